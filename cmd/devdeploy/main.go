@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"devdeploy/internal/agent"
+	"devdeploy/internal/config"
 	"devdeploy/internal/ui"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -14,7 +16,17 @@ func main() {
 		os.Exit(1)
 	}
 
-	model := ui.NewAppModel().AsTeaModel()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "devdeploy: config: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := agent.FromConfig(cfg.Agent); err != nil {
+		fmt.Fprintf(os.Stderr, "devdeploy: config: %v\n", err)
+		os.Exit(1)
+	}
+
+	model := ui.NewAppModel(ui.WithConfig(cfg)).AsTeaModel()
 	p := tea.NewProgram(model, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"devdeploy/internal/agent"
+	"devdeploy/internal/ralph"
+)
+
+// runLogs implements `ralph logs`: list the transcripts of a run, or
+// pretty-print a bead's transcripts as conversations.
+func runLogs(args []string) int {
+	fs := flag.NewFlagSet("ralph logs", flag.ExitOnError)
+	workdir := fs.String("workdir", ".", "path to the repository")
+	prompt := fs.Bool("prompt", false, "also print the prompt each agent was given")
+	raw := fs.Bool("raw", false, "print the stream-json output as recorded")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ralph logs [flags] <run-id> [<bead-id>]\n\n")
+		fmt.Fprintf(os.Stderr, "Without a bead, lists the beads with transcripts in the run.\n")
+		fmt.Fprintf(os.Stderr, "With one, prints each of its agent runs as a conversation.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 1
+	}
+	runID := fs.Arg(0)
+
+	var err error
+	if fs.NArg() == 1 {
+		err = listRunTranscripts(os.Stdout, *workdir, runID)
+	} else {
+		err = printBeadTranscripts(os.Stdout, *workdir, runID, fs.Arg(1), *prompt, *raw)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// listRunTranscripts prints each bead of a run with its agent runs.
+func listRunTranscripts(w io.Writer, workdir, runID string) error {
+	ids, err := ralph.TranscriptBeads(workdir, runID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		ts, err := ralph.ListTranscripts(workdir, runID, id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s", id)
+		for _, t := range ts {
+			fmt.Fprintf(w, " %s-%d", t.Role, t.N)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// printBeadTranscripts prints every agent run of a bead, oldest first.
+func printBeadTranscripts(w io.Writer, workdir, runID, beadID string, prompt, raw bool) error {
+	ts, err := ralph.ListTranscripts(workdir, runID, beadID)
+	if err != nil {
+		return err
+	}
+	for i, t := range ts {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "═══ %s %s-%d (%s)\n", beadID, t.Role, t.N, t.Path+ralph.TranscriptStdoutExt)
+		if prompt {
+			if err := copyFile(w, t.Path+ralph.TranscriptPromptExt, "Prompt:\n"); err != nil {
+				return err
+			}
+		}
+		f, err := os.Open(t.Path + ralph.TranscriptStdoutExt)
+		if err != nil {
+			return err
+		}
+		if raw {
+			_, err = io.Copy(w, f)
+		} else {
+			err = agent.FormatTranscript(w, f)
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if err := copyFile(w, t.Path+ralph.TranscriptStderrExt, "\nStderr:\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile writes header and the contents of path to w. A missing file
+// writes nothing.
+func copyFile(w io.Writer, path, header string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, header)
+	_, err = w.Write(data)
+	return err
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
+	"devdeploy/internal/agent"
+	ddconfig "devdeploy/internal/config"
+	"devdeploy/internal/project"
 	"devdeploy/internal/ralph"
+	"devdeploy/internal/ralph/tui"
 )
 
 // config holds the parsed CLI configuration for a ralph run.
@@ -17,6 +23,23 @@ type config struct {
 	bead         string        // root bead (epic or single task) to complete
 	maxParallel  int           // max concurrent agents
 	agentTimeout time.Duration // per-agent timeout
+	maxIter      int           // max beads to start (0 = unlimited)
+	maxFailures  int           // consecutive failures before stopping
+	timeout      time.Duration // total wall-clock limit
+	resume       string        // run ID to resume
+	verify       string        // command gating merges (overrides project config)
+	review       bool          // run a reviewer agent before merging
+	escalate     string        // model failed beads are retried on once (overrides project config)
+	maxAttempts  int           // attempts per failed bead (overrides project config retry)
+	pr           bool          // open a pull request per bead instead of merging
+	pool         bool          // reuse worktrees across beads
+	mergeStrat   string        // how beads land: merge, squash, rebase, ff-only (overrides project config)
+	budget       float64       // stop once agents cost this many USD (0 = none)
+	report       string        // machine-readable report format (json, jsonl)
+	reportFile   string        // report destination ("-" = stdout)
+	tui          bool          // interactive trace view instead of log lines
+	keepRuns     int           // runs whose transcripts are kept (0 = no transcripts)
+	control      bool          // serve the control API on a unix socket
 	verbose      bool          // detailed logging
 }
 
@@ -26,11 +49,32 @@ func parseFlags() config {
 	flag.StringVar(&cfg.workdir, "workdir", "", "path to the repository to operate in (required)")
 	flag.StringVar(&cfg.bead, "bead", "", "root bead ID - epic or single task to complete (required)")
 	flag.IntVar(&cfg.maxParallel, "max-parallel", 4, "maximum parallel agents (use 1 for sequential)")
-	flag.DurationVar(&cfg.agentTimeout, "agent-timeout", 10*time.Minute, "per-agent execution timeout")
-	flag.BoolVar(&cfg.verbose, "verbose", false, "enable detailed logging")
+	flag.DurationVar(&cfg.agentTimeout, "agent-timeout", 10*time.Minute, "per-agent execution timeout (default: project config agent_timeout; a timeout:<d> label overrides it per bead)")
+	flag.IntVar(&cfg.maxIter, "max-iterations", 0, "maximum beads to start (0 = unlimited)")
+	flag.IntVar(&cfg.maxFailures, "max-failures", ralph.DefaultConsecutiveFailureLimit, "stop after this many consecutive failures (0 = never)")
+	flag.DurationVar(&cfg.timeout, "timeout", ralph.DefaultWallClockTimeout, "total wall-clock limit for the run (0 = none)")
+	flag.StringVar(&cfg.verify, "verify", "", "command that must pass before and after each merge, e.g. \"go test ./...\" (default: project config verify)")
+	flag.BoolVar(&cfg.review, "review", false, "have a reviewer agent (agent.review_model) approve each bead before merging")
+	flag.StringVar(&cfg.escalate, "escalation-model", "", "retry a failed bead once on this model (default: project config escalation_model)")
+	flag.BoolVar(&cfg.pr, "pr", false, "push each successful bead's branch and open a pull request with gh instead of merging (default: project config pull_requests)")
+	flag.BoolVar(&cfg.pool, "worktree-pool", false, "reuse up to --max-parallel worktrees across beads instead of creating one per bead (default: project config worktree_pool)")
+	flag.StringVar(&cfg.mergeStrat, "merge-strategy", "", "how successful beads land: merge, squash, rebase or ff-only (default: project config merge_strategy, then merge)")
+	flag.IntVar(&cfg.maxAttempts, "max-attempts", 0, "attempts per bead that fails or times out before it is labeled ralph-failed (0 = project config retry)")
+	flag.Float64Var(&cfg.budget, "budget", 0, "stop starting beads once agents have cost this many USD (0 = no budget; see agent.prices)")
+	flag.StringVar(&cfg.resume, "resume", "", "resume an interrupted run by ID (--bead defaults to the run's)")
+	flag.StringVar(&cfg.report, "report", "", "write a machine-readable run report: json or jsonl")
+	flag.StringVar(&cfg.reportFile, "report-file", "-", "report destination (- = stdout; progress then goes to stderr)")
+	flag.BoolVar(&cfg.tui, "tui", isTerminal(os.Stdout), "show a live trace view (default: on when stdout is a terminal)")
+	flag.BoolVar(&cfg.control, "control", true, "serve the control API on a unix socket in the temp dir (see devdeploy)")
+	flag.IntVar(&cfg.keepRuns, "keep-transcripts", ralph.DefaultTranscriptRuns, "keep agent transcripts of this many recent runs (0 = don't record transcripts)")
+	flag.BoolVar(&cfg.verbose, "verbose", false, "enable detailed logging, including the agents' raw output (shown anyway when no transcripts are written)")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: ralph --workdir=<path> --bead=<id> [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: ralph --workdir=<path> --bead=<id> [flags]\n")
+		fmt.Fprintf(os.Stderr, "       ralph plan --bead=<epic> [--format=text|dot]\n")
+		fmt.Fprintf(os.Stderr, "       ralph prompt --bead=<id>\n")
+		fmt.Fprintf(os.Stderr, "       ralph logs <run-id> [<bead-id>]\n")
+		fmt.Fprintf(os.Stderr, "       ralph watch [flags] [<repo>...]\n\n")
 		fmt.Fprintf(os.Stderr, "Ralph is an autonomous agent work loop that processes beads\n")
 		fmt.Fprintf(os.Stderr, "and dispatches agents to complete them in parallel.\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
@@ -38,7 +82,12 @@ func parseFlags() config {
 		fmt.Fprintf(os.Stderr, "\nExit codes:\n")
 		fmt.Fprintf(os.Stderr, "  0  Normal completion (all beads processed)\n")
 		fmt.Fprintf(os.Stderr, "  1  Runtime error\n")
+		fmt.Fprintf(os.Stderr, "  2  --max-iterations reached\n")
+		fmt.Fprintf(os.Stderr, "  3  --max-failures consecutive failures\n")
+		fmt.Fprintf(os.Stderr, "  4  --timeout exceeded\n")
 		fmt.Fprintf(os.Stderr, "  5  Interrupted (SIGINT)\n")
+		fmt.Fprintf(os.Stderr, "  6  Only beads that already failed in this run remain\n")
+		fmt.Fprintf(os.Stderr, "  7  --budget spent\n")
 	}
 
 	flag.Parse()
@@ -49,15 +98,89 @@ func parseFlags() config {
 		os.Exit(1)
 	}
 
-	if cfg.bead == "" {
+	if cfg.bead == "" && cfg.resume == "" {
 		fmt.Fprintln(os.Stderr, "error: --bead is required")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if cfg.budget < 0 {
+		fmt.Fprintln(os.Stderr, "error: --budget must not be negative")
+		os.Exit(1)
+	}
+
+	if cfg.keepRuns < 0 {
+		fmt.Fprintln(os.Stderr, "error: --keep-transcripts must not be negative")
+		os.Exit(1)
+	}
+
+	if cfg.report != "" {
+		if _, err := ralph.ParseReportFormat(cfg.report); err != nil {
+			fmt.Fprintf(os.Stderr, "error: --report: %v\n", err)
+			os.Exit(1)
+		}
+		// A report on stdout and the TUI cannot share the terminal.
+		if cfg.tui && (cfg.reportFile == "-" || cfg.reportFile == "") {
+			if flagSet("tui") {
+				fmt.Fprintln(os.Stderr, "error: --tui needs --report-file when --report is set")
+				os.Exit(1)
+			}
+			cfg.tui = false
+		}
+	}
+
 	return cfg
 }
 
+// loopAgent returns the backend and model of ralph's agents in a project:
+// its agent_backend and agent_model, or else the global agent.backend and
+// agent.loop_model. The global loop model is only used with the global
+// backend.
+func loopAgent(global *ddconfig.Config, proj *project.Config) (agent.Backend, string, error) {
+	name := proj.AgentBackend
+	if name == "" {
+		name = global.Agent.Backend
+	}
+	backend, err := agent.ByName(global.Agent, name)
+	if err != nil {
+		return nil, "", err
+	}
+	model := proj.AgentModel
+	if model == "" && (proj.AgentBackend == "" || proj.AgentBackend == global.Agent.Backend) {
+		model = global.Agent.LoopModel
+	}
+	return backend, model, nil
+}
+
+// retryPolicy converts the project config's retry rules; maxAttempts, when
+// positive, replaces their attempt limits.
+func retryPolicy(cfg project.RetryConfig, maxAttempts int) ralph.RetryPolicy {
+	rule := func(r project.RetryRule) ralph.RetryRule {
+		if maxAttempts > 0 {
+			r.MaxAttempts = maxAttempts
+		}
+		return ralph.RetryRule{MaxAttempts: r.MaxAttempts, Backoff: r.Backoff}
+	}
+	return ralph.RetryPolicy{Failure: rule(cfg.Failure), Timeout: rule(cfg.Timeout)}
+}
+
+// isTerminal reports whether f is a character device, i.e. a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func run(cfg config) (int, error) {
 	// Verify workdir exists
 	info, err := os.Stat(cfg.workdir)
@@ -72,31 +195,212 @@ func run(cfg config) (int, error) {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
+	globalCfg, err := ddconfig.Load()
+	if err != nil {
+		return 1, fmt.Errorf("config: %w", err)
+	}
+
+	// Worktrees managed by devdeploy pick up their project's config.
+	projCfg, err := project.LoadConfigForWorktree(cfg.workdir)
+	if err != nil {
+		return 1, err
+	}
+	backend, model, err := loopAgent(globalCfg, projCfg)
+	if err != nil {
+		return 1, fmt.Errorf("config: %w", err)
+	}
+	templates, err := ralph.LoadTemplates(cfg.workdir, projCfg.PromptTemplate, projCfg.MergeTemplate)
+	if err != nil {
+		return 1, err
+	}
+	agentTimeout := cfg.agentTimeout
+	if !flagSet("agent-timeout") && projCfg.AgentTimeout > 0 {
+		agentTimeout = projCfg.AgentTimeout
+	}
+	escalate := cfg.escalate
+	if escalate == "" {
+		escalate = projCfg.EscalationModel
+	}
+	verify := cfg.verify
+	if verify == "" {
+		verify = projCfg.Verify
+	}
+	mergeStrategy := cfg.mergeStrat
+	if mergeStrategy == "" {
+		mergeStrategy = projCfg.MergeStrategy
+	}
+	strategy, err := ralph.ParseMergeStrategy(mergeStrategy)
+	if err != nil {
+		return 1, err
+	}
+
+	// A report on stdout must be the only thing there, so human-readable
+	// progress moves to stderr.
+	var progress io.Writer = os.Stdout
+	var reporter *ralph.Reporter
+	if cfg.report != "" {
+		format, _ := ralph.ParseReportFormat(cfg.report) // validated in parseFlags
+		var w io.Writer = os.Stdout
+		if cfg.reportFile == "-" || cfg.reportFile == "" {
+			progress = os.Stderr
+		} else {
+			f, err := os.Create(cfg.reportFile)
+			if err != nil {
+				return 1, fmt.Errorf("report file: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		reporter = ralph.NewReporter(w, format)
+	}
+
+	// Journal the run so it can be resumed after an interruption.
+	var journal *ralph.Journal
+	if cfg.resume != "" {
+		journal, err = ralph.OpenJournal(cfg.workdir, cfg.resume)
+		if err != nil {
+			return 1, err
+		}
+		if cfg.bead == "" {
+			cfg.bead = journal.State().RootBead
+		}
+	} else if journal, err = ralph.NewJournal(cfg.workdir, cfg.bead); err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: warning: run journal disabled: %v\n", err)
+	}
+	if journal != nil {
+		defer journal.Close()
+		fmt.Fprintf(progress, "Run %s (resume with --resume %s)\n", journal.RunID(), journal.RunID())
+	}
+
+	// Transcripts live next to the journal, so they need one.
+	var transcripts *ralph.Transcripts
+	if journal != nil && cfg.keepRuns > 0 {
+		if err := ralph.PruneTranscripts(cfg.workdir, cfg.keepRuns, journal.RunID()); err != nil {
+			fmt.Fprintf(os.Stderr, "ralph: warning: pruning transcripts: %v\n", err)
+		}
+		if transcripts, err = ralph.NewTranscripts(cfg.workdir, journal.RunID()); err != nil {
+			fmt.Fprintf(os.Stderr, "ralph: warning: transcripts disabled: %v\n", err)
+		}
+	}
+
+	// The TUI owns the terminal, so log lines go to a file next to the
+	// journal instead.
+	var logPath string
+	if cfg.tui {
+		progress = io.Discard
+		if journal != nil {
+			logPath = strings.TrimSuffix(journal.Path(), ".jsonl") + ".log"
+			f, err := os.Create(logPath)
+			if err != nil {
+				return 1, fmt.Errorf("log file: %w", err)
+			}
+			defer f.Close()
+			progress = f
+		}
+	}
+
 	core := &ralph.Core{
 		WorkDir:      cfg.workdir,
 		RootBead:     cfg.bead,
 		MaxParallel:  cfg.maxParallel,
-		AgentTimeout: cfg.agentTimeout,
-		Output:       os.Stdout,
+		AgentTimeout: agentTimeout,
+		Model:        model,
+		Env:          projCfg.Environ(),
+		Backend:      backend,
+		Output:       progress,
+		Journal:      journal,
+		Reporter:     reporter,
+		Transcripts:  transcripts,
+
+		BackendByName: func(name string) (agent.Backend, error) {
+			return agent.ByName(globalCfg.Agent, name)
+		},
+		EscalationModel:  escalate,
+		Retry:            retryPolicy(projCfg.Retry, cfg.maxAttempts),
+		PullRequests:     cfg.pr || projCfg.PullRequests,
+		WorktreePool:     cfg.pool || projCfg.WorktreePool,
+		MergeStrategy:    strategy,
+		ConflictAttempts: projCfg.ConflictAttempts,
+		Templates:        templates,
+
+		VerifyCommand: verify,
+		Review:        cfg.review,
+		ReviewModel:   globalCfg.Agent.ReviewModel,
+		Prices:        globalCfg.Agent.Prices,
+		Budget:        cfg.budget,
+
+		MaxIterations:           cfg.maxIter,
+		ConsecutiveFailureLimit: cfg.maxFailures,
+		WallClockTimeout:        cfg.timeout,
+	}
+	// A zero flag means "no limit", which Core spells as negative.
+	if cfg.maxFailures == 0 {
+		core.ConsecutiveFailureLimit = -1
+	}
+	if cfg.timeout == 0 {
+		core.WallClockTimeout = -1
+	}
+	switch {
+	case cfg.tui:
+		core.AgentOutput = io.Discard
+	case cfg.verbose:
+		core.AgentOutput = progress
 	}
 
-	result, err := core.Run(ctx)
-	if err != nil {
-		return 1, err
+	// Let devdeploy see and steer the run.
+	if cfg.control {
+		socket := ralph.ControlSocketPath(cfg.workdir)
+		l, err := ralph.ListenControl(socket)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ralph: warning: control API disabled: %v\n", err)
+		} else {
+			core.Control = ralph.NewController()
+			serveCtx, stopServe := context.WithCancel(context.Background())
+			served := make(chan struct{})
+			go func() {
+				defer close(served)
+				_ = core.Control.Serve(serveCtx, l)
+			}()
+			defer func() {
+				stopServe()
+				<-served
+			}()
+			fmt.Fprintf(progress, "Control socket %s\n", socket)
+		}
 	}
 
-	// Return appropriate exit code based on context cancellation
-	if ctx.Err() != nil {
-		return 5, nil // Interrupted
+	var summary *ralph.RunSummary
+	if cfg.tui {
+		summary, err = tui.Run(ctx, core)
+		if logPath != "" {
+			fmt.Fprintf(os.Stderr, "ralph: log written to %s\n", logPath)
+		}
+	} else {
+		summary, err = core.Run(ctx)
+	}
+	if err != nil {
+		return 1, err
 	}
 
-	// Consider any failures as partial success (exit 0) since Core
-	// processes all available beads. The summary shows failure counts.
-	_ = result
-	return 0, nil
+	// Individual failures still exit 0 when the loop ran to completion;
+	// the summary shows failure counts.
+	return summary.StopReason.ExitCode(), nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "plan" {
+		os.Exit(runPlan(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "prompt" {
+		os.Exit(runPrompt(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "logs" {
+		os.Exit(runLogs(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		os.Exit(runWatch(os.Args[2:]))
+	}
+
 	cfg := parseFlags()
 	exitCode, err := run(cfg)
 	if err != nil {
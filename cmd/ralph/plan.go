@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"devdeploy/internal/ralph"
+)
+
+// runPlan implements `ralph plan`: print the expected execution of an epic
+// without launching agents.
+func runPlan(args []string) int {
+	fs := flag.NewFlagSet("ralph plan", flag.ExitOnError)
+	workdir := fs.String("workdir", ".", "path to the repository")
+	bead := fs.String("bead", "", "root bead ID - epic to plan (required)")
+	maxParallel := fs.Int("max-parallel", 4, "maximum parallel agents to plan for")
+	format := fs.String("format", "text", "output format: text or dot")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ralph plan --bead=<epic> [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Shows the waves in which ralph would work the epic's beads, and\n")
+		fmt.Fprintf(os.Stderr, "flags dependency cycles and beads that never become ready.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if *bead == "" {
+		fmt.Fprintln(os.Stderr, "error: --bead is required")
+		fs.Usage()
+		return 1
+	}
+	if *format != "text" && *format != "dot" {
+		fmt.Fprintf(os.Stderr, "error: unknown --format %q (want text or dot)\n", *format)
+		return 1
+	}
+
+	plan, err := ralph.BuildPlan(nil, *workdir, *bead, *maxParallel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	if *format == "dot" {
+		err = plan.WriteDOT(os.Stdout)
+	} else {
+		err = plan.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"devdeploy/internal/project"
+	"devdeploy/internal/ralph"
+)
+
+// runPrompt implements `ralph prompt`: print the prompt an agent would get
+// for a bead, rendered with the repo's template overrides.
+func runPrompt(args []string) int {
+	fs := flag.NewFlagSet("ralph prompt", flag.ExitOnError)
+	workdir := fs.String("workdir", ".", "path to the repository")
+	bead := fs.String("bead", "", "bead ID to render the prompt for (required)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ralph prompt --bead=<id> [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Prints the prompt ralph would give the bead's agent, using the\n")
+		fmt.Fprintf(os.Stderr, "project config's prompt_template or %s if set.\n\n", ralph.PromptTemplateFile)
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if *bead == "" {
+		fmt.Fprintln(os.Stderr, "error: --bead is required")
+		fs.Usage()
+		return 1
+	}
+
+	projCfg, err := project.LoadConfigForWorktree(*workdir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	templates, err := ralph.LoadTemplates(*workdir, projCfg.PromptTemplate, projCfg.MergeTemplate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	data, err := ralph.FetchPromptData(nil, *workdir, *bead)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	prompt, err := templates.RenderPrompt(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	if templates.PromptSource != "" {
+		fmt.Fprintf(os.Stderr, "ralph: using %s\n", templates.PromptSource)
+	}
+	fmt.Print(prompt)
+	return 0
+}
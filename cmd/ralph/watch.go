@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"devdeploy/internal/agent"
+	ddconfig "devdeploy/internal/config"
+	"devdeploy/internal/project"
+	"devdeploy/internal/ralph"
+)
+
+// watchConfig is the configuration ralph watch builds Cores from. It is
+// loaded at startup and again on SIGHUP.
+type watchConfig struct {
+	global   *ddconfig.Config
+	projects map[string]*watchProject // by repo
+}
+
+// watchProject is a repo's project config, the agent it selects and its
+// prompt templates.
+type watchProject struct {
+	*project.Config
+	backend   agent.Backend
+	model     string
+	templates *ralph.Templates
+}
+
+// loadWatchConfig loads the global config and each repo's project config.
+func loadWatchConfig(repos []string) (*watchConfig, error) {
+	global, err := ddconfig.Load()
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	wc := &watchConfig{global: global, projects: make(map[string]*watchProject)}
+	for _, repo := range repos {
+		projCfg, err := project.LoadConfigForWorktree(repo)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", repo, err)
+		}
+		backend, model, err := loopAgent(global, projCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: config: %w", repo, err)
+		}
+		templates, err := ralph.LoadTemplates(repo, projCfg.PromptTemplate, projCfg.MergeTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", repo, err)
+		}
+		wc.projects[repo] = &watchProject{Config: projCfg, backend: backend, model: model, templates: templates}
+	}
+	return wc, nil
+}
+
+// runWatch implements `ralph watch`: keep working ready beads in one or
+// more repos until interrupted.
+func runWatch(args []string) int {
+	fs := flag.NewFlagSet("ralph watch", flag.ExitOnError)
+	maxConcurrency := fs.Int("max-concurrency", ralph.DefaultMaxConcurrency, "maximum agents running at once across all repos")
+	maxParallel := fs.Int("max-parallel", 0, "maximum parallel agents per repo (0 = --max-concurrency)")
+	agentTimeout := fs.Duration("agent-timeout", 10*time.Minute, "per-agent execution timeout (default: project config agent_timeout)")
+	poll := fs.Duration("poll", ralph.DefaultPollInterval, "how often to check the beads database for changes")
+	maxIdle := fs.Duration("max-idle", ralph.DefaultMaxIdleInterval, "longest wait between ready checks of an unchanged repo")
+	verify := fs.String("verify", "", "command that must pass before and after each merge (default: project config verify)")
+	review := fs.Bool("review", false, "have a reviewer agent approve each bead before merging")
+	escalate := fs.String("escalation-model", "", "retry a failed bead once on this model (default: project config escalation_model)")
+	pr := fs.Bool("pr", false, "open a pull request per bead instead of merging (default: project config pull_requests)")
+	pool := fs.Bool("worktree-pool", false, "reuse worktrees across beads instead of creating one per bead (default: project config worktree_pool)")
+	mergeStrategy := fs.String("merge-strategy", "", "how successful beads land: merge, squash, rebase or ff-only (default: project config merge_strategy)")
+	maxAttempts := fs.Int("max-attempts", 0, "attempts per bead that fails or times out before it is labeled ralph-failed (0 = project config retry)")
+	keepRuns := fs.Int("keep-transcripts", ralph.DefaultTranscriptRuns, "keep agent transcripts of this many recent runs per repo (0 = don't record transcripts)")
+	verbose := fs.Bool("verbose", false, "also print the agents' raw output")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: ralph watch [flags] [<repo>...]\n\n")
+		fmt.Fprintf(os.Stderr, "Watches the repos (default: the current directory) and works their\n")
+		fmt.Fprintf(os.Stderr, "ready beads as they appear, until interrupted. Each batch of work\n")
+		fmt.Fprintf(os.Stderr, "is a separate run with its own journal. SIGHUP reloads the\n")
+		fmt.Fprintf(os.Stderr, "configuration and retries beads that failed.\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	timeoutSet := false
+	fs.Visit(func(f *flag.Flag) { timeoutSet = timeoutSet || f.Name == "agent-timeout" })
+
+	repos := fs.Args()
+	if len(repos) == 0 {
+		repos = []string{"."}
+	}
+	for i, repo := range repos {
+		abs, err := filepath.Abs(repo)
+		if err == nil {
+			repos[i] = abs
+		}
+		if info, err := os.Stat(repos[i]); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "ralph: %s is not a directory\n", repo)
+			return 1
+		}
+	}
+	if *maxConcurrency < 1 || *maxParallel < 0 || *keepRuns < 0 {
+		fmt.Fprintln(os.Stderr, "error: --max-concurrency must be positive, --max-parallel and --keep-transcripts not negative")
+		return 1
+	}
+	if _, err := ralph.ParseMergeStrategy(*mergeStrategy); err != nil {
+		fmt.Fprintf(os.Stderr, "error: --merge-strategy: %v\n", err)
+		return 1
+	}
+	perRepo := *maxParallel
+	if perRepo == 0 {
+		perRepo = *maxConcurrency
+	}
+
+	var mu sync.Mutex
+	wc, err := loadWatchConfig(repos)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+
+	newCore := func(workDir string) (*ralph.Core, error) {
+		mu.Lock()
+		cfg := wc
+		mu.Unlock()
+		projCfg := cfg.projects[workDir]
+		timeout := *agentTimeout
+		if !timeoutSet && projCfg.AgentTimeout > 0 {
+			timeout = projCfg.AgentTimeout
+		}
+		escalation := *escalate
+		if escalation == "" {
+			escalation = projCfg.EscalationModel
+		}
+		verifyCmd := *verify
+		if verifyCmd == "" {
+			verifyCmd = projCfg.Verify
+		}
+		strategyName := *mergeStrategy
+		if strategyName == "" {
+			strategyName = projCfg.MergeStrategy
+		}
+		strategy, err := ralph.ParseMergeStrategy(strategyName)
+		if err != nil {
+			return nil, err
+		}
+		core := &ralph.Core{
+			WorkDir:      workDir,
+			MaxParallel:  perRepo,
+			AgentTimeout: timeout,
+			Model:        projCfg.model,
+			Env:          projCfg.Environ(),
+			Backend:      projCfg.backend,
+			BackendByName: func(name string) (agent.Backend, error) {
+				return agent.ByName(cfg.global.Agent, name)
+			},
+			EscalationModel:  escalation,
+			Retry:            retryPolicy(projCfg.Retry, *maxAttempts),
+			PullRequests:     *pr || projCfg.PullRequests,
+			WorktreePool:     *pool || projCfg.WorktreePool,
+			MergeStrategy:    strategy,
+			ConflictAttempts: projCfg.ConflictAttempts,
+			Templates:        projCfg.templates,
+			VerifyCommand:    verifyCmd,
+			Review:           *review,
+			ReviewModel:      cfg.global.Agent.ReviewModel,
+			Prices:           cfg.global.Agent.Prices,
+			// Watching has no natural end; only failures stop a pass.
+			WallClockTimeout: -1,
+		}
+		if *verbose {
+			core.AgentOutput = os.Stdout
+		}
+		journal, err := ralph.NewJournal(workDir, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ralph: warning: run journal disabled: %v\n", err)
+			return core, nil
+		}
+		core.Journal = journal
+		fmt.Printf("Run %s in %s\n", journal.RunID(), workDir)
+		if *keepRuns > 0 {
+			if err := ralph.PruneTranscripts(workDir, *keepRuns, journal.RunID()); err != nil {
+				fmt.Fprintf(os.Stderr, "ralph: warning: pruning transcripts: %v\n", err)
+			}
+			if core.Transcripts, err = ralph.NewTranscripts(workDir, journal.RunID()); err != nil {
+				fmt.Fprintf(os.Stderr, "ralph: warning: transcripts disabled: %v\n", err)
+			}
+		}
+		return core, nil
+	}
+
+	w := &ralph.Watcher{
+		Repos:           repos,
+		NewCore:         newCore,
+		MaxConcurrency:  *maxConcurrency,
+		PollInterval:    *poll,
+		MaxIdleInterval: *maxIdle,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+			}
+			reloaded, err := loadWatchConfig(repos)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ralph: reload failed, keeping the old configuration: %v\n", err)
+				continue
+			}
+			mu.Lock()
+			wc = reloaded
+			mu.Unlock()
+			fmt.Println("Configuration reloaded")
+			w.Reload()
+		}
+	}()
+
+	if err := w.Watch(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "ralph: %v\n", err)
+		return 1
+	}
+	return 0
+}
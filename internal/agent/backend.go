@@ -0,0 +1,388 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"devdeploy/internal/config"
+)
+
+// Backend names accepted by agent.backend in the global config.
+const (
+	BackendCursor  = "cursor"
+	BackendClaude  = "claude"
+	BackendAider   = "aider"
+	BackendCommand = "command"
+)
+
+// Backend drives one agent CLI. It owns the command line for headless
+// (ralph) and interactive (tmux pane) runs, and the parser for the
+// headless output stream.
+type Backend interface {
+	// Name returns the backend name (BackendCursor, ...).
+	Name() string
+	// Binary returns the executable to run.
+	Binary() string
+	// HeadlessArgs returns the arguments, without the binary, for a
+	// non-interactive run. An empty model means the CLI's default.
+	HeadlessArgs(model, prompt string) []string
+	// InteractiveArgs returns the arguments for an interactive session.
+	// prompt may be empty.
+	InteractiveArgs(model, prompt string) []string
+	// NewParser returns a parser for the output of a headless run.
+	NewParser() StreamParser
+}
+
+// StreamParser consumes a headless run's stdout one line at a time.
+type StreamParser interface {
+	ParseLine(line string)
+	Result() Result
+}
+
+// Result is what a StreamParser extracts from an agent's output.
+type Result struct {
+	// ChatID is the backend's session/chat ID, useful for finding the
+	// conversation of a failed run.
+	ChatID string
+	// ErrorMessage is the error reported by the agent, if any.
+	ErrorMessage string
+	// Text is the agent's final answer, when the CLI reports it.
+	Text string
+	// Usage is the run's token usage, when the CLI reports it.
+	Usage Usage
+}
+
+// ParseOutput feeds complete captured output to p and returns its result.
+func ParseOutput(p StreamParser, stdout string) Result {
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		p.ParseLine(scanner.Text())
+	}
+	return p.Result()
+}
+
+// FromConfig builds the backend selected by the global agent config.
+func FromConfig(cfg config.AgentConfig) (Backend, error) {
+	switch cfg.Backend {
+	case "", BackendCursor:
+		return Cursor{Command: cfg.Command}, nil
+	case BackendClaude:
+		return Claude{Command: cfg.Command}, nil
+	case BackendAider:
+		return Aider{Command: cfg.Command}, nil
+	case BackendCommand:
+		return NewTemplate(cfg.Command, cfg.Args, cfg.InteractiveArgs)
+	default:
+		return nil, fmt.Errorf("unknown agent.backend %q (want %s, %s, %s or %s)",
+			cfg.Backend, BackendCursor, BackendClaude, BackendAider, BackendCommand)
+	}
+}
+
+// ByName builds the named backend. cfg's command and argument templates
+// only apply when cfg selects that backend; any other backend runs its
+// default binary.
+func ByName(cfg config.AgentConfig, name string) (Backend, error) {
+	if orDefault(cfg.Backend, BackendCursor) != orDefault(name, BackendCursor) {
+		cfg = config.AgentConfig{Backend: name}
+	}
+	return FromConfig(cfg)
+}
+
+// CommandLine renders binary and args as a single shell command line,
+// single-quoting every word so prompts survive backticks and $.
+func CommandLine(b Backend, args []string) string {
+	words := make([]string, 0, len(args)+1)
+	for _, w := range append([]string{b.Binary()}, args...) {
+		words = append(words, "'"+strings.ReplaceAll(w, "'", `'\''`)+"'")
+	}
+	return strings.Join(words, " ")
+}
+
+// modelArgs returns --model <model>, or nothing for the CLI default.
+func modelArgs(model string) []string {
+	if model == "" {
+		return nil
+	}
+	return []string{"--model", model}
+}
+
+// orDefault returns s, or def when s is empty.
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// ---------------------------------------------------------------------------
+// Cursor
+// ---------------------------------------------------------------------------
+
+// Cursor is the Cursor "agent" CLI with stream-json output.
+type Cursor struct {
+	Command string // binary; empty means "agent"
+}
+
+func (c Cursor) Name() string   { return BackendCursor }
+func (c Cursor) Binary() string { return orDefault(c.Command, "agent") }
+
+func (c Cursor) HeadlessArgs(model, prompt string) []string {
+	args := append(modelArgs(model), "--print", "--force", "--output-format", "stream-json")
+	return append(args, prompt)
+}
+
+func (c Cursor) InteractiveArgs(model, prompt string) []string {
+	args := append(modelArgs(model), "--force")
+	if prompt != "" {
+		args = append(args, prompt)
+	}
+	return args
+}
+
+// NewParser parses the final "result" event:
+// {"type":"result","chatId":"...","result":"...","error":"...","duration_ms":...}
+func (c Cursor) NewParser() StreamParser {
+	return &resultEventParser{extract: cursorResult}
+}
+
+func cursorResult(event map[string]any, r *Result) {
+	// chatId may be camel- or snake-case depending on the CLI version.
+	if id, ok := event["chatId"].(string); ok {
+		r.ChatID = id
+	} else if id, ok := event["chat_id"].(string); ok {
+		r.ChatID = id
+	}
+	if text, ok := event["result"].(string); ok {
+		r.Text = text
+	}
+	if errStr, ok := event["error"].(string); ok && errStr != "" {
+		r.ErrorMessage = errStr
+	} else if errObj, ok := event["error"].(map[string]any); ok {
+		if msg, ok := errObj["message"].(string); ok {
+			r.ErrorMessage = msg
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Claude
+// ---------------------------------------------------------------------------
+
+// Claude is a Claude-style CLI ("claude -p --output-format stream-json").
+type Claude struct {
+	Command string // binary; empty means "claude"
+}
+
+func (c Claude) Name() string   { return BackendClaude }
+func (c Claude) Binary() string { return orDefault(c.Command, "claude") }
+
+func (c Claude) HeadlessArgs(model, prompt string) []string {
+	// stream-json in print mode requires --verbose.
+	args := append(modelArgs(model), "--print", "--output-format", "stream-json", "--verbose", "--dangerously-skip-permissions")
+	return append(args, prompt)
+}
+
+func (c Claude) InteractiveArgs(model, prompt string) []string {
+	args := append(modelArgs(model), "--dangerously-skip-permissions")
+	if prompt != "" {
+		args = append(args, prompt)
+	}
+	return args
+}
+
+// NewParser parses the final "result" event:
+// {"type":"result","subtype":"success","is_error":false,"result":"...","session_id":"..."}
+func (c Claude) NewParser() StreamParser {
+	return &resultEventParser{extract: claudeResult}
+}
+
+func claudeResult(event map[string]any, r *Result) {
+	if id, ok := event["session_id"].(string); ok {
+		r.ChatID = id
+	}
+	text, _ := event["result"].(string)
+	if isErr, _ := event["is_error"].(bool); isErr {
+		msg := text
+		if msg == "" {
+			msg, _ = event["subtype"].(string)
+		}
+		r.ErrorMessage = msg
+	} else {
+		r.Text = text
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Aider
+// ---------------------------------------------------------------------------
+
+// Aider is the aider CLI. It prints plain text, so there is no chat ID and
+// the outcome comes from the exit code alone.
+type Aider struct {
+	Command string // binary; empty means "aider"
+}
+
+func (a Aider) Name() string   { return BackendAider }
+func (a Aider) Binary() string { return orDefault(a.Command, "aider") }
+
+func (a Aider) HeadlessArgs(model, prompt string) []string {
+	args := append(modelArgs(model), "--yes-always", "--no-pretty", "--no-stream")
+	return append(args, "--message", prompt)
+}
+
+// InteractiveArgs starts an aider chat. aider has no way to seed an
+// interactive chat, so a prompt is sent with --message and aider exits
+// after answering it.
+func (a Aider) InteractiveArgs(model, prompt string) []string {
+	args := append(modelArgs(model), "--yes-always")
+	if prompt != "" {
+		args = append(args, "--message", prompt)
+	}
+	return args
+}
+
+func (a Aider) NewParser() StreamParser { return &plainParser{} }
+
+// plainParser extracts nothing; used for CLIs without structured output.
+type plainParser struct{}
+
+func (p *plainParser) ParseLine(string) {}
+func (p *plainParser) Result() Result   { return Result{} }
+
+// ---------------------------------------------------------------------------
+// Command template
+// ---------------------------------------------------------------------------
+
+// TemplateData is the data available to command template arguments.
+type TemplateData struct {
+	Model  string
+	Prompt string
+}
+
+// Template runs an arbitrary CLI. Each argument is a text/template over
+// TemplateData; arguments that render empty are dropped, so optional flags
+// can be written as {{if .Model}}--model={{.Model}}{{end}}.
+type Template struct {
+	command     string
+	args        []*template.Template
+	interactive []*template.Template
+}
+
+// NewTemplate parses the headless and interactive argument templates.
+// Without interactive templates the headless ones are used for both.
+func NewTemplate(command string, args, interactiveArgs []string) (*Template, error) {
+	if command == "" {
+		return nil, fmt.Errorf("agent.command is required for the %s backend", BackendCommand)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("agent.args is required for the %s backend", BackendCommand)
+	}
+	t := &Template{command: command}
+	var err error
+	if t.args, err = parseArgTemplates("agent.args", args); err != nil {
+		return nil, err
+	}
+	if len(interactiveArgs) == 0 {
+		t.interactive = t.args
+	} else if t.interactive, err = parseArgTemplates("agent.interactive_args", interactiveArgs); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func parseArgTemplates(key string, args []string) ([]*template.Template, error) {
+	tmpls := make([]*template.Template, len(args))
+	for i, a := range args {
+		t, err := template.New(key).Option("missingkey=error").Parse(a)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+		// Execute once so unknown fields fail at startup, not mid-run.
+		if err := t.Execute(&bytes.Buffer{}, TemplateData{}); err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+		tmpls[i] = t
+	}
+	return tmpls, nil
+}
+
+func (t *Template) Name() string   { return BackendCommand }
+func (t *Template) Binary() string { return t.command }
+
+func (t *Template) HeadlessArgs(model, prompt string) []string {
+	return renderArgs(t.args, TemplateData{Model: model, Prompt: prompt})
+}
+
+func (t *Template) InteractiveArgs(model, prompt string) []string {
+	return renderArgs(t.interactive, TemplateData{Model: model, Prompt: prompt})
+}
+
+func renderArgs(tmpls []*template.Template, data TemplateData) []string {
+	var args []string
+	for _, t := range tmpls {
+		var b strings.Builder
+		// Templates were validated by NewTemplate against the same data type.
+		_ = t.Execute(&b, data)
+		if b.Len() > 0 {
+			args = append(args, b.String())
+		}
+	}
+	return args
+}
+
+// NewParser accepts either Cursor- or Claude-style result events, so tools
+// that mimic one of them get chat IDs and errors; plain output yields none.
+func (t *Template) NewParser() StreamParser {
+	return &resultEventParser{extract: func(event map[string]any, r *Result) {
+		cursorResult(event, r)
+		if r.ChatID == "" || r.ErrorMessage == "" {
+			var c Result
+			claudeResult(event, &c)
+			r.ChatID = orDefault(r.ChatID, c.ChatID)
+			r.ErrorMessage = orDefault(r.ErrorMessage, c.ErrorMessage)
+		}
+	}}
+}
+
+// resultEventParser scans JSON lines for {"type":"result",...} events and
+// hands each to extract. Usage is collected from every event. Non-JSON
+// lines are ignored.
+type resultEventParser struct {
+	extract func(event map[string]any, r *Result)
+	result  Result
+	usage   usageParser
+}
+
+func (p *resultEventParser) ParseLine(line string) {
+	if line == "" {
+		return
+	}
+	var event map[string]any
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return
+	}
+	p.usage.parseEvent(event)
+	if eventType, _ := event["type"].(string); eventType != "result" {
+		return
+	}
+	p.extract(event, &p.result)
+}
+
+func (p *resultEventParser) Result() Result {
+	r := p.result
+	r.Usage = p.usage.usage()
+	return r
+}
+
+// Compile-time interface compliance checks
+var (
+	_ Backend = Cursor{}
+	_ Backend = Claude{}
+	_ Backend = Aider{}
+	_ Backend = (*Template)(nil)
+)
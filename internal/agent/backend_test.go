@@ -0,0 +1,252 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"devdeploy/internal/config"
+)
+
+func TestFromConfig(t *testing.T) {
+	tests := []struct {
+		cfg        config.AgentConfig
+		wantName   string
+		wantBinary string
+	}{
+		{config.AgentConfig{}, BackendCursor, "agent"},
+		{config.AgentConfig{Backend: "cursor", Command: "cursor-agent"}, BackendCursor, "cursor-agent"},
+		{config.AgentConfig{Backend: "claude"}, BackendClaude, "claude"},
+		{config.AgentConfig{Backend: "aider"}, BackendAider, "aider"},
+		{config.AgentConfig{Backend: "command", Command: "mytool", Args: []string{"{{.Prompt}}"}}, BackendCommand, "mytool"},
+	}
+	for _, tt := range tests {
+		b, err := FromConfig(tt.cfg)
+		if err != nil {
+			t.Fatalf("FromConfig(%+v): %v", tt.cfg, err)
+		}
+		if b.Name() != tt.wantName || b.Binary() != tt.wantBinary {
+			t.Errorf("FromConfig(%+v) = %s/%s, want %s/%s", tt.cfg, b.Name(), b.Binary(), tt.wantName, tt.wantBinary)
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	cfg := config.AgentConfig{Backend: "claude", Command: "/opt/claude"}
+	tests := []struct {
+		name       string
+		wantBinary string
+	}{
+		{"claude", "/opt/claude"},
+		{"cursor", "agent"},
+		{"", "agent"},
+		{"aider", "aider"},
+	}
+	for _, tt := range tests {
+		b, err := ByName(cfg, tt.name)
+		if err != nil {
+			t.Fatalf("ByName(%q): %v", tt.name, err)
+		}
+		if b.Binary() != tt.wantBinary {
+			t.Errorf("ByName(%q) binary = %s, want %s", tt.name, b.Binary(), tt.wantBinary)
+		}
+	}
+	if _, err := ByName(cfg, "command"); err == nil {
+		t.Error("ByName(command) without a configured command: want error")
+	}
+}
+
+func TestFromConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.AgentConfig
+		want string
+	}{
+		{"unknown backend", config.AgentConfig{Backend: "vim"}, "unknown agent.backend"},
+		{"command without binary", config.AgentConfig{Backend: "command", Args: []string{"x"}}, "agent.command"},
+		{"command without args", config.AgentConfig{Backend: "command", Command: "x"}, "agent.args"},
+		{"bad template", config.AgentConfig{Backend: "command", Command: "x", Args: []string{"{{.Prompt"}}, "agent.args[0]"},
+		{"unknown field", config.AgentConfig{Backend: "command", Command: "x", Args: []string{"{{.Nope}}"}}, "agent.args[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromConfig(tt.cfg)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackendArgs(t *testing.T) {
+	tmpl, err := NewTemplate("mytool",
+		[]string{"run", "{{if .Model}}--model={{.Model}}{{end}}", "{{.Prompt}}"},
+		[]string{"chat"})
+	if err != nil {
+		t.Fatalf("NewTemplate: %v", err)
+	}
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"cursor headless", Cursor{}.HeadlessArgs("m", "p"), []string{"--model", "m", "--print", "--force", "--output-format", "stream-json", "p"}},
+		{"cursor interactive", Cursor{}.InteractiveArgs("", ""), []string{"--force"}},
+		{"claude headless", Claude{}.HeadlessArgs("", "p"), []string{"--print", "--output-format", "stream-json", "--verbose", "--dangerously-skip-permissions", "p"}},
+		{"aider interactive", Aider{}.InteractiveArgs("m", "p"), []string{"--model", "m", "--yes-always", "--message", "p"}},
+		{"template headless", tmpl.HeadlessArgs("m", "do it"), []string{"run", "--model=m", "do it"}},
+		{"template no model", tmpl.HeadlessArgs("", "do it"), []string{"run", "do it"}},
+		{"template interactive", tmpl.InteractiveArgs("m", "p"), []string{"chat"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestCommandLine_QuotesWords(t *testing.T) {
+	got := CommandLine(Cursor{Command: "/opt/my agent"}, []string{"it's", "$HOME"})
+	want := `'/opt/my agent' 'it'\''s' '$HOME'`
+	if got != want {
+		t.Errorf("CommandLine = %q, want %q", got, want)
+	}
+}
+
+func TestClaudeParser(t *testing.T) {
+	stdout := `{"type":"system","subtype":"init","session_id":"s-1"}
+{"type":"assistant","message":{"content":[]}}
+{"type":"result","subtype":"error_max_turns","is_error":true,"session_id":"s-1"}
+`
+	r := ParseOutput(Claude{}.NewParser(), stdout)
+	if r.ChatID != "s-1" {
+		t.Errorf("chatID = %q, want s-1", r.ChatID)
+	}
+	if r.ErrorMessage != "error_max_turns" {
+		t.Errorf("errorMsg = %q, want error_max_turns", r.ErrorMessage)
+	}
+
+	ok := ParseOutput(Claude{}.NewParser(), `{"type":"result","is_error":false,"result":"done","session_id":"s-2"}`)
+	if ok.ChatID != "s-2" || ok.ErrorMessage != "" || ok.Text != "done" {
+		t.Errorf("success result = %+v, want chat s-2 with text and without error", ok)
+	}
+}
+
+func TestTemplateParser_AcceptsEitherFormat(t *testing.T) {
+	tmpl, err := NewTemplate("x", []string{"{{.Prompt}}"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := ParseOutput(tmpl.NewParser(), `{"type":"result","session_id":"s-3","is_error":true,"result":"boom"}`)
+	if r.ChatID != "s-3" || r.ErrorMessage != "boom" {
+		t.Errorf("claude-style result = %+v", r)
+	}
+	r = ParseOutput(tmpl.NewParser(), `{"type":"result","chatId":"c-1"}`)
+	if r.ChatID != "c-1" || r.ErrorMessage != "" {
+		t.Errorf("cursor-style result = %+v", r)
+	}
+	if r := ParseOutput(tmpl.NewParser(), "plain text\n"); r != (Result{}) {
+		t.Errorf("plain output result = %+v, want empty", r)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Cursor result parsing tests
+// ---------------------------------------------------------------------------
+
+func TestCursorParser_ChatID(t *testing.T) {
+	stdout := `{"type":"system","content":"system prompt"}
+{"type":"tool_call","subtype":"started","name":"read","arguments":{"path":"foo.go"}}
+{"type":"result","chatId":"chat-abc123","duration_ms":5000}
+`
+	r := ParseOutput(Cursor{}.NewParser(), stdout)
+	chatID, errMsg := r.ChatID, r.ErrorMessage
+	if chatID != "chat-abc123" {
+		t.Errorf("chatID = %q, want %q", chatID, "chat-abc123")
+	}
+	if errMsg != "" {
+		t.Errorf("errorMsg = %q, want empty", errMsg)
+	}
+}
+
+func TestCursorParser_ChatIDSnakeCase(t *testing.T) {
+	stdout := `{"type":"result","chat_id":"chat-xyz789","duration_ms":3000}
+`
+	r := ParseOutput(Cursor{}.NewParser(), stdout)
+	chatID, errMsg := r.ChatID, r.ErrorMessage
+	if chatID != "chat-xyz789" {
+		t.Errorf("chatID = %q, want %q", chatID, "chat-xyz789")
+	}
+	if errMsg != "" {
+		t.Errorf("errorMsg = %q, want empty", errMsg)
+	}
+}
+
+func TestCursorParser_ErrorString(t *testing.T) {
+	stdout := `{"type":"result","chatId":"chat-err001","error":"Agent crashed unexpectedly"}
+`
+	r := ParseOutput(Cursor{}.NewParser(), stdout)
+	chatID, errMsg := r.ChatID, r.ErrorMessage
+	if chatID != "chat-err001" {
+		t.Errorf("chatID = %q, want %q", chatID, "chat-err001")
+	}
+	if errMsg != "Agent crashed unexpectedly" {
+		t.Errorf("errorMsg = %q, want %q", errMsg, "Agent crashed unexpectedly")
+	}
+}
+
+func TestCursorParser_ErrorObject(t *testing.T) {
+	stdout := `{"type":"result","chatId":"chat-err002","error":{"message":"Detailed error message","code":500}}
+`
+	r := ParseOutput(Cursor{}.NewParser(), stdout)
+	chatID, errMsg := r.ChatID, r.ErrorMessage
+	if chatID != "chat-err002" {
+		t.Errorf("chatID = %q, want %q", chatID, "chat-err002")
+	}
+	if errMsg != "Detailed error message" {
+		t.Errorf("errorMsg = %q, want %q", errMsg, "Detailed error message")
+	}
+}
+
+func TestCursorParser_NoResult(t *testing.T) {
+	stdout := `{"type":"system","content":"system prompt"}
+{"type":"tool_call","name":"read"}
+`
+	r := ParseOutput(Cursor{}.NewParser(), stdout)
+	chatID, errMsg := r.ChatID, r.ErrorMessage
+	if chatID != "" {
+		t.Errorf("chatID = %q, want empty", chatID)
+	}
+	if errMsg != "" {
+		t.Errorf("errorMsg = %q, want empty", errMsg)
+	}
+}
+
+func TestCursorParser_EmptyStdout(t *testing.T) {
+	r := ParseOutput(Cursor{}.NewParser(), "")
+	chatID, errMsg := r.ChatID, r.ErrorMessage
+	if chatID != "" {
+		t.Errorf("chatID = %q, want empty", chatID)
+	}
+	if errMsg != "" {
+		t.Errorf("errorMsg = %q, want empty", errMsg)
+	}
+}
+
+func TestCursorParser_InvalidJSON(t *testing.T) {
+	stdout := `not json
+{"type":"result","chatId":"chat-123"}
+more garbage`
+	r := ParseOutput(Cursor{}.NewParser(), stdout)
+	chatID, errMsg := r.ChatID, r.ErrorMessage
+	// Should still extract from the valid line
+	if chatID != "chat-123" {
+		t.Errorf("chatID = %q, want %q", chatID, "chat-123")
+	}
+	if errMsg != "" {
+		t.Errorf("errorMsg = %q, want empty", errMsg)
+	}
+}
@@ -0,0 +1,232 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// ToolCall is a tool invocation parsed from an agent's stream-json output.
+type ToolCall struct {
+	// ID pairs the start of a call with its end.
+	ID string
+	// Name is normalized across CLIs where possible: read, write, edit,
+	// delete, shell, grep, search, ls. Other tools keep their own name,
+	// lower-cased.
+	Name string
+	// Attrs holds the interesting arguments: file_path, command, pattern,
+	// query.
+	Attrs map[string]string
+}
+
+// ToolObserver receives tool calls while an agent is running.
+type ToolObserver interface {
+	ToolStarted(call ToolCall)
+	// ToolEnded reports a finished call. attrs has "status" (ok or error)
+	// when the CLI reports it.
+	ToolEnded(id string, attrs map[string]string)
+}
+
+// ToolStream is an io.Writer that parses an agent's stream-json output as
+// it is written and reports tool calls to an observer. It understands the
+// Cursor tool_call events and the Claude tool_use / tool_result content
+// blocks; anything else, including non-JSON output, is ignored.
+type ToolStream struct {
+	mu   sync.Mutex
+	obs  ToolObserver
+	buf  []byte
+	open []string // started, not yet ended call IDs, in start order
+}
+
+// NewToolStream returns a ToolStream reporting to obs.
+func NewToolStream(obs ToolObserver) *ToolStream {
+	return &ToolStream{obs: obs}
+}
+
+// Write buffers p and parses every complete line.
+func (s *ToolStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(s.buf, p...)
+	for {
+		i := bytes.IndexByte(s.buf, '\n')
+		if i < 0 {
+			break
+		}
+		s.parseLine(s.buf[:i])
+		s.buf = s.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close parses a trailing partial line and ends calls that never finished,
+// e.g. because the agent was killed.
+func (s *ToolStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buf) > 0 {
+		s.parseLine(s.buf)
+		s.buf = nil
+	}
+	s.endAll(map[string]string{"status": "unfinished"})
+	return nil
+}
+
+func (s *ToolStream) parseLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return
+	}
+	var event struct {
+		Type     string                    `json:"type"`
+		Subtype  string                    `json:"subtype"`
+		CallID   string                    `json:"call_id"`
+		ToolCall map[string]map[string]any `json:"tool_call"`
+		Message  struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(line, &event); err != nil {
+		return
+	}
+
+	switch event.Type {
+	case "tool_call":
+		// Cursor: {"type":"tool_call","subtype":"started","call_id":"...",
+		//          "tool_call":{"shellToolCall":{"args":{"command":"..."}}}}
+		for key, call := range event.ToolCall {
+			switch event.Subtype {
+			case "started":
+				args, _ := call["args"].(map[string]any)
+				if key == "function" {
+					// Generic tools: {"function":{"name":"...","arguments":"{...}"}}
+					key, _ = call["name"].(string)
+					if raw, ok := call["arguments"].(string); ok {
+						_ = json.Unmarshal([]byte(raw), &args)
+					}
+				}
+				s.start(ToolCall{ID: event.CallID, Name: toolName(strings.TrimSuffix(key, "ToolCall")), Attrs: toolAttrs(args)})
+			case "completed":
+				attrs := map[string]string{}
+				if result, ok := call["result"].(map[string]any); ok {
+					if _, failed := result["error"]; failed {
+						attrs["status"] = "error"
+					} else {
+						attrs["status"] = "ok"
+					}
+				}
+				s.end(event.CallID, attrs)
+			}
+		}
+	case "assistant", "user":
+		// Claude: tool_use blocks in assistant messages, tool_result blocks
+		// in the following user message.
+		var blocks []struct {
+			Type      string         `json:"type"`
+			ID        string         `json:"id"`
+			Name      string         `json:"name"`
+			Input     map[string]any `json:"input"`
+			ToolUseID string         `json:"tool_use_id"`
+			IsError   bool           `json:"is_error"`
+		}
+		if json.Unmarshal(event.Message.Content, &blocks) != nil {
+			return // plain string content
+		}
+		for _, b := range blocks {
+			switch b.Type {
+			case "tool_use":
+				s.start(ToolCall{ID: b.ID, Name: toolName(b.Name), Attrs: toolAttrs(b.Input)})
+			case "tool_result":
+				status := "ok"
+				if b.IsError {
+					status = "error"
+				}
+				s.end(b.ToolUseID, map[string]string{"status": status})
+			}
+		}
+	case "result":
+		s.endAll(nil)
+	}
+}
+
+func (s *ToolStream) start(call ToolCall) {
+	if call.ID == "" {
+		return
+	}
+	s.open = append(s.open, call.ID)
+	s.obs.ToolStarted(call)
+}
+
+func (s *ToolStream) end(id string, attrs map[string]string) {
+	for i, openID := range s.open {
+		if openID == id {
+			s.open = append(s.open[:i], s.open[i+1:]...)
+			s.obs.ToolEnded(id, attrs)
+			return
+		}
+	}
+}
+
+func (s *ToolStream) endAll(attrs map[string]string) {
+	for _, id := range s.open {
+		s.obs.ToolEnded(id, attrs)
+	}
+	s.open = nil
+}
+
+// toolNames maps CLI tool names (lower-cased, Cursor's without the
+// "ToolCall" suffix) to the names the trace view knows.
+var toolNames = map[string]string{
+	"read":       "read",
+	"write":      "write",
+	"edit":       "edit",
+	"multiedit":  "edit",
+	"strreplace": "edit",
+	"delete":     "delete",
+	"bash":       "shell",
+	"shell":      "shell",
+	"grep":       "grep",
+	"glob":       "search",
+	"semsearch":  "search",
+	"websearch":  "search",
+	"ls":         "ls",
+}
+
+func toolName(name string) string {
+	name = strings.ToLower(name)
+	if n, ok := toolNames[name]; ok {
+		return n
+	}
+	if name == "" {
+		return "tool"
+	}
+	return name
+}
+
+// toolAttrKeys maps argument names to trace attributes; the first present
+// argument wins.
+var toolAttrKeys = []struct{ attr, arg string }{
+	{"file_path", "file_path"},
+	{"file_path", "path"},
+	{"file_path", "target_file"},
+	{"file_path", "targetFile"},
+	{"command", "command"},
+	{"pattern", "pattern"},
+	{"pattern", "glob_pattern"},
+	{"pattern", "globPattern"},
+	{"query", "query"},
+}
+
+func toolAttrs(args map[string]any) map[string]string {
+	attrs := make(map[string]string)
+	for _, k := range toolAttrKeys {
+		if _, done := attrs[k.attr]; done {
+			continue
+		}
+		if v, ok := args[k.arg].(string); ok && v != "" {
+			attrs[k.attr] = v
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingToolObserver logs tool events as strings.
+type recordingToolObserver struct {
+	events []string
+}
+
+func (r *recordingToolObserver) ToolStarted(call ToolCall) {
+	r.events = append(r.events, fmt.Sprintf("start %s %s %v", call.ID, call.Name, call.Attrs))
+}
+
+func (r *recordingToolObserver) ToolEnded(id string, attrs map[string]string) {
+	r.events = append(r.events, fmt.Sprintf("end %s %v", id, attrs))
+}
+
+func TestToolStream_Cursor(t *testing.T) {
+	stdout := `{"type":"system","subtype":"init"}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Let me look."}]}}
+{"type":"tool_call","subtype":"started","call_id":"c1","tool_call":{"readToolCall":{"args":{"path":"/repo/main.go"}}}}
+{"type":"tool_call","subtype":"completed","call_id":"c1","tool_call":{"readToolCall":{"args":{"path":"/repo/main.go"},"result":{"success":{"content":"package main"}}}}}
+{"type":"tool_call","subtype":"started","call_id":"c2","tool_call":{"shellToolCall":{"args":{"command":"go test ./..."}}}}
+{"type":"tool_call","subtype":"completed","call_id":"c2","tool_call":{"shellToolCall":{"result":{"error":{"message":"exit 1"}}}}}
+{"type":"tool_call","subtype":"started","call_id":"c3","tool_call":{"function":{"name":"Custom","arguments":"{\"query\":\"widgets\"}"}}}
+{"type":"result","chatId":"chat-1"}
+`
+	obs := &recordingToolObserver{}
+	s := NewToolStream(obs)
+	// Feed in awkward chunks to exercise line buffering.
+	for len(stdout) > 0 {
+		n := min(17, len(stdout))
+		if _, err := s.Write([]byte(stdout[:n])); err != nil {
+			t.Fatal(err)
+		}
+		stdout = stdout[n:]
+	}
+	_ = s.Close()
+
+	want := []string{
+		"start c1 read map[file_path:/repo/main.go]",
+		"end c1 map[status:ok]",
+		"start c2 shell map[command:go test ./...]",
+		"end c2 map[status:error]",
+		"start c3 custom map[query:widgets]",
+		"end c3 map[]",
+	}
+	if !reflect.DeepEqual(obs.events, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(obs.events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestToolStream_Claude(t *testing.T) {
+	stdout := `{"type":"assistant","message":{"content":[{"type":"text","text":"Editing."},{"type":"tool_use","id":"t1","name":"Edit","input":{"file_path":"a.go","old_string":"x"}},{"type":"tool_use","id":"t2","name":"Grep","input":{"pattern":"TODO"}}]}}
+{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"no match"}]}}
+{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t3","name":"Bash","input":{"command":"make"}}]}}
+not json
+`
+	obs := &recordingToolObserver{}
+	s := NewToolStream(obs)
+	if _, err := s.Write([]byte(stdout)); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close() // agent killed: t2 and t3 never got results
+
+	want := []string{
+		"start t1 edit map[file_path:a.go]",
+		"start t2 grep map[pattern:TODO]",
+		"end t1 map[status:error]",
+		"start t3 shell map[command:make]",
+		"end t2 map[status:unfinished]",
+		"end t3 map[status:unfinished]",
+	}
+	if !reflect.DeepEqual(obs.events, want) {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(obs.events, "\n"), strings.Join(want, "\n"))
+	}
+}
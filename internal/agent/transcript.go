@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormatTranscript renders an agent's stream-json output as a readable
+// conversation: assistant and user text, tool calls (→) and their results
+// (←), and the final result with its usage. It understands the Cursor and
+// Claude event formats; lines that are not JSON are copied as they are.
+func FormatTranscript(w io.Writer, r io.Reader) error {
+	f := &transcriptFormatter{w: w}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		f.line(sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return f.err
+}
+
+type transcriptFormatter struct {
+	w    io.Writer
+	role string // speaker of the last printed text, to avoid repeated headers
+	err  error
+}
+
+func (f *transcriptFormatter) printf(format string, args ...any) {
+	if f.err == nil {
+		_, f.err = fmt.Fprintf(f.w, format, args...)
+	}
+}
+
+// say prints text under a role header, printing the header only when the
+// speaker changes.
+func (f *transcriptFormatter) say(role, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if f.role != role {
+		f.printf("\n%s:\n", role)
+		f.role = role
+	}
+	f.printf("%s\n", text)
+}
+
+func (f *transcriptFormatter) tool(name string, args map[string]any) {
+	f.role = ""
+	f.printf("  → %s%s\n", toolName(name), formatAttrs(toolAttrs(args)))
+}
+
+func (f *transcriptFormatter) toolResult(status, detail string) {
+	f.role = ""
+	if detail != "" {
+		detail = ": " + firstLine(detail)
+	}
+	f.printf("  ← %s%s\n", status, detail)
+}
+
+func (f *transcriptFormatter) line(line string) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return
+	}
+	var event map[string]any
+	if trimmed[0] != '{' || json.Unmarshal([]byte(trimmed), &event) != nil {
+		f.role = ""
+		f.printf("%s\n", line)
+		return
+	}
+
+	switch event["type"] {
+	case "system":
+		if event["subtype"] == "init" {
+			model, _ := event["model"].(string)
+			f.printf("── session started%s\n", orEmpty(" (model %s)", model))
+		}
+	case "assistant", "user":
+		role := "Assistant"
+		if event["type"] == "user" {
+			role = "User"
+		}
+		msg, _ := event["message"].(map[string]any)
+		switch content := msg["content"].(type) {
+		case string:
+			f.say(role, content)
+		case []any:
+			for _, c := range content {
+				block, _ := c.(map[string]any)
+				switch block["type"] {
+				case "text":
+					text, _ := block["text"].(string)
+					f.say(role, text)
+				case "tool_use":
+					name, _ := block["name"].(string)
+					input, _ := block["input"].(map[string]any)
+					f.tool(name, input)
+				case "tool_result":
+					if isErr, _ := block["is_error"].(bool); isErr {
+						f.toolResult("error", contentText(block["content"]))
+					} else {
+						f.toolResult("ok", "")
+					}
+				}
+			}
+		}
+	case "tool_call":
+		calls, _ := event["tool_call"].(map[string]any)
+		for key, c := range calls {
+			call, _ := c.(map[string]any)
+			switch event["subtype"] {
+			case "started":
+				args, _ := call["args"].(map[string]any)
+				if key == "function" {
+					key, _ = call["name"].(string)
+					if raw, ok := call["arguments"].(string); ok {
+						_ = json.Unmarshal([]byte(raw), &args)
+					}
+				}
+				f.tool(strings.TrimSuffix(key, "ToolCall"), args)
+			case "completed":
+				result, _ := call["result"].(map[string]any)
+				if e, failed := result["error"]; failed {
+					detail, _ := e.(map[string]any)["message"].(string)
+					f.toolResult("error", detail)
+				} else {
+					f.toolResult("ok", "")
+				}
+			}
+		}
+	case "result":
+		var p usageParser
+		p.parseEvent(event)
+		f.role = ""
+		f.printf("\n── result: %s\n", resultSummary(event, p.usage()))
+	}
+}
+
+// resultSummary describes a result event: its outcome, duration and usage.
+func resultSummary(event map[string]any, u Usage) string {
+	parts := []string{}
+	subtype, _ := event["subtype"].(string)
+	if isErr, _ := event["is_error"].(bool); isErr {
+		parts = append(parts, "error")
+	} else if subtype != "" {
+		parts = append(parts, subtype)
+	} else {
+		parts = append(parts, "done")
+	}
+	if ms := firstNumber(event, "duration_ms", "durationMs"); ms > 0 {
+		parts = append(parts, (time.Duration(ms) * time.Millisecond).Round(100*time.Millisecond).String())
+	}
+	if n := firstNumber(event, "num_turns"); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d turns", int(n)))
+	}
+	if u.Tokens() > 0 {
+		parts = append(parts, fmt.Sprintf("%d in / %d out tokens", u.InputTokens, u.OutputTokens))
+	}
+	if u.CostUSD > 0 {
+		parts = append(parts, fmt.Sprintf("$%.4f", u.CostUSD))
+	}
+	summary := strings.Join(parts, ", ")
+	if text, ok := event["result"].(string); ok && strings.TrimSpace(text) != "" {
+		summary += "\n" + strings.TrimSpace(text)
+	}
+	return summary
+}
+
+// formatAttrs renders tool attributes as " key=value ..." in key order.
+func formatAttrs(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, firstLine(attrs[k]))
+	}
+	return b.String()
+}
+
+// contentText returns the text of a tool_result content, which is either
+// a string or a list of text blocks.
+func contentText(content any) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []any:
+		for _, b := range c {
+			if block, ok := b.(map[string]any); ok {
+				if text, ok := block["text"].(string); ok {
+					return text
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// firstLine returns the first line of s, marking anything cut off.
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
+
+func orEmpty(format, s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, s)
+}
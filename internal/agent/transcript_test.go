@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatTranscript_Claude(t *testing.T) {
+	events := []string{
+		`{"type":"system","subtype":"init","model":"claude-sonnet"}`,
+		`{"type":"user","message":{"content":"Fix the build."}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Looking."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go build ./..."}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":[{"type":"text","text":"main.go:3: undefined: x\nmore"}]}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"main.go"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t2","content":"ok"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Fixed."}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Closing the bead."}]}}`,
+		`not json`,
+		`{"type":"result","subtype":"success","duration_ms":61500,"num_turns":3,"result":"Done.","total_cost_usd":0.5,"usage":{"input_tokens":100,"output_tokens":40}}`,
+	}
+	var b strings.Builder
+	if err := FormatTranscript(&b, strings.NewReader(strings.Join(events, "\n"))); err != nil {
+		t.Fatalf("FormatTranscript: %v", err)
+	}
+	want := `── session started (model claude-sonnet)
+
+User:
+Fix the build.
+
+Assistant:
+Looking.
+  → shell command=go build ./...
+  ← error: main.go:3: undefined: x …
+  → edit file_path=main.go
+  ← ok
+
+Assistant:
+Fixed.
+Closing the bead.
+not json
+
+── result: success, 1m1.5s, 3 turns, 100 in / 40 out tokens, $0.5000
+Done.
+`
+	if b.String() != want {
+		t.Errorf("transcript:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestFormatTranscript_Cursor(t *testing.T) {
+	stdout := `{"type":"system","subtype":"init","model":"composer-1"}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Let me look."}]}}
+{"type":"tool_call","subtype":"started","call_id":"c1","tool_call":{"readToolCall":{"args":{"path":"/repo/main.go"}}}}
+{"type":"tool_call","subtype":"completed","call_id":"c1","tool_call":{"readToolCall":{"result":{"success":{}}}}}
+{"type":"tool_call","subtype":"started","call_id":"c2","tool_call":{"shellToolCall":{"args":{"command":"make"}}}}
+{"type":"tool_call","subtype":"completed","call_id":"c2","tool_call":{"shellToolCall":{"result":{"error":{"message":"exit 2"}}}}}
+{"type":"result","chatId":"chat-1","is_error":true}
+`
+	var b strings.Builder
+	if err := FormatTranscript(&b, strings.NewReader(stdout)); err != nil {
+		t.Fatalf("FormatTranscript: %v", err)
+	}
+	want := `── session started (model composer-1)
+
+Assistant:
+Let me look.
+  → read file_path=/repo/main.go
+  ← ok
+  → shell command=make
+  ← error: exit 2
+
+── result: error
+`
+	if b.String() != want {
+		t.Errorf("transcript:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,149 @@
+package agent
+
+import "devdeploy/internal/config"
+
+// Usage is the token usage and cost of one or more agent runs.
+type Usage struct {
+	// Model is the model the CLI reported, or the one it was started with.
+	Model            string  `json:"model,omitempty"`
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CacheReadTokens  int64   `json:"cache_read_tokens"`
+	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Add returns the sum of u and o. The model is kept when both agree and
+// becomes "mixed" otherwise.
+func (u Usage) Add(o Usage) Usage {
+	switch {
+	case u.Model == "":
+		u.Model = o.Model
+	case o.Model != "" && o.Model != u.Model:
+		u.Model = "mixed"
+	}
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.CacheWriteTokens += o.CacheWriteTokens
+	u.CostUSD += o.CostUSD
+	return u
+}
+
+// Tokens returns the total number of tokens.
+func (u Usage) Tokens() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
+}
+
+// Priced fills in CostUSD from prices when the CLI did not report a cost.
+// Usage of a model without a price keeps a zero cost.
+func (u Usage) Priced(prices map[string]config.ModelPrice) Usage {
+	if u.CostUSD > 0 {
+		return u
+	}
+	p, ok := prices[u.Model]
+	if !ok {
+		return u
+	}
+	u.CostUSD = (float64(u.InputTokens)*p.Input +
+		float64(u.OutputTokens)*p.Output +
+		float64(u.CacheReadTokens)*p.CacheRead +
+		float64(u.CacheWriteTokens)*p.CacheWrite) / 1e6
+	return u
+}
+
+// usageParser collects usage from stream-json events. A result event
+// carries the run's totals and wins; otherwise per-message usage is summed.
+type usageParser struct {
+	model    string
+	streamed Usage
+	final    *Usage
+	seen     map[string]bool // message IDs already counted
+}
+
+// parseEvent picks usage out of any stream-json event:
+//
+//	{"type":"system","subtype":"init","model":"..."}
+//	{"type":"assistant","message":{"id":"...","model":"...","usage":{...}}}
+//	{"type":"usage","usage":{...}}
+//	{"type":"result","usage":{...},"total_cost_usd":0.12}
+//
+// Token counts are accepted in snake_case (Claude) and camelCase (Cursor).
+func (p *usageParser) parseEvent(event map[string]any) {
+	if model, ok := event["model"].(string); ok && model != "" {
+		p.model = model
+	}
+	switch event["type"] {
+	case "assistant":
+		msg, _ := event["message"].(map[string]any)
+		if model, ok := msg["model"].(string); ok && model != "" {
+			p.model = model
+		}
+		usage, ok := msg["usage"].(map[string]any)
+		if !ok {
+			return
+		}
+		// Claude repeats a message's usage on each of its content events.
+		if id, _ := msg["id"].(string); id != "" {
+			if p.seen[id] {
+				return
+			}
+			if p.seen == nil {
+				p.seen = make(map[string]bool)
+			}
+			p.seen[id] = true
+		}
+		p.streamed = p.streamed.Add(usageFromMap(usage))
+	case "usage":
+		usage, ok := event["usage"].(map[string]any)
+		if !ok {
+			usage = event
+		}
+		p.streamed = p.streamed.Add(usageFromMap(usage))
+	case "result":
+		usage, ok := event["usage"].(map[string]any)
+		if !ok {
+			return
+		}
+		u := usageFromMap(usage)
+		u.CostUSD = firstNumber(event, "total_cost_usd", "cost_usd", "costUSD")
+		if models, ok := event["modelUsage"].(map[string]any); ok && len(models) == 1 {
+			for model := range models {
+				u.Model = model
+			}
+		}
+		p.final = &u
+	}
+}
+
+// usage returns the collected usage.
+func (p *usageParser) usage() Usage {
+	u := p.streamed
+	if p.final != nil {
+		u = *p.final
+	}
+	if u.Model == "" {
+		u.Model = p.model
+	}
+	return u
+}
+
+func usageFromMap(m map[string]any) Usage {
+	return Usage{
+		InputTokens:      int64(firstNumber(m, "input_tokens", "inputTokens")),
+		OutputTokens:     int64(firstNumber(m, "output_tokens", "outputTokens")),
+		CacheReadTokens:  int64(firstNumber(m, "cache_read_input_tokens", "cache_read_tokens", "cacheReadTokens")),
+		CacheWriteTokens: int64(firstNumber(m, "cache_creation_input_tokens", "cache_write_tokens", "cacheWriteTokens")),
+		CostUSD:          firstNumber(m, "cost_usd", "costUSD"),
+	}
+}
+
+// firstNumber returns the first of keys that holds a number in m.
+func firstNumber(m map[string]any, keys ...string) float64 {
+	for _, k := range keys {
+		if v, ok := m[k].(float64); ok {
+			return v
+		}
+	}
+	return 0
+}
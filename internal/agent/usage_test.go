@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"devdeploy/internal/config"
+)
+
+func TestParseOutput_ClaudeUsage(t *testing.T) {
+	events := []string{
+		`{"type":"system","subtype":"init","model":"claude-sonnet","session_id":"s-1"}`,
+		`{"type":"assistant","message":{"id":"m1","model":"claude-sonnet","content":[{"type":"text","text":"a"}],"usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"type":"assistant","message":{"id":"m1","model":"claude-sonnet","content":[{"type":"tool_use","id":"t1","name":"Bash"}],"usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"type":"result","is_error":false,"result":"done","session_id":"s-1","total_cost_usd":0.25,"usage":{"input_tokens":100,"output_tokens":40,"cache_read_input_tokens":1000,"cache_creation_input_tokens":200}}`,
+	}
+	got := ParseOutput(Claude{}.NewParser(), strings.Join(events, "\n")).Usage
+	want := Usage{Model: "claude-sonnet", InputTokens: 100, OutputTokens: 40, CacheReadTokens: 1000, CacheWriteTokens: 200, CostUSD: 0.25}
+	if got != want {
+		t.Errorf("usage = %+v, want %+v", got, want)
+	}
+
+	// Killed before the result event: per-message usage, each message once.
+	got = ParseOutput(Claude{}.NewParser(), strings.Join(events[:3], "\n")).Usage
+	if got.InputTokens != 10 || got.OutputTokens != 5 || got.Model != "claude-sonnet" {
+		t.Errorf("streamed usage = %+v, want 10 in / 5 out", got)
+	}
+}
+
+func TestParseOutput_CursorUsage(t *testing.T) {
+	stdout := `{"type":"system","subtype":"init","model":"composer-1"}
+{"type":"result","chatId":"c-1","usage":{"inputTokens":300,"outputTokens":20,"cacheReadTokens":5000,"cacheWriteTokens":0}}
+`
+	got := ParseOutput(Cursor{}.NewParser(), stdout).Usage
+	want := Usage{Model: "composer-1", InputTokens: 300, OutputTokens: 20, CacheReadTokens: 5000}
+	if got != want {
+		t.Errorf("usage = %+v, want %+v", got, want)
+	}
+}
+
+func TestUsage_AddAndPriced(t *testing.T) {
+	u := Usage{Model: "a", InputTokens: 1_000_000, OutputTokens: 100_000}
+	prices := map[string]config.ModelPrice{"a": {Input: 2, Output: 10}}
+	if got := u.Priced(prices).CostUSD; got != 3 {
+		t.Errorf("priced cost = %v, want 3", got)
+	}
+	if got := (Usage{Model: "a", InputTokens: 5, CostUSD: 0.5}).Priced(prices).CostUSD; got != 0.5 {
+		t.Errorf("reported cost replaced: %v", got)
+	}
+	if got := (Usage{Model: "unknown", InputTokens: 5}).Priced(prices).CostUSD; got != 0 {
+		t.Errorf("unpriced model cost = %v, want 0", got)
+	}
+
+	sum := u.Add(Usage{Model: "a", OutputTokens: 1, CostUSD: 1}).Add(Usage{Model: "b", CacheReadTokens: 2})
+	if sum.Model != "mixed" || sum.InputTokens != 1_000_000 || sum.OutputTokens != 100_001 || sum.CacheReadTokens != 2 || sum.CostUSD != 1 {
+		t.Errorf("sum = %+v", sum)
+	}
+	if (Usage{}).Add(Usage{Model: "b"}).Model != "b" {
+		t.Error("adding to empty usage should keep the other model")
+	}
+}
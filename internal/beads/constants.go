@@ -11,6 +11,16 @@ const (
 const (
 	LabelNeedsHuman = "needs-human"
 	LabelPRPrefix   = "pr:"
+
+	// LabelRalphFailed marks a bead ralph gave up on after its retries;
+	// ralph leaves it alone until a human removes the label.
+	LabelRalphFailed = "ralph-failed"
+
+	// Labels that override ralph's agent settings for one bead, e.g.
+	// "model:claude-4.5-opus-high-thinking", "timeout:30m", "agent:claude".
+	LabelModelPrefix   = "model:"
+	LabelTimeoutPrefix = "timeout:"
+	LabelAgentPrefix   = "agent:"
 )
 
 // DependencyType constants.
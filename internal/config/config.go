@@ -0,0 +1,258 @@
+// Package config loads the global devdeploy configuration from
+// ~/.devdeploy/config.yaml, with environment variable overrides.
+//
+// The global config holds per-user settings (GitHub team, agent CLI and
+// models, cache tuning) so every teammate can run the same binaries.
+// Per-project settings live in the project's own config.yaml (see
+// project.Config) and take precedence where both apply.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PathEnv is the env var override for the config file location.
+	PathEnv = "DEVDEPLOY_CONFIG"
+	// DefaultPath is the config file location under $HOME.
+	DefaultPath = ".devdeploy/config.yaml"
+)
+
+// Env var overrides for individual settings. They win over the file.
+const (
+	ReviewTeamEnv     = "DEVDEPLOY_REVIEW_TEAM"
+	PRCacheTTLEnv     = "DEVDEPLOY_PR_CACHE_TTL"
+	MergedPRMaxAgeEnv = "DEVDEPLOY_MERGED_PR_MAX_AGE"
+	AgentBackendEnv   = "DEVDEPLOY_AGENT_BACKEND"
+	AgentCommandEnv   = "DEVDEPLOY_AGENT_COMMAND"
+	AgentModelEnv     = "DEVDEPLOY_AGENT_MODEL"
+	LoopModelEnv      = "DEVDEPLOY_LOOP_MODEL"
+	ReviewModelEnv    = "DEVDEPLOY_REVIEW_MODEL"
+)
+
+// Built-in defaults, used for any setting left unset. The model defaults
+// are Cursor model names and only apply to the cursor backend; other
+// backends use their CLI's default model unless one is configured.
+const (
+	DefaultReviewTeam     = "adaptive-telemetry"
+	DefaultPRCacheTTL     = 45 * time.Second
+	DefaultMergedPRMaxAge = 20 * time.Hour
+	DefaultAgentBackend   = "cursor"
+	DefaultAgentModel     = "claude-4.5-opus-high-thinking"
+	DefaultLoopModel      = "composer-1"
+	DefaultReviewModel    = "claude-4.5-opus-high-thinking"
+)
+
+// Config is the global devdeploy configuration.
+type Config struct {
+	// ReviewTeam is the GitHub team slug whose review requests are listed
+	// alongside the user's own PRs. A project's review_team overrides it.
+	ReviewTeam string `yaml:"review_team"`
+
+	// PRCacheTTL is how long cached gh pr list results remain valid.
+	PRCacheTTL time.Duration `yaml:"pr_cache_ttl"`
+
+	// MergedPRMaxAge is the maximum age of merged PRs shown in project detail.
+	MergedPRMaxAge time.Duration `yaml:"merged_pr_max_age"`
+
+	// Agent configures the agent CLI used by devdeploy and ralph.
+	Agent AgentConfig `yaml:"agent"`
+}
+
+// AgentConfig configures the agent CLI and the models used for each role.
+// See agent.FromConfig for how the backend is built.
+type AgentConfig struct {
+	Backend     string `yaml:"backend"`      // cursor, claude, aider or command
+	Command     string `yaml:"command"`      // agent binary; empty means the backend's default
+	Model       string `yaml:"model"`        // interactive agents (SPC s a)
+	LoopModel   string `yaml:"loop_model"`   // ralph loop agents
+	ReviewModel string `yaml:"review_model"` // verification/review passes
+
+	// Args and InteractiveArgs are argument templates for the command
+	// backend, over {{.Model}} and {{.Prompt}}.
+	Args            []string `yaml:"args"`
+	InteractiveArgs []string `yaml:"interactive_args"`
+
+	// Prices maps model names to token prices, used to estimate the cost
+	// of runs whose CLI reports tokens but no cost.
+	Prices map[string]ModelPrice `yaml:"prices"`
+}
+
+// ModelPrice is a model's price in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `yaml:"input"`
+	Output     float64 `yaml:"output"`
+	CacheRead  float64 `yaml:"cache_read"`
+	CacheWrite float64 `yaml:"cache_write"`
+}
+
+// Default returns a config with every setting at its built-in default.
+func Default() *Config {
+	return &Config{
+		ReviewTeam:     DefaultReviewTeam,
+		PRCacheTTL:     DefaultPRCacheTTL,
+		MergedPRMaxAge: DefaultMergedPRMaxAge,
+		Agent: AgentConfig{
+			Backend:     DefaultAgentBackend,
+			Model:       DefaultAgentModel,
+			LoopModel:   DefaultLoopModel,
+			ReviewModel: DefaultReviewModel,
+		},
+	}
+}
+
+// ResolvePath returns the config file path, using the DEVDEPLOY_CONFIG env
+// var if set, otherwise ~/.devdeploy/config.yaml.
+func ResolvePath() (string, error) {
+	if p := os.Getenv(PathEnv); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, DefaultPath), nil
+}
+
+// Load reads the global config file (missing is fine), applies env var
+// overrides and defaults, and validates the result.
+func Load() (*Config, error) {
+	path, err := ResolvePath()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path, os.Getenv)
+}
+
+// LoadFile is like Load but reads from an explicit path and env lookup,
+// so tests can run without touching the real environment.
+func LoadFile(path string, getenv func(string) string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings from env vars.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	strs := []struct {
+		env string
+		dst *string
+	}{
+		{ReviewTeamEnv, &c.ReviewTeam},
+		{AgentBackendEnv, &c.Agent.Backend},
+		{AgentCommandEnv, &c.Agent.Command},
+		{AgentModelEnv, &c.Agent.Model},
+		{LoopModelEnv, &c.Agent.LoopModel},
+		{ReviewModelEnv, &c.Agent.ReviewModel},
+	}
+	for _, s := range strs {
+		if v := getenv(s.env); v != "" {
+			*s.dst = v
+		}
+	}
+	durs := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{PRCacheTTLEnv, &c.PRCacheTTL},
+		{MergedPRMaxAgeEnv, &c.MergedPRMaxAge},
+	}
+	for _, d := range durs {
+		v := getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.env, err)
+		}
+		*d.dst = parsed
+	}
+	return nil
+}
+
+// applyDefaults fills unset settings with built-in defaults.
+func (c *Config) applyDefaults() {
+	def := Default()
+	if c.ReviewTeam == "" {
+		c.ReviewTeam = def.ReviewTeam
+	}
+	if c.PRCacheTTL == 0 {
+		c.PRCacheTTL = def.PRCacheTTL
+	}
+	if c.MergedPRMaxAge == 0 {
+		c.MergedPRMaxAge = def.MergedPRMaxAge
+	}
+	if c.Agent.Backend == "" {
+		c.Agent.Backend = def.Agent.Backend
+	}
+	if c.Agent.Backend != DefaultAgentBackend {
+		return
+	}
+	if c.Agent.Model == "" {
+		c.Agent.Model = def.Agent.Model
+	}
+	if c.Agent.LoopModel == "" {
+		c.Agent.LoopModel = def.Agent.LoopModel
+	}
+	if c.Agent.ReviewModel == "" {
+		c.Agent.ReviewModel = def.Agent.ReviewModel
+	}
+}
+
+// Validate rejects values that would break gh queries or agent command lines.
+func (c *Config) Validate() error {
+	if strings.ContainsAny(c.ReviewTeam, " \t\n/") {
+		return fmt.Errorf("invalid review_team %q: use the team slug without the org", c.ReviewTeam)
+	}
+	if c.PRCacheTTL < 0 {
+		return fmt.Errorf("pr_cache_ttl must not be negative")
+	}
+	if c.MergedPRMaxAge < 0 {
+		return fmt.Errorf("merged_pr_max_age must not be negative")
+	}
+	if strings.ContainsAny(c.Agent.Command, "\t\n'\"") {
+		return fmt.Errorf("invalid agent.command %q", c.Agent.Command)
+	}
+	for name, model := range map[string]string{
+		"agent.model":        c.Agent.Model,
+		"agent.loop_model":   c.Agent.LoopModel,
+		"agent.review_model": c.Agent.ReviewModel,
+	} {
+		if strings.ContainsAny(model, " \t\n'\"") {
+			return fmt.Errorf("invalid %s %q", name, model)
+		}
+	}
+	for model, p := range c.Agent.Prices {
+		if p.Input < 0 || p.Output < 0 || p.CacheRead < 0 || p.CacheWrite < 0 {
+			return fmt.Errorf("agent.prices.%s must not be negative", model)
+		}
+	}
+	return nil
+}
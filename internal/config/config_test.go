@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func noEnv(string) string { return "" }
+
+func TestLoadFile_MissingUsesDefaults(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), "config.yaml"), noEnv)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("config = %+v, want defaults %+v", cfg, Default())
+	}
+}
+
+func TestLoadFile_ParsesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+review_team: platform
+pr_cache_ttl: 2m
+agent:
+  command: cursor-agent
+  loop_model: fast-model
+  prices:
+    fast-model: {input: 1.25, output: 10, cache_read: 0.125}
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadFile(path, noEnv)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if cfg.ReviewTeam != "platform" {
+		t.Errorf("review team = %q, want platform", cfg.ReviewTeam)
+	}
+	if cfg.PRCacheTTL != 2*time.Minute {
+		t.Errorf("pr cache ttl = %v, want 2m", cfg.PRCacheTTL)
+	}
+	if cfg.MergedPRMaxAge != DefaultMergedPRMaxAge {
+		t.Errorf("merged pr max age = %v, want default", cfg.MergedPRMaxAge)
+	}
+	if cfg.Agent.Command != "cursor-agent" || cfg.Agent.LoopModel != "fast-model" {
+		t.Errorf("agent = %+v, want cursor-agent/fast-model", cfg.Agent)
+	}
+	if p := cfg.Agent.Prices["fast-model"]; p != (ModelPrice{Input: 1.25, Output: 10, CacheRead: 0.125}) {
+		t.Errorf("fast-model price = %+v", p)
+	}
+	if cfg.Agent.Model != DefaultAgentModel {
+		t.Errorf("agent model = %q, want default", cfg.Agent.Model)
+	}
+}
+
+func TestLoadFile_NonCursorBackendKeepsCLIModels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "agent:\n  backend: claude\n  review_model: opus\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadFile(path, noEnv)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if cfg.Agent.Model != "" || cfg.Agent.LoopModel != "" {
+		t.Errorf("models = %q/%q, want empty so the CLI default is used", cfg.Agent.Model, cfg.Agent.LoopModel)
+	}
+	if cfg.Agent.ReviewModel != "opus" {
+		t.Errorf("review model = %q, want opus", cfg.Agent.ReviewModel)
+	}
+}
+
+func TestLoadFile_EnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("review_team: from-file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		ReviewTeamEnv: "from-env",
+		PRCacheTTLEnv: "10s",
+		LoopModelEnv:  "env-model",
+	}
+	cfg, err := LoadFile(path, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if cfg.ReviewTeam != "from-env" {
+		t.Errorf("review team = %q, want from-env", cfg.ReviewTeam)
+	}
+	if cfg.PRCacheTTL != 10*time.Second {
+		t.Errorf("pr cache ttl = %v, want 10s", cfg.PRCacheTTL)
+	}
+	if cfg.Agent.LoopModel != "env-model" {
+		t.Errorf("loop model = %q, want env-model", cfg.Agent.LoopModel)
+	}
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		env  map[string]string
+		want string
+	}{
+		{"unknown key", "review_tem: x\n", nil, "review_tem"},
+		{"team with org", "review_team: org/team\n", nil, "review_team"},
+		{"bad duration", "pr_cache_ttl: soon\n", nil, "time.Duration"},
+		{"negative age", "merged_pr_max_age: -1h\n", nil, "merged_pr_max_age"},
+		{"bad model", "agent:\n  loop_model: \"a b\"\n", nil, "agent.loop_model"},
+		{"negative price", "agent:\n  prices:\n    m: {output: -1}\n", nil, "agent.prices.m"},
+		{"bad env duration", "", map[string]string{PRCacheTTLEnv: "x"}, PRCacheTTLEnv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadFile(path, func(k string) string { return tt.env[k] })
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
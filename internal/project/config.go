@@ -0,0 +1,312 @@
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigFileName is the per-project config file in the project directory.
+	ConfigFileName = "config.yaml"
+	// DefaultBranchPrefix is prepended to branches created by AddRepo
+	// when the project config does not set branch_prefix.
+	DefaultBranchPrefix = "devdeploy/"
+)
+
+// defaultConfigText is written by CreateProject. Every key is commented out
+// so a fresh project behaves exactly like one without a config file.
+const defaultConfigText = `# devdeploy project config
+#
+# branch_prefix: devdeploy/        # prefix for branches created by "add repo"
+# agent_model: claude-4.5-opus-high-thinking
+# review_team: my-team             # GitHub team slug for review-requested PRs
+# env:                             # extra env vars for setup commands and agents
+#   GOFLAGS: -mod=mod
+# setup:                           # shell commands run in each new worktree
+#   - make deps
+# verify: go test ./...            # ralph merges a bead only if this passes
+# agent_timeout: 20m               # ralph's per-bead agent timeout (label: timeout:30m)
+# agent_backend: claude            # ralph's agent CLI (label: agent:<backend>)
+# escalation_model: claude-4.5-opus-high-thinking  # retry failed beads once on this model
+# pull_requests: true              # ralph opens a PR per bead instead of merging
+# worktree_pool: true              # ralph reuses worktrees across beads, keeping build caches
+# merge_strategy: squash           # merge (default), squash, rebase or ff-only
+# conflict_attempts: 3             # agents that may try to resolve a merge's conflicts
+# prompt_template: prompt.tmpl     # ralph's bead prompt (default: <repo>/.ralph/prompt.tmpl)
+# merge_template: merge.tmpl       # ralph's merge conflict prompt (default: <repo>/.ralph/merge.tmpl)
+# retry:                           # ralph's retries of failed beads in a run
+#   failure: {max_attempts: 3, backoff: 1m}   # backoff doubles per retry
+#   timeout: {max_attempts: 2, backoff: 5m}
+# repos:
+#   my-repo:
+#     base_branch: develop         # default: origin/HEAD, then main/master
+`
+
+// envKeyPattern matches valid environment variable names.
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// modelPattern matches agent model names. Models reach the agent's command
+// line, so shell metacharacters are rejected.
+var modelPattern = regexp.MustCompile(`^[A-Za-z0-9._:/@+-]*$`)
+
+// Config is the per-project configuration loaded from <projectDir>/config.yaml.
+// Zero values mean "use the built-in default".
+type Config struct {
+	BranchPrefix string                `yaml:"branch_prefix"` // prefix for branches created by AddRepo
+	AgentModel   string                `yaml:"agent_model"`   // model for agents launched in this project
+	ReviewTeam   string                `yaml:"review_team"`   // GitHub team slug for review-requested PRs
+	Env          map[string]string     `yaml:"env"`           // extra env vars for setup commands and agents
+	Setup        []string              `yaml:"setup"`         // shell commands run in each new worktree
+	Verify       string                `yaml:"verify"`        // shell command gating ralph merges
+	Repos        map[string]RepoConfig `yaml:"repos"`         // per-repo overrides keyed by repo name
+
+	// Defaults for ralph's agents; a bead's model:, timeout: and agent:
+	// labels override them.
+	AgentTimeout     time.Duration `yaml:"agent_timeout"`     // per-bead agent timeout
+	AgentBackend     string        `yaml:"agent_backend"`     // agent CLI, overriding the global agent.backend
+	EscalationModel  string        `yaml:"escalation_model"`  // model a failed bead is retried on once
+	Retry            RetryConfig   `yaml:"retry"`             // ralph's retries of failed beads
+	PullRequests     bool          `yaml:"pull_requests"`     // ralph opens a PR per bead instead of merging
+	WorktreePool     bool          `yaml:"worktree_pool"`     // ralph reuses worktrees across beads
+	MergeStrategy    string        `yaml:"merge_strategy"`    // how ralph lands beads: merge, squash, rebase or ff-only
+	ConflictAttempts int           `yaml:"conflict_attempts"` // agents that may try to resolve a merge's conflicts
+
+	// Template files overriding ralph's prompts; relative paths are
+	// resolved against the project directory.
+	PromptTemplate string `yaml:"prompt_template"`
+	MergeTemplate  string `yaml:"merge_template"`
+}
+
+// RetryConfig holds ralph's retry rules per bead outcome.
+type RetryConfig struct {
+	Failure RetryRule `yaml:"failure"` // the agent failed or left the bead open
+	Timeout RetryRule `yaml:"timeout"` // the agent was killed at its timeout
+}
+
+// RetryRule bounds the attempts at a bead after one outcome.
+type RetryRule struct {
+	MaxAttempts int           `yaml:"max_attempts"` // attempts per run, counting the first
+	Backoff     time.Duration `yaml:"backoff"`      // wait before the first retry; doubles after
+}
+
+// RepoConfig holds per-repo overrides within a project.
+type RepoConfig struct {
+	BaseBranch string `yaml:"base_branch"` // branch new worktrees start from
+}
+
+// ParseConfig decodes and validates config YAML. Unknown keys are rejected
+// so typos surface instead of being silently ignored. Empty input (or a
+// file containing only comments) yields an empty config.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing project config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadConfigFile reads and parses a config file. A missing file is not an
+// error and yields an empty config.
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, p := range []*string{&cfg.PromptTemplate, &cfg.MergeTemplate} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(filepath.Dir(path), *p)
+		}
+	}
+	return cfg, nil
+}
+
+// LoadConfigForWorktree loads the config of the project that contains the
+// given worktree. Project worktrees live directly under the project
+// directory, so the config is expected in the worktree's parent. A
+// worktree outside the projects base (see ResolveProjectsBase) belongs to
+// no project and yields an empty config.
+func LoadConfigForWorktree(worktreePath string) (*Config, error) {
+	base, err := ResolveProjectsBase()
+	if err != nil {
+		return &Config{}, nil
+	}
+	return loadConfigForWorktree(base, worktreePath)
+}
+
+// loadConfigForWorktree loads the config of the project under projectsBase
+// that contains worktreePath, or an empty config if there is none.
+func loadConfigForWorktree(projectsBase, worktreePath string) (*Config, error) {
+	projDir, err := filepath.Abs(filepath.Dir(worktreePath))
+	if err != nil {
+		return nil, err
+	}
+	base, err := filepath.Abs(projectsBase)
+	if err != nil {
+		return nil, err
+	}
+	if !sameDir(filepath.Dir(projDir), base) {
+		return &Config{}, nil
+	}
+	return LoadConfigFile(filepath.Join(projDir, ConfigFileName))
+}
+
+// sameDir reports whether a and b name the same directory, following
+// symlinks where they exist.
+func sameDir(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
+
+// LoadConfig loads the config for the named project.
+func (m *Manager) LoadConfig(projectName string) (*Config, error) {
+	return LoadConfigFile(filepath.Join(m.projectDir(projectName), ConfigFileName))
+}
+
+// Validate checks the config for values that would break git or the shell.
+func (c *Config) Validate() error {
+	if c.BranchPrefix != "" {
+		if strings.ContainsAny(c.BranchPrefix, " ~^:?*[\\") || strings.Contains(c.BranchPrefix, "..") ||
+			strings.HasPrefix(c.BranchPrefix, "-") || strings.HasPrefix(c.BranchPrefix, "/") {
+			return fmt.Errorf("invalid branch_prefix %q", c.BranchPrefix)
+		}
+	}
+	if !modelPattern.MatchString(c.AgentModel) {
+		return fmt.Errorf("invalid agent_model %q", c.AgentModel)
+	}
+	if !modelPattern.MatchString(c.EscalationModel) {
+		return fmt.Errorf("invalid escalation_model %q", c.EscalationModel)
+	}
+	if strings.ContainsAny(c.AgentBackend, " \t\n'\"/") {
+		return fmt.Errorf("invalid agent_backend %q", c.AgentBackend)
+	}
+	if c.AgentTimeout < 0 {
+		return fmt.Errorf("invalid agent_timeout %v: must not be negative", c.AgentTimeout)
+	}
+	if c.ConflictAttempts < 0 {
+		return fmt.Errorf("invalid conflict_attempts %d: must not be negative", c.ConflictAttempts)
+	}
+	switch c.MergeStrategy {
+	case "", "merge", "squash", "rebase", "ff-only":
+	default:
+		return fmt.Errorf("invalid merge_strategy %q: want merge, squash, rebase or ff-only", c.MergeStrategy)
+	}
+	for name, r := range map[string]RetryRule{"failure": c.Retry.Failure, "timeout": c.Retry.Timeout} {
+		if r.MaxAttempts < 0 || r.Backoff < 0 {
+			return fmt.Errorf("invalid retry.%s: max_attempts and backoff must not be negative", name)
+		}
+	}
+	if strings.ContainsAny(c.ReviewTeam, " \t\n/") {
+		return fmt.Errorf("invalid review_team %q: use the team slug without the org", c.ReviewTeam)
+	}
+	for k := range c.Env {
+		if !envKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid env var name %q", k)
+		}
+	}
+	for i, s := range c.Setup {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("setup[%d] is empty", i)
+		}
+	}
+	for name, rc := range c.Repos {
+		if strings.ContainsAny(rc.BaseBranch, " ~^:?*[\\") || strings.HasPrefix(rc.BaseBranch, "-") {
+			return fmt.Errorf("repos.%s: invalid base_branch %q", name, rc.BaseBranch)
+		}
+	}
+	return nil
+}
+
+// BranchPrefixOrDefault returns the configured branch prefix, or
+// DefaultBranchPrefix when unset.
+func (c *Config) BranchPrefixOrDefault() string {
+	if c.BranchPrefix == "" {
+		return DefaultBranchPrefix
+	}
+	return c.BranchPrefix
+}
+
+// BaseBranch returns the configured base branch for a repo, or "" to use
+// the repository's default branch.
+func (c *Config) BaseBranch(repoName string) string {
+	return c.Repos[repoName].BaseBranch
+}
+
+// Environ returns the extra env vars as KEY=value pairs, sorted by key so
+// commands built from them are deterministic.
+func (c *Config) Environ() []string {
+	keys := make([]string, 0, len(c.Env))
+	for k := range c.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+c.Env[k])
+	}
+	return env
+}
+
+// RunSetup runs the configured setup commands in dir with the extra env
+// vars applied. It stops at the first failing command.
+func (c *Config) RunSetup(dir string) error {
+	for _, script := range c.Setup {
+		cmd := exec.Command("sh", "-c", script)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), c.Environ()...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = err.Error()
+			}
+			return fmt.Errorf("setup %q: %s", script, msg)
+		}
+	}
+	return nil
+}
+
+// resolveBaseBranch returns the ref new worktrees of repoPath should start
+// from. A configured branch is preferred on origin, then locally; with no
+// configured branch the repository default is used.
+func resolveBaseBranch(repoPath, configured string) (string, error) {
+	if configured == "" {
+		return resolveDefaultBranch(repoPath)
+	}
+	for _, candidate := range []string{"origin/" + configured, configured} {
+		if exec.Command("git", "-C", repoPath, "rev-parse", "--verify", candidate).Run() == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("configured base branch %s not found locally or on origin", configured)
+}
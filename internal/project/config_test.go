@@ -0,0 +1,304 @@
+package project
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"devdeploy/internal/config"
+)
+
+func TestParseConfig_Full(t *testing.T) {
+	data := []byte(`
+branch_prefix: team/
+agent_model: composer-1
+review_team: platform
+env:
+  GOFLAGS: -mod=mod
+  A_VAR: x
+setup:
+  - make deps
+verify: go test ./...
+agent_timeout: 20m
+agent_backend: claude
+escalation_model: opus
+pull_requests: true
+worktree_pool: true
+merge_strategy: rebase
+conflict_attempts: 5
+retry:
+  failure: {max_attempts: 3, backoff: 1m}
+  timeout:
+    max_attempts: 2
+repos:
+  api:
+    base_branch: develop
+`)
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.BranchPrefixOrDefault() != "team/" {
+		t.Errorf("branch prefix = %q, want team/", cfg.BranchPrefixOrDefault())
+	}
+	if cfg.AgentModel != "composer-1" {
+		t.Errorf("agent model = %q, want composer-1", cfg.AgentModel)
+	}
+	if cfg.ReviewTeam != "platform" {
+		t.Errorf("review team = %q, want platform", cfg.ReviewTeam)
+	}
+	if cfg.Verify != "go test ./..." {
+		t.Errorf("verify = %q, want go test ./...", cfg.Verify)
+	}
+	if cfg.AgentTimeout != 20*time.Minute || cfg.AgentBackend != "claude" || cfg.EscalationModel != "opus" {
+		t.Errorf("agent timeout/backend/escalation = %v/%q/%q, want 20m/claude/opus", cfg.AgentTimeout, cfg.AgentBackend, cfg.EscalationModel)
+	}
+	want := RetryConfig{Failure: RetryRule{MaxAttempts: 3, Backoff: time.Minute}, Timeout: RetryRule{MaxAttempts: 2}}
+	if cfg.Retry != want || !cfg.PullRequests || !cfg.WorktreePool || cfg.MergeStrategy != "rebase" || cfg.ConflictAttempts != 5 {
+		t.Errorf("retry = %+v, pull requests = %v, worktree pool = %v, merge strategy = %q, conflict attempts = %d; want %+v, true, true, rebase, 5",
+			cfg.Retry, cfg.PullRequests, cfg.WorktreePool, cfg.MergeStrategy, cfg.ConflictAttempts, want)
+	}
+	if got := cfg.BaseBranch("api"); got != "develop" {
+		t.Errorf("BaseBranch(api) = %q, want develop", got)
+	}
+	if got := cfg.BaseBranch("other"); got != "" {
+		t.Errorf("BaseBranch(other) = %q, want empty", got)
+	}
+	env := cfg.Environ()
+	if len(env) != 2 || env[0] != "A_VAR=x" || env[1] != "GOFLAGS=-mod=mod" {
+		t.Errorf("Environ() = %v, want sorted [A_VAR=x GOFLAGS=-mod=mod]", env)
+	}
+}
+
+func TestParseConfig_DefaultTemplateIsEmpty(t *testing.T) {
+	cfg, err := ParseConfig([]byte(defaultConfigText))
+	if err != nil {
+		t.Fatalf("ParseConfig(default template): %v", err)
+	}
+	if cfg.BranchPrefixOrDefault() != DefaultBranchPrefix {
+		t.Errorf("branch prefix = %q, want default %q", cfg.BranchPrefixOrDefault(), DefaultBranchPrefix)
+	}
+	if len(cfg.Setup) != 0 || len(cfg.Env) != 0 || len(cfg.Repos) != 0 {
+		t.Errorf("expected empty config, got %+v", cfg)
+	}
+}
+
+func TestParseConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unknown key", "branch_prefx: x/\n", "branch_prefx"},
+		{"bad prefix", "branch_prefix: \"a..b/\"\n", "branch_prefix"},
+		{"bad model", "agent_model: \"a b\"\n", "agent_model"},
+		{"model with command substitution", "agent_model: \"$(touch pwned)\"\n", "agent_model"},
+		{"team with org", "review_team: org/team\n", "review_team"},
+		{"bad env key", "env:\n  1BAD: x\n", "env var"},
+		{"empty setup", "setup:\n  - \"  \"\n", "setup[0]"},
+		{"bad base branch", "repos:\n  api:\n    base_branch: \"-x\"\n", "repos.api"},
+		{"bad agent timeout", "agent_timeout: soon\n", "soon"},
+		{"negative agent timeout", "agent_timeout: -5m\n", "agent_timeout"},
+		{"bad escalation model", "escalation_model: \"a b\"\n", "escalation_model"},
+		{"escalation model with backticks", "escalation_model: \"`id`\"\n", "escalation_model"},
+		{"negative retry attempts", "retry:\n  timeout: {max_attempts: -1}\n", "retry.timeout"},
+		{"unknown merge strategy", "merge_strategy: octopus\n", "merge_strategy"},
+		{"negative conflict attempts", "conflict_attempts: -1\n", "conflict_attempts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.data))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_LoadConfig_MissingFile(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir, dir)
+
+	cfg, err := m.LoadConfig("no-such-project")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.BranchPrefixOrDefault() != DefaultBranchPrefix {
+		t.Errorf("expected default branch prefix, got %q", cfg.BranchPrefixOrDefault())
+	}
+}
+
+func TestLoadConfigForWorktree(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ProjectDirEnv, dir)
+	m := NewManager(dir, dir)
+	_ = m.CreateProject("proj")
+	projDir := filepath.Join(dir, "proj")
+	if err := os.WriteFile(filepath.Join(projDir, ConfigFileName), []byte("review_team: infra\nprompt_template: ralph/prompt.tmpl\nmerge_template: /etc/merge.tmpl\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfigForWorktree(filepath.Join(projDir, "my-repo"))
+	if err != nil {
+		t.Fatalf("LoadConfigForWorktree: %v", err)
+	}
+	if cfg.ReviewTeam != "infra" {
+		t.Errorf("review team = %q, want infra", cfg.ReviewTeam)
+	}
+	if want := filepath.Join(projDir, "ralph", "prompt.tmpl"); cfg.PromptTemplate != want || cfg.MergeTemplate != "/etc/merge.tmpl" {
+		t.Errorf("templates = %q, %q; want %q resolved against the project and the absolute path kept", cfg.PromptTemplate, cfg.MergeTemplate, want)
+	}
+	if got := m.reviewTeamFor(filepath.Join(projDir, "my-repo")); got != "infra" {
+		t.Errorf("reviewTeamFor = %q, want infra", got)
+	}
+	if got := m.reviewTeamFor(filepath.Join(dir, "other", "repo")); got != config.DefaultReviewTeam {
+		t.Errorf("reviewTeamFor without config = %q, want %q", got, config.DefaultReviewTeam)
+	}
+	global := &config.Config{ReviewTeam: "global-team"}
+	gm := NewManager(dir, dir, WithGlobalConfig(global))
+	if got := gm.reviewTeamFor(filepath.Join(dir, "other", "repo")); got != "global-team" {
+		t.Errorf("reviewTeamFor with global config = %q, want global-team", got)
+	}
+}
+
+func TestLoadConfigForWorktree_OutsideProjects(t *testing.T) {
+	t.Setenv(ProjectDirEnv, t.TempDir())
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, ConfigFileName), []byte("agent_model: foreign\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfigForWorktree(filepath.Join(other, "repo"))
+	if err != nil {
+		t.Fatalf("LoadConfigForWorktree: %v", err)
+	}
+	if cfg.AgentModel != "" {
+		t.Errorf("agent model = %q, want the config outside the projects base ignored", cfg.AgentModel)
+	}
+}
+
+func TestConfig_RunSetup(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		Env:   map[string]string{"GREETING": "hello"},
+		Setup: []string{`echo "$GREETING" > out.txt`},
+	}
+	if err := cfg.RunSetup(dir); err != nil {
+		t.Fatalf("RunSetup: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatalf("reading setup output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "hello" {
+		t.Errorf("setup output = %q, want hello", data)
+	}
+
+	failing := &Config{Setup: []string{"echo boom >&2; exit 1", "touch never"}}
+	err = failing.RunSetup(dir)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected setup error mentioning stderr, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "never")); !os.IsNotExist(statErr) {
+		t.Error("expected setup to stop at the first failing command")
+	}
+}
+
+func TestManager_AddRepo_UsesConfig(t *testing.T) {
+	dir := t.TempDir()
+	wsDir := filepath.Join(dir, "workspace")
+	srcRepo := filepath.Join(wsDir, "api")
+	if err := os.MkdirAll(srcRepo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-b", "main"},
+		{"config", "user.name", "Test User"},
+		{"config", "user.email", "test@example.com"},
+		{"commit", "--allow-empty", "-m", "initial"},
+		{"branch", "develop"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", srcRepo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	m := NewManager(filepath.Join(dir, "projects"), wsDir)
+	_ = m.CreateProject("proj")
+	cfgText := "branch_prefix: team/\nsetup:\n  - touch .setup-done\nrepos:\n  api:\n    base_branch: develop\n"
+	if err := os.WriteFile(filepath.Join(dir, "projects", "proj", ConfigFileName), []byte(cfgText), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.AddRepo("proj", "api"); err != nil {
+		t.Fatalf("AddRepo: %v", err)
+	}
+	wt := filepath.Join(dir, "projects", "proj", "api")
+	out, err := exec.Command("git", "-C", wt, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	if branch := strings.TrimSpace(string(out)); !strings.HasPrefix(branch, "team/proj-") {
+		t.Errorf("branch = %q, want team/proj-* prefix", branch)
+	}
+	if _, err := os.Stat(filepath.Join(wt, ".setup-done")); err != nil {
+		t.Errorf("expected setup command to run in worktree: %v", err)
+	}
+}
+
+func TestManager_EnsurePRWorktree_SetupFailure(t *testing.T) {
+	dir := t.TempDir()
+	wsDir := filepath.Join(dir, "workspace")
+	srcRepo := filepath.Join(wsDir, "api")
+	if err := os.MkdirAll(srcRepo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-b", "main"},
+		{"config", "user.name", "Test User"},
+		{"config", "user.email", "test@example.com"},
+		{"commit", "--allow-empty", "-m", "initial"},
+		{"branch", "feat"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", srcRepo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	m := NewManager(filepath.Join(dir, "projects"), wsDir)
+	_ = m.CreateProject("proj")
+	ready := filepath.Join(dir, "ready")
+	cfgText := "setup:\n  - test -f " + ready + "\n  - touch .setup-done\n"
+	if err := os.WriteFile(filepath.Join(dir, "projects", "proj", ConfigFileName), []byte(cfgText), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wt := filepath.Join(dir, "projects", "proj", "api-pr-7")
+	if _, err := m.EnsurePRWorktree("proj", "api", 7, "feat"); err == nil {
+		t.Fatal("expected EnsurePRWorktree to fail with setup")
+	}
+	if _, err := os.Stat(wt); !os.IsNotExist(err) {
+		t.Fatalf("worktree whose setup failed was kept: %v", err)
+	}
+
+	// The next call adds the worktree again and reruns setup.
+	if err := os.WriteFile(ready, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.EnsurePRWorktree("proj", "api", 7, "feat")
+	if err != nil {
+		t.Fatalf("EnsurePRWorktree after fixing setup: %v", err)
+	}
+	if got != wt {
+		t.Errorf("EnsurePRWorktree = %s, want %s", got, wt)
+	}
+	if _, err := os.Stat(filepath.Join(wt, ".setup-done")); err != nil {
+		t.Errorf("expected setup to rerun in the new worktree: %v", err)
+	}
+}
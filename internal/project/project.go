@@ -12,6 +12,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"devdeploy/internal/config"
 )
 
 const (
@@ -90,15 +92,22 @@ type prCacheEntry struct {
 type Manager struct {
 	projectsBase string
 	workspace    string
+	global       *config.Config          // global settings (review team, cache TTLs)
 	prCache      map[string]prCacheEntry // key: worktreePath + state + limit
 	prCacheMu    sync.RWMutex            // protects prCache
 }
 
-// prCacheTTL is how long cached PR data remains valid.
-const prCacheTTL = 45 * time.Second
+// ManagerOption configures NewManager.
+type ManagerOption func(*Manager)
+
+// WithGlobalConfig sets the global devdeploy config. Without it the
+// built-in defaults (config.Default) are used.
+func WithGlobalConfig(cfg *config.Config) ManagerOption {
+	return func(m *Manager) { m.global = cfg }
+}
 
 // NewManager creates a manager for the given projects base directory.
-func NewManager(projectsBase, workspace string) *Manager {
+func NewManager(projectsBase, workspace string, opts ...ManagerOption) *Manager {
 	if workspace == "" {
 		workspace = os.Getenv(WorkspaceEnv)
 	}
@@ -106,11 +115,16 @@ func NewManager(projectsBase, workspace string) *Manager {
 		home, _ := os.UserHomeDir()
 		workspace = filepath.Join(home, DefaultWorkspace)
 	}
-	return &Manager{
+	m := &Manager{
 		projectsBase: projectsBase,
 		workspace:    workspace,
+		global:       config.Default(),
 		prCache:      make(map[string]prCacheEntry),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 // ProjectInfo holds minimal project metadata for listing.
@@ -155,11 +169,11 @@ func (m *Manager) CreateProject(name string) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	configPath := filepath.Join(dir, "config.yaml")
+	configPath := filepath.Join(dir, ConfigFileName)
 	if _, err := os.Stat(configPath); err == nil {
 		return nil // already exists
 	}
-	return os.WriteFile(configPath, []byte("# devdeploy project config\n"), 0644)
+	return os.WriteFile(configPath, []byte(defaultConfigText), 0644)
 }
 
 // DeleteProject removes a project directory and all its worktrees.
@@ -217,7 +231,7 @@ func (m *Manager) ListProjectRepos(projectName string) ([]string, error) {
 			continue
 		}
 		name := e.Name()
-		if strings.HasPrefix(name, ".") || name == "config.yaml" {
+		if strings.HasPrefix(name, ".") || name == ConfigFileName {
 			continue
 		}
 		// Skip PR worktree dirs (e.g. my-repo-pr-42); they belong to PR resources.
@@ -255,20 +269,27 @@ func (m *Manager) ListProjectReposOnly(projectName string) []Resource {
 }
 
 // AddRepo creates a worktree in the project dir from a repo in ~/workspace.
-// It creates a new branch named devdeploy/<project>-<3 random alphanumeric chars> based on main,
-// ensuring it's up to date. The random suffix reduces collisions when multiple devdeploy
-// instances or users add the same project.
+// It creates a new branch named <prefix><project>-<3 random alphanumeric chars> based on
+// the repo's base branch, ensuring it's up to date. The prefix (default devdeploy/) and
+// base branch (default origin/HEAD, then main/master) come from the project config.
+// The random suffix reduces collisions when multiple devdeploy instances or users add
+// the same project.
 // Does not change the main repo's current branch.
 // Hooks are disabled during worktree add/merge to avoid repo-specific hooks (e.g. beads)
-// from failing and blocking the operation.
+// from failing and blocking the operation. Configured setup commands run in the new
+// worktree once it is ready; if they fail, the worktree is removed again.
 func (m *Manager) AddRepo(projectName, repoName string) error {
 	srcRepo := filepath.Join(m.workspace, repoName)
 	dstPath := filepath.Join(m.projectDir(projectName), repoName)
 	if _, err := os.Stat(srcRepo); err != nil {
 		return fmt.Errorf("source repo %s: %w", srcRepo, err)
 	}
+	cfg, err := m.LoadConfig(projectName)
+	if err != nil {
+		return err
+	}
 	base := strings.ToLower(strings.ReplaceAll(projectName, " ", "-"))
-	branch := "devdeploy/" + base + "-" + randAlnum(3)
+	branch := cfg.BranchPrefixOrDefault() + base + "-" + randAlnum(3)
 
 	// Empty dir for core.hooksPath to disable hooks (avoids post-checkout etc. failing)
 	emptyHooksDir, err := os.MkdirTemp("", "devdeploy-nohooks")
@@ -284,8 +305,8 @@ func (m *Manager) AddRepo(projectName, repoName string) error {
 	// Best-effort fetch; failure is okay if we already have the ref locally
 	_ = fetchCmd.Run()
 
-	// Resolve default branch ref
-	mainRef, err := resolveDefaultBranch(srcRepo)
+	// Resolve base branch ref (configured per repo, else the default branch)
+	mainRef, err := resolveBaseBranch(srcRepo, cfg.BaseBranch(repoName))
 	if err != nil {
 		return err
 	}
@@ -307,7 +328,7 @@ func (m *Manager) AddRepo(projectName, repoName string) error {
 		}
 		// Invalidate cache for this project since a repo was added
 		m.ClearPRCacheForProject(projectName)
-		return nil
+		return runSetup(cfg, srcRepo, dstPath)
 	}
 
 	// Branch exists: add worktree, then update it with main (without touching main repo's HEAD)
@@ -336,7 +357,22 @@ func (m *Manager) AddRepo(projectName, repoName string) error {
 	}
 	// Invalidate cache for this project since a repo was added
 	m.ClearPRCacheForProject(projectName)
-	return nil
+	return runSetup(cfg, srcRepo, dstPath)
+}
+
+// runSetup runs cfg's setup commands in the worktree just added at dstPath.
+// If they fail the worktree is removed again (its branch is kept), so the
+// next attempt adds it afresh and reruns setup rather than reusing a
+// worktree that was never set up.
+func runSetup(cfg *Config, srcRepo, dstPath string) error {
+	err := cfg.RunSetup(dstPath)
+	if err == nil {
+		return nil
+	}
+	if out, rmErr := exec.Command("git", "-C", srcRepo, "worktree", "remove", dstPath, "--force").CombinedOutput(); rmErr != nil {
+		return fmt.Errorf("%w (removing worktree: %s)", err, strings.TrimSpace(string(out)))
+	}
+	return err
 }
 
 // RemoveRepo removes a worktree from the project.
@@ -413,10 +449,17 @@ type RepoPRs struct {
 	PRs  []PRInfo
 }
 
-// reviewTeam is the GitHub team slug used to filter PRs by review request.
-// PRs requesting review from this team are included alongside the current
-// user's own PRs.
-const reviewTeam = "adaptive-telemetry"
+// reviewTeamFor returns the GitHub team slug used to filter PRs by review
+// request for the project containing worktreePath. PRs requesting review
+// from this team are included alongside the current user's own PRs.
+// The project's review_team wins; when the project config is missing,
+// invalid, or does not set one, the global review team is used.
+func (m *Manager) reviewTeamFor(worktreePath string) string {
+	if cfg, err := loadConfigForWorktree(m.projectsBase, worktreePath); err == nil && cfg.ReviewTeam != "" {
+		return cfg.ReviewTeam
+	}
+	return m.global.ReviewTeam
+}
 
 // prCacheKey generates a cache key from worktreePath, state, and limit.
 func prCacheKey(worktreePath, state string, limit int) string {
@@ -431,7 +474,7 @@ func (m *Manager) getCachedPRs(key string) ([]PRInfo, bool) {
 	if !ok {
 		return nil, false
 	}
-	if time.Since(entry.timestamp) > prCacheTTL {
+	if time.Since(entry.timestamp) > m.global.PRCacheTTL {
 		return nil, false
 	}
 	// Return a copy to avoid external mutation
@@ -516,7 +559,7 @@ func getRepoOwner(worktreePath string) string {
 }
 
 // ListFilteredPRsInRepo returns PRs authored by the current user OR
-// requesting review from the reviewTeam. It makes two gh pr list calls
+// requesting review from the project's review team. It makes two gh pr list calls
 // and deduplicates the results by PR number.
 // This is exported for use in async loading scenarios.
 func (m *Manager) ListFilteredPRsInRepo(worktreePath string, state string, limit int) ([]PRInfo, error) {
@@ -524,9 +567,9 @@ func (m *Manager) ListFilteredPRsInRepo(worktreePath string, state string, limit
 }
 
 // listFilteredPRsInRepo returns PRs authored by the current user OR
-// requesting review from the reviewTeam. It makes two gh pr list calls
+// requesting review from the project's review team. It makes two gh pr list calls
 // and deduplicates the results by PR number.
-// Results are cached for prCacheTTL to reduce GitHub API calls.
+// Results are cached for the configured PR cache TTL to reduce GitHub API calls.
 func (m *Manager) listFilteredPRsInRepo(worktreePath string, state string, limit int) ([]PRInfo, error) {
 	// Check cache first
 	cacheKey := prCacheKey(worktreePath, state, limit)
@@ -541,7 +584,7 @@ func (m *Manager) listFilteredPRsInRepo(worktreePath string, state string, limit
 	var teamPRs []PRInfo
 	var teamErr error
 	if owner := getRepoOwner(worktreePath); owner != "" {
-		search := fmt.Sprintf("team-review-requested:%s/%s", owner, reviewTeam)
+		search := fmt.Sprintf("team-review-requested:%s/%s", owner, m.reviewTeamFor(worktreePath))
 		teamPRs, teamErr = m.listPRsInRepo(worktreePath, state, limit, "--search", search)
 	}
 
@@ -683,9 +726,6 @@ func (m *Manager) LoadProjectSummary(projectName string) DashboardSummary {
 // mergedPRsLimit is how many recently merged PRs to show per repo.
 const mergedPRsLimit = 5
 
-// mergedPRMaxAge is the maximum age of merged PRs to show (20 hours).
-const mergedPRMaxAge = 20 * time.Hour
-
 // ListProjectPRs returns PRs grouped by repo (open + recently merged).
 // PRs are fetched in parallel across repos, and within each repo, open and merged PRs
 // are fetched concurrently for optimal performance.
@@ -739,8 +779,8 @@ func (m *Manager) ListProjectPRs(projectName string) ([]RepoPRs, error) {
 				allPRs = append(allPRs, openPRs...)
 			}
 			if mergedErr == nil {
-				// Filter merged PRs to only include those merged within mergedPRMaxAge
-				cutoff := time.Now().Add(-mergedPRMaxAge)
+				// Filter merged PRs to only include those merged within the configured max age
+				cutoff := time.Now().Add(-m.global.MergedPRMaxAge)
 				for _, pr := range mergedPRs {
 					if pr.MergedAt != nil && pr.MergedAt.After(cutoff) {
 						allPRs = append(allPRs, pr)
@@ -782,6 +822,7 @@ func (m *Manager) ListProjectPRs(projectName string) ([]RepoPRs, error) {
 // git worktree list output from the source repo), and if so reuses it.
 // Otherwise it fetches the branch from origin and creates a new worktree.
 // The worktree path is: <projectDir>/<repoName>-pr-<number>.
+// Configured setup commands run only when a new worktree is created.
 // Returns the absolute worktree path.
 func (m *Manager) EnsurePRWorktree(projectName, repoName string, prNumber int, branchName string) (string, error) {
 	srcRepo := filepath.Join(m.workspace, repoName)
@@ -810,6 +851,11 @@ func (m *Manager) EnsurePRWorktree(projectName, repoName string, prNumber int, b
 		return existing, nil // Reusing existing worktree, no cache invalidation needed
 	}
 
+	cfg, err := m.LoadConfig(projectName)
+	if err != nil {
+		return "", err
+	}
+
 	// Fetch the branch from origin (it may not exist locally yet).
 	fetchCmd := exec.Command("git", "-C", srcRepo, "fetch", "origin", branchName)
 	fetchCmd.Stderr = nil
@@ -863,6 +909,9 @@ func (m *Manager) EnsurePRWorktree(projectName, repoName string, prNumber int, b
 
 	// Invalidate cache for this project since a new worktree was created
 	m.ClearPRCacheForProject(projectName)
+	if err := runSetup(cfg, srcRepo, dstPath); err != nil {
+		return "", err
+	}
 	return dstPath, nil
 }
 
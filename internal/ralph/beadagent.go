@@ -0,0 +1,126 @@
+package ralph
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"devdeploy/internal/agent"
+	"devdeploy/internal/beads"
+	"devdeploy/internal/config"
+)
+
+// AgentLabels are the agent settings a bead asks for through its labels:
+// model:<name>, timeout:<duration> and agent:<backend>. Zero values leave
+// the Core's setting alone.
+type AgentLabels struct {
+	Model   string
+	Timeout time.Duration
+	Agent   string // backend name
+}
+
+// ParseAgentLabels extracts the agent settings from a bead's labels. A
+// malformed or repeated setting is an error.
+func ParseAgentLabels(labels []string) (AgentLabels, error) {
+	var l AgentLabels
+	set := func(dst *string, label, value string) error {
+		if value == "" || strings.ContainsAny(value, " \t\n'\"") {
+			return fmt.Errorf("invalid label %q", label)
+		}
+		if *dst != "" {
+			return fmt.Errorf("label %q conflicts with an earlier %s label", label, strings.SplitN(label, ":", 2)[0])
+		}
+		*dst = value
+		return nil
+	}
+	for _, label := range labels {
+		var err error
+		switch {
+		case strings.HasPrefix(label, beads.LabelModelPrefix):
+			err = set(&l.Model, label, strings.TrimPrefix(label, beads.LabelModelPrefix))
+		case strings.HasPrefix(label, beads.LabelAgentPrefix):
+			err = set(&l.Agent, label, strings.TrimPrefix(label, beads.LabelAgentPrefix))
+		case strings.HasPrefix(label, beads.LabelTimeoutPrefix):
+			d, perr := time.ParseDuration(strings.TrimPrefix(label, beads.LabelTimeoutPrefix))
+			switch {
+			case perr != nil || d <= 0:
+				err = fmt.Errorf("invalid label %q: want a positive duration like timeout:30m", label)
+			case l.Timeout != 0:
+				err = fmt.Errorf("label %q conflicts with an earlier timeout label", label)
+			default:
+				l.Timeout = d
+			}
+		}
+		if err != nil {
+			return AgentLabels{}, err
+		}
+	}
+	return l, nil
+}
+
+// beadAgent is the agent setup of one attempt at a bead.
+type beadAgent struct {
+	Model     string
+	Timeout   time.Duration // zero means DefaultTimeout
+	Backend   agent.Backend // nil means the Cursor "agent" CLI
+	Escalated bool          // running on Core.EscalationModel
+}
+
+// beadAgent resolves the agent for attempt (1 for the first) at bead: the
+// Core's settings, overridden by the bead's labels, and on a retry after a
+// failure by EscalationModel. A bead that picks another backend without
+// picking a model gets that CLI's default model, since Core.Model names a
+// model of Core.Backend.
+func (c *Core) beadAgent(bead *beads.Bead, attempt int) (beadAgent, error) {
+	a := beadAgent{Model: c.Model, Timeout: c.AgentTimeout, Backend: c.Backend}
+	labels, err := ParseAgentLabels(bead.Labels)
+	if err != nil {
+		return a, err
+	}
+	if labels.Agent != "" && labels.Agent != backendName(c.Backend) {
+		backendByName := c.BackendByName
+		if backendByName == nil {
+			backendByName = func(name string) (agent.Backend, error) {
+				return agent.FromConfig(config.AgentConfig{Backend: name})
+			}
+		}
+		if a.Backend, err = backendByName(labels.Agent); err != nil {
+			return a, fmt.Errorf("label %s%s: %w", beads.LabelAgentPrefix, labels.Agent, err)
+		}
+		a.Model = ""
+	}
+	if labels.Model != "" {
+		a.Model = labels.Model
+	}
+	if labels.Timeout > 0 {
+		a.Timeout = labels.Timeout
+	}
+	if attempt > 1 && c.EscalationModel != "" {
+		a.Model, a.Escalated = c.EscalationModel, true
+	}
+	return a, nil
+}
+
+// backendName returns b's name; nil is the Cursor CLI.
+func backendName(b agent.Backend) string {
+	if b == nil {
+		return agent.BackendCursor
+	}
+	return b.Name()
+}
+
+// options returns the RunAgent options selecting the attempt's model,
+// timeout and backend.
+func (a beadAgent) options() []Option {
+	var opts []Option
+	if a.Timeout > 0 {
+		opts = append(opts, WithTimeout(a.Timeout))
+	}
+	if a.Model != "" {
+		opts = append(opts, WithModel(a.Model))
+	}
+	if a.Backend != nil {
+		opts = append(opts, WithBackend(a.Backend))
+	}
+	return opts
+}
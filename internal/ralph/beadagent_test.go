@@ -0,0 +1,195 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"devdeploy/internal/agent"
+	"devdeploy/internal/beads"
+)
+
+func TestParseAgentLabels(t *testing.T) {
+	tests := []struct {
+		labels  []string
+		want    AgentLabels
+		wantErr string
+	}{
+		{labels: nil, want: AgentLabels{}},
+		{labels: []string{"needs-human", "pr:12"}, want: AgentLabels{}},
+		{
+			labels: []string{"model:claude-4.5-opus-high-thinking", "timeout:30m", "agent:claude"},
+			want:   AgentLabels{Model: "claude-4.5-opus-high-thinking", Timeout: 30 * time.Minute, Agent: "claude"},
+		},
+		{labels: []string{"timeout:soon"}, wantErr: "positive duration"},
+		{labels: []string{"timeout:-1m"}, wantErr: "positive duration"},
+		{labels: []string{"model:"}, wantErr: "invalid label"},
+		{labels: []string{"model:a", "model:b"}, wantErr: "conflicts"},
+		{labels: []string{"timeout:1m", "timeout:2m"}, wantErr: "conflicts"},
+	}
+	for _, tt := range tests {
+		got, err := ParseAgentLabels(tt.labels)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseAgentLabels(%v) error = %v, want %q", tt.labels, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAgentLabels(%v) = %+v, %v; want %+v", tt.labels, got, err, tt.want)
+		}
+	}
+}
+
+func TestCore_beadAgent(t *testing.T) {
+	c := &Core{
+		Model:           "composer-1",
+		AgentTimeout:    10 * time.Minute,
+		Backend:         agent.Cursor{},
+		EscalationModel: "opus",
+	}
+	tests := []struct {
+		name    string
+		labels  []string
+		attempt int
+		model   string
+		timeout time.Duration
+		backend string
+	}{
+		{"defaults", nil, 1, "composer-1", 10 * time.Minute, agent.BackendCursor},
+		{"labels", []string{"model:sonnet", "timeout:1h"}, 1, "sonnet", time.Hour, agent.BackendCursor},
+		{"same backend keeps the model", []string{"agent:cursor"}, 1, "composer-1", 10 * time.Minute, agent.BackendCursor},
+		{"other backend uses its default model", []string{"agent:claude"}, 1, "", 10 * time.Minute, agent.BackendClaude},
+		{"escalated", []string{"model:sonnet", "timeout:1h"}, 2, "opus", time.Hour, agent.BackendCursor},
+	}
+	for _, tt := range tests {
+		a, err := c.beadAgent(&beads.Bead{ID: "b", Labels: tt.labels}, tt.attempt)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if a.Model != tt.model || a.Timeout != tt.timeout || backendName(a.Backend) != tt.backend {
+			t.Errorf("%s: got model %q, timeout %v, backend %s; want %q, %v, %s",
+				tt.name, a.Model, a.Timeout, backendName(a.Backend), tt.model, tt.timeout, tt.backend)
+		}
+		if a.Escalated != (tt.attempt > 1) {
+			t.Errorf("%s: escalated = %v", tt.name, a.Escalated)
+		}
+	}
+
+	if _, err := c.beadAgent(&beads.Bead{ID: "b", Labels: []string{"agent:emacs"}}, 1); err == nil {
+		t.Error("unknown agent: label: want error")
+	}
+}
+
+// attemptObserver records the model and attempt of every finished bead.
+type attemptObserver struct {
+	NoopObserver
+	mu       sync.Mutex
+	attempts []string
+}
+
+func (o *attemptObserver) OnBeadComplete(r BeadResult) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.attempts = append(o.attempts, fmt.Sprintf("%s@%s#%d:%s", r.Bead.ID, r.Model, r.Attempt, r.Outcome))
+}
+
+func TestCore_Run_Escalation(t *testing.T) {
+	var mu sync.Mutex
+	closed := make(map[string]bool)
+	runBD := func(dir string, args ...string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		var entries []bdReadyEntry
+		for _, e := range []bdReadyEntry{
+			{ID: "hard", Title: "hard", Status: "open", Labels: []string{"model:sonnet"}},
+			{ID: "hopeless", Title: "hopeless", Status: "open"},
+		} {
+			if !closed[e.ID] {
+				entries = append(entries, e)
+			}
+		}
+		return json.Marshal(entries)
+	}
+	observer := &attemptObserver{}
+	var out bytes.Buffer
+	core := &Core{
+		WorkDir:                 t.TempDir(),
+		Model:                   "composer-1",
+		EscalationModel:         "opus",
+		ConsecutiveFailureLimit: -1,
+		Output:                  &out,
+		Observer:                observer,
+		RunBD:                   runBD,
+		FetchPrompt: func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+			return &PromptData{ID: beadID}, nil
+		},
+		Render: func(data *PromptData) (string, error) { return data.ID, nil },
+		Execute: func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+			return &AgentResult{}, nil
+		},
+	}
+	// "hard" only succeeds on the second attempt, "hopeless" never does.
+	tries := make(map[string]int)
+	core.AssessFn = func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		tries[beadID]++
+		if beadID == "hard" && tries[beadID] == 2 {
+			closed[beadID] = true
+			return OutcomeSuccess, ""
+		}
+		return OutcomeFailure, "still open"
+	}
+
+	result, err := core.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// Sequential: a retry starts ahead of the other ready beads.
+	want := []string{
+		"hard@sonnet#1:failure", "hard@opus#2:success",
+		"hopeless@composer-1#1:failure", "hopeless@opus#2:failure",
+	}
+	if strings.Join(observer.attempts, " ") != strings.Join(want, " ") {
+		t.Errorf("attempts = %v, want %v", observer.attempts, want)
+	}
+	if result.Iterations != 4 || result.Succeeded != 1 || result.Failed != 1 {
+		t.Errorf("iterations/succeeded/failed = %d/%d/%d, want 4/1/1", result.Iterations, result.Succeeded, result.Failed)
+	}
+	if !strings.Contains(out.String(), "[hard] escalating to opus") {
+		t.Errorf("output does not mention the escalation:\n%s", out.String())
+	}
+}
+
+func TestCore_AgentOptionsFor_Output(t *testing.T) {
+	var buf bytes.Buffer
+	tests := []struct {
+		name string
+		core *Core
+		want io.Writer
+	}{
+		{"default", &Core{}, os.Stdout},
+		{"transcripts", &Core{Transcripts: &Transcripts{}}, io.Discard},
+		{"explicit", &Core{AgentOutput: &buf, Transcripts: &Transcripts{}}, &buf},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o options
+			for _, opt := range tt.core.agentOptionsFor(beadAgent{}) {
+				opt(&o)
+			}
+			if o.stdoutWriter != tt.want {
+				t.Errorf("stdout writer = %T, want %T", o.stdoutWriter, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package ralph
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultConflictAttempts is how many agents try to resolve a merge's
+// conflicts when ConflictResolution.MaxAttempts is zero.
+const DefaultConflictAttempts = 3
+
+// Size limits for the conflict hunks kept for question beads.
+const (
+	maxConflictHunkBytes      = 4 * 1024  // per file
+	maxConflictHunkTotalBytes = 20 * 1024 // all files
+)
+
+// ConflictResolution bounds and checks the agents resolving a merge's
+// conflicts. Each attempt must leave no unmerged paths and no conflict
+// markers, and pass Verify; the failure is shown to the next attempt.
+// Once MaxAttempts agents have failed, the merge is aborted and a question
+// bead created.
+type ConflictResolution struct {
+	// MaxAttempts is how many agents may try. Zero means
+	// DefaultConflictAttempts.
+	MaxAttempts int
+
+	// Verify builds and tests a resolution in the repository, e.g. by
+	// running the verification command. Nil skips the check.
+	Verify func(ctx context.Context, dir string) error
+}
+
+func (r ConflictResolution) attempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return DefaultConflictAttempts
+}
+
+// mergeConflicts describes the conflicts a merge ran into, as git left
+// them before any agent touched the files.
+type mergeConflicts struct {
+	Files []string // unmerged paths
+	Hunks string   // their conflict regions, truncated
+}
+
+// readMergeConflicts collects the unmerged paths of repoPath and the
+// conflict regions in them.
+func readMergeConflicts(repoPath string) *mergeConflicts {
+	out, err := runGit(repoPath, "diff", "--name-only", "--diff-filter=U")
+	c := &mergeConflicts{}
+	if err != nil || out == "" {
+		return c
+	}
+	c.Files = strings.Split(out, "\n")
+	var hunks strings.Builder
+	for _, f := range c.Files {
+		regions := conflictRegions(filepath.Join(repoPath, f))
+		if regions == "" {
+			continue
+		}
+		if hunks.Len() >= maxConflictHunkTotalBytes {
+			hunks.WriteString("\n... (more files truncated)\n")
+			break
+		}
+		fmt.Fprintf(&hunks, "### %s\n\n```\n%s\n```\n\n", f, truncateText(regions, maxConflictHunkBytes))
+	}
+	c.Hunks = strings.TrimSpace(hunks.String())
+	return c
+}
+
+// conflictRegions returns the lines of path between conflict markers,
+// markers included.
+func conflictRegions(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var regions []string
+	inside := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "<<<<<<<") {
+			inside = true
+		}
+		if inside {
+			regions = append(regions, line)
+		}
+		if strings.HasPrefix(line, ">>>>>>>") {
+			inside = false
+		}
+	}
+	return strings.Join(regions, "\n")
+}
+
+// hasConflictMarkers reports whether path contains a line that starts or
+// ends a conflict region.
+func hasConflictMarkers(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return containsConflictMarkers(f)
+}
+
+// containsConflictMarkers reports whether r has a line that starts or ends
+// a conflict region.
+func containsConflictMarkers(r io.Reader) bool {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") ||
+			line == "<<<<<<<" || line == ">>>>>>>" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve has agents resolve the conflicts the strategy left in the
+// repository, up to m.resolution.attempts() of them, checking each result
+// with landingFailure and checkResolution. An attempt that abandoned the
+// operation fails and restart sets it up again for the next one. When all
+// attempts fail, abort restores the target branch, a question bead with
+// the conflicts is created and an error returned.
+func (m *mergeRequest) resolve(ctx context.Context, abort, restart func()) error {
+	base, _ := m.git("rev-parse", "HEAD")
+	conflicts := readMergeConflicts(m.repoPath)
+	attempts := m.resolution.attempts()
+
+	var failure string
+	for attempt := 1; attempt <= attempts; attempt++ {
+		prompt, err := m.tmpl.RenderConflictResolution(&ConflictResolutionData{
+			TargetBranch:    m.targetBranch,
+			SourceBranch:    m.sourceBranch,
+			RepoPath:        m.repoPath,
+			ConflictDetails: getConflictDetails(m.repoPath),
+			BeadID:          m.beadID,
+			BeadTitle:       m.beadTitle,
+			Strategy:        m.strategy,
+			Attempt:         attempt,
+			PreviousFailure: failure,
+		})
+		if err != nil {
+			abort()
+			return fmt.Errorf("rendering conflict resolution prompt: %w", err)
+		}
+
+		var opts []Option
+		if m.agentTimeout > 0 {
+			opts = append(opts, WithTimeout(m.agentTimeout))
+		}
+		opts = append(opts, m.agentOpts...)
+		result, agentErr := RunAgent(ctx, m.repoPath, prompt, opts...)
+		switch {
+		case ctx.Err() != nil:
+			abort()
+			return fmt.Errorf("merge conflict resolution: %w", ctx.Err())
+		case agentErr != nil:
+			failure = fmt.Sprintf("The agent failed to run: %v", agentErr)
+		case result.TimedOut:
+			failure = fmt.Sprintf("The agent timed out after %s without finishing.", result.Duration.Truncate(1e9))
+		default:
+			if failure = m.landingFailure(); failure != "" {
+				restart()
+			} else {
+				failure = m.checkResolution(ctx, base, conflicts.Files)
+			}
+		}
+		if failure == "" {
+			return nil
+		}
+	}
+
+	if m.beadID != "" {
+		_ = createQuestionBeadForMergeConflict(m.repoPath, m.beadID, m.targetBranch, m.sourceBranch, conflicts,
+			fmt.Sprintf("%d agent attempt(s) failed. The last one:\n\n%s", attempts, failure))
+	}
+	abort()
+	return fmt.Errorf("agent could not resolve merge conflicts after %d attempt(s): %s", attempts, firstLine(failure))
+}
+
+// checkResolution checks the repository after a resolution attempt: no
+// paths may be left unmerged, neither the conflicted files nor any file
+// changed since base may contain conflict markers (unless the source
+// branch's version has them too, e.g. a test fixture), and the resolution
+// must pass Verify. It returns what is wrong, for the next attempt's
+// prompt, or "" when the resolution holds.
+func (m *mergeRequest) checkResolution(ctx context.Context, base string, conflicted []string) string {
+	var marked []string
+	seen := make(map[string]bool)
+	for _, f := range conflicted {
+		seen[f] = true
+		if hasConflictMarkers(filepath.Join(m.repoPath, f)) {
+			marked = append(marked, f)
+		}
+	}
+	if changed, err := m.git("diff", "--name-only", base); err == nil && changed != "" {
+		for _, f := range strings.Split(changed, "\n") {
+			if !seen[f] && hasConflictMarkers(filepath.Join(m.repoPath, f)) && !m.sourceHasConflictMarkers(f) {
+				marked = append(marked, f)
+			}
+		}
+	}
+	if len(marked) > 0 {
+		return "Conflict markers remain in: " + strings.Join(marked, ", ")
+	}
+	if unmerged, _ := m.git("diff", "--name-only", "--diff-filter=U"); unmerged != "" {
+		return "These paths are still unmerged; resolve and `git add` them: " + strings.ReplaceAll(unmerged, "\n", ", ")
+	}
+	if m.resolution.Verify != nil {
+		if err := m.resolution.Verify(ctx, m.repoPath); err != nil {
+			return fmt.Sprintf("The resolution does not pass verification:\n\n%v", err)
+		}
+	}
+	// Stage what the agent fixed after resolving, so it is committed.
+	if out, err := m.git("add", "-u"); err != nil {
+		return fmt.Sprintf("Staging the resolution failed: %s", out)
+	}
+	return ""
+}
+
+// sourceHasConflictMarkers reports whether the source branch's version of
+// path contains conflict markers.
+func (m *mergeRequest) sourceHasConflictMarkers(path string) bool {
+	out, err := exec.Command("git", "-C", m.repoPath, "show", m.sourceBranch+":"+path).Output()
+	return err == nil && containsConflictMarkers(bytes.NewReader(out))
+}
+
+// mergeConflictQuestion is the description of the question bead created
+// when agents could not resolve a merge's conflicts.
+func mergeConflictQuestion(targetBranch, sourceBranch string, conflicts *mergeConflicts, failure string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Merge conflicts detected when attempting to merge **%s** into **%s**.\n\n", sourceBranch, targetBranch)
+	b.WriteString("## Context\n")
+	if failure != "" {
+		fmt.Fprintf(&b, "The merge was aborted after agents could not resolve the conflicts. %s\n\n", failure)
+	} else {
+		b.WriteString("The merge agent attempted to merge these branches but encountered conflicts that require human intervention.\n\n")
+	}
+	b.WriteString("## Conflicting Files\n")
+	for _, f := range conflicts.Files {
+		fmt.Fprintf(&b, "- %s\n", f)
+	}
+	if conflicts.Hunks != "" {
+		fmt.Fprintf(&b, "\n## Conflicts\n\n%s\n", conflicts.Hunks)
+	}
+	b.WriteString("\n## Next Steps\nPlease resolve the conflicts manually and complete the merge.")
+	return b.String()
+}
+
+// firstLine returns s up to its first line break.
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(s, "\n")
+	return s
+}
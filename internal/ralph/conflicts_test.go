@@ -0,0 +1,218 @@
+package ralph
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingAgent is a conflict resolution agent script that saves each
+// prompt to <dir>/prompt-<n> and runs attempt n's command, if any.
+func countingAgent(dir string, commands ...string) []Option {
+	script := `n=$(ls "` + dir + `" | wc -l); n=$((n + 1)); eval "printf '%s' \"\${$#}\"" > "` + dir + `/prompt-$n"
+case $n in
+`
+	for i, c := range commands {
+		script += "  " + string(rune('1'+i)) + ") " + c + " ;;\n"
+	}
+	script += "esac\n"
+	return shellAgent(script)
+}
+
+func readPrompts(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prompts []string
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		prompts = append(prompts, string(data))
+	}
+	return prompts
+}
+
+func TestMergeWithAgentResolution_RetriesMarkersLeftBehind(t *testing.T) {
+	for _, strategy := range []MergeStrategy{MergeStrategyMerge, MergeStrategySquash, MergeStrategyRebase} {
+		t.Run(string(strategy), func(t *testing.T) {
+			repo, target := setupStrategyRepo(t, true)
+			prompts := t.TempDir()
+			agent := countingAgent(prompts,
+				"git add shared.txt", // stages the file, markers and all
+				"printf 'resolved\\n' > shared.txt && git add shared.txt",
+			)
+
+			err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "", strategy, ConflictResolution{}, 0, nil, agent...)
+			if err != nil {
+				t.Fatalf("MergeWithAgentResolution: %v", err)
+			}
+			got := readPrompts(t, prompts)
+			if len(got) != 2 {
+				t.Fatalf("agent ran %d times, want 2", len(got))
+			}
+			if !strings.Contains(got[1], "attempt 2") || !strings.Contains(got[1], "Conflict markers remain in: shared.txt") {
+				t.Errorf("second prompt does not explain the first attempt's failure:\n%s", got[1])
+			}
+			if data, _ := os.ReadFile(filepath.Join(repo, "shared.txt")); string(data) != "resolved\n" {
+				t.Errorf("shared.txt = %q, want the second attempt's resolution", data)
+			}
+		})
+	}
+}
+
+func TestMergeWithAgentResolution_Verify(t *testing.T) {
+	repo, target := setupStrategyRepo(t, true)
+	prompts := t.TempDir()
+	agent := countingAgent(prompts,
+		"printf 'broken\\n' > shared.txt && git add shared.txt",
+		"printf 'fixed\\n' > shared.txt",
+	)
+	var checked []string
+	resolution := ConflictResolution{Verify: func(ctx context.Context, dir string) error {
+		data, _ := os.ReadFile(filepath.Join(dir, "shared.txt"))
+		checked = append(checked, string(data))
+		if string(data) != "fixed\n" {
+			return errors.New("go test ./...: exit status 1\n--- FAIL: TestShared")
+		}
+		return nil
+	}}
+
+	if err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "", MergeStrategyMerge, resolution, 0, nil, agent...); err != nil {
+		t.Fatalf("MergeWithAgentResolution: %v", err)
+	}
+	if len(checked) != 2 {
+		t.Errorf("verified %d resolutions, want 2", len(checked))
+	}
+	if got := readPrompts(t, prompts); len(got) != 2 || !strings.Contains(got[1], "--- FAIL: TestShared") {
+		t.Errorf("second prompt does not carry the verification output: %q", got)
+	}
+	// The fix after the verification failure is part of the merge commit.
+	if got := gitOutput(t, repo, "show", "HEAD:shared.txt"); got != "fixed" {
+		t.Errorf("merged shared.txt = %q, want fixed", got)
+	}
+	if n := len(strings.Fields(gitOutput(t, repo, "rev-list", "--parents", "-n", "1", "HEAD"))); n != 3 {
+		t.Error("HEAD is not a merge commit")
+	}
+	if status := gitOutput(t, repo, "status", "--porcelain"); status != "" {
+		t.Errorf("repository not clean after the merge:\n%s", status)
+	}
+}
+
+func TestMergeWithAgentResolution_GivesUpAfterMaxAttempts(t *testing.T) {
+	repo, target := setupStrategyRepo(t, true)
+	oldHead := gitOutput(t, repo, "rev-parse", "HEAD")
+	prompts := t.TempDir()
+	resolution := ConflictResolution{
+		MaxAttempts: 2,
+		Verify:      func(ctx context.Context, dir string) error { return errors.New("build failed") },
+	}
+	agent := countingAgent(prompts, "printf 'one\\n' > shared.txt && git add shared.txt", "printf 'two\\n' > shared.txt")
+
+	err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "", MergeStrategyRebase, resolution, 0, nil, agent...)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempt(s)") {
+		t.Fatalf("err = %v, want a failure after 2 attempts", err)
+	}
+	if got := readPrompts(t, prompts); len(got) != 2 {
+		t.Errorf("agent ran %d times, want 2", len(got))
+	}
+	if got := gitOutput(t, repo, "rev-parse", "HEAD"); got != oldHead {
+		t.Errorf("HEAD moved to %s after a failed merge", got)
+	}
+	if status := gitOutput(t, repo, "status", "--porcelain"); status != "" {
+		t.Errorf("failed merge not aborted:\n%s", status)
+	}
+}
+
+func TestMergeWithAgentResolution_AgentAborts(t *testing.T) {
+	aborts := map[MergeStrategy]string{
+		MergeStrategyMerge:  "git merge --abort",
+		MergeStrategySquash: "git reset --hard",
+		MergeStrategyRebase: "git cherry-pick --abort",
+	}
+	for strategy, abort := range aborts {
+		t.Run(string(strategy)+"/then resolves", func(t *testing.T) {
+			repo, target := setupStrategyRepo(t, true)
+			prompts := t.TempDir()
+			agent := countingAgent(prompts, abort, "printf 'resolved\\n' > shared.txt && git add shared.txt")
+
+			err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "Share", strategy, ConflictResolution{}, 0, nil, agent...)
+			if err != nil {
+				t.Fatalf("MergeWithAgentResolution: %v", err)
+			}
+			got := readPrompts(t, prompts)
+			if len(got) != 2 || !strings.Contains(got[1], "instead of") {
+				t.Fatalf("second prompt does not explain the abort: %q", got)
+			}
+			if data := gitOutput(t, repo, "show", "HEAD:shared.txt"); data != "resolved" {
+				t.Errorf("landed shared.txt = %q, want the second attempt's resolution", data)
+			}
+		})
+
+		t.Run(string(strategy)+"/always", func(t *testing.T) {
+			repo, target := setupStrategyRepo(t, true)
+			oldHead := gitOutput(t, repo, "rev-parse", "HEAD")
+			resolution := ConflictResolution{MaxAttempts: 2}
+			err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "", strategy, resolution, 0, nil, shellAgent(abort)...)
+			if err == nil || !strings.Contains(err.Error(), "after 2 attempt(s)") {
+				t.Fatalf("err = %v, want a failure after 2 attempts", err)
+			}
+			if got := gitOutput(t, repo, "rev-parse", "HEAD"); got != oldHead {
+				t.Errorf("HEAD moved to %s, but nothing landed", got)
+			}
+			if status := gitOutput(t, repo, "status", "--porcelain"); status != "" {
+				t.Errorf("failed merge not cleaned up:\n%s", status)
+			}
+		})
+	}
+}
+
+func TestMergeConflictQuestion(t *testing.T) {
+	repo, target := setupStrategyRepo(t, true)
+	_, _ = runGit(repo, "merge", "ralph/b-1")
+	conflicts := readMergeConflicts(repo)
+	_, _ = runGit(repo, "merge", "--abort")
+
+	if len(conflicts.Files) != 1 || conflicts.Files[0] != "shared.txt" {
+		t.Fatalf("conflicting files = %v, want [shared.txt]", conflicts.Files)
+	}
+	got := mergeConflictQuestion(target, "ralph/b-1", conflicts, "2 agent attempt(s) failed. The last one:\n\nbuild failed")
+	for _, want := range []string{
+		"merge **ralph/b-1** into **" + target + "**",
+		"- shared.txt",
+		"### shared.txt",
+		"<<<<<<< HEAD\ntarget\n=======\nbranch\n>>>>>>> ralph/b-1",
+		"build failed",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("question description missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestHasConflictMarkers(t *testing.T) {
+	dir := t.TempDir()
+	for name, tt := range map[string]struct {
+		content string
+		want    bool
+	}{
+		"clean":     {"package x\n// ======= is fine\n", false},
+		"start":     {"a\n<<<<<<< HEAD\nb\n", true},
+		"end":       {"a\n>>>>>>> ralph/x\n", true},
+		"separator": {"Title\n=======\n", false},
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := hasConflictMarkers(path); got != tt.want {
+			t.Errorf("%s: hasConflictMarkers = %v, want %v", name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package ralph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"devdeploy/internal/agent"
+	"devdeploy/internal/beads"
+	"devdeploy/internal/trace"
+)
+
+// MaxControlParallel is the largest MaxParallel a Controller accepts.
+const MaxControlParallel = 32
+
+// maxControlEvents caps the trace events a Controller keeps to replay to
+// new subscribers.
+const maxControlEvents = 10000
+
+// Controller lets another process see and steer a running Core through
+// the control API (see ControlHandler): status, in-flight beads,
+// cancelling a bead's agent, pausing and resuming scheduling, changing
+// MaxParallel and streaming trace events. Set it as Core.Control; one
+// Controller serves one Run at a time.
+type Controller struct {
+	mu          sync.Mutex
+	status      ControlStatus
+	worktrees   bool // the run uses worktrees, so it can run beads in parallel
+	inFlight    map[string]*controlBead
+	settled     map[string]Outcome
+	spans       map[string]string // iteration and tool span ID -> bead ID
+	events      []trace.TraceEvent
+	subscribers map[chan trace.TraceEvent]bool
+	changed     chan struct{}
+}
+
+// ControlStatus is the state of a run as reported by the control API.
+type ControlStatus struct {
+	WorkDir     string        `json:"workdir"`
+	RootBead    string        `json:"root_bead,omitempty"`
+	RunID       string        `json:"run_id,omitempty"`
+	State       string        `json:"state"` // ControlRunning, ControlPaused or ControlDone
+	MaxParallel int           `json:"max_parallel"`
+	StartedAt   time.Time     `json:"started_at"`
+	Iterations  int           `json:"iterations"`
+	Succeeded   int           `json:"succeeded"`
+	Questions   int           `json:"questions"`
+	Failed      int           `json:"failed"`
+	TimedOut    int           `json:"timed_out"`
+	Usage       agent.Usage   `json:"usage"`
+	StopReason  *StopReason   `json:"stop_reason,omitempty"`
+	InFlight    []ControlBead `json:"in_flight"`
+	// Settled holds the outcome of every bead that finished in the run.
+	Settled map[string]Outcome `json:"settled,omitempty"`
+}
+
+// Run states in ControlStatus.State.
+const (
+	ControlRunning = "running"
+	ControlPaused  = "paused"
+	ControlDone    = "done"
+)
+
+// ControlBead is a bead whose agent is running.
+type ControlBead struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// Tool describes the agent's current tool call, if any.
+	Tool string `json:"tool,omitempty"`
+}
+
+type controlBead struct {
+	ControlBead
+	cancel   context.CancelFunc
+	toolSpan string // span of the call in Tool
+}
+
+// NewController returns a Controller for a Core that has not run yet.
+func NewController() *Controller {
+	return &Controller{
+		status:      ControlStatus{State: ControlDone},
+		inFlight:    make(map[string]*controlBead),
+		settled:     make(map[string]Outcome),
+		spans:       make(map[string]string),
+		subscribers: make(map[chan trace.TraceEvent]bool),
+		changed:     make(chan struct{}, 1),
+	}
+}
+
+// Status returns the run's current state.
+func (ctl *Controller) Status() ControlStatus {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	s := ctl.status
+	s.InFlight = ctl.inFlightLocked()
+	if len(ctl.settled) > 0 {
+		s.Settled = maps.Clone(ctl.settled)
+	}
+	return s
+}
+
+// InFlight returns the beads whose agents are running, oldest first.
+func (ctl *Controller) InFlight() []ControlBead {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	return ctl.inFlightLocked()
+}
+
+func (ctl *Controller) inFlightLocked() []ControlBead {
+	list := make([]ControlBead, 0, len(ctl.inFlight))
+	for _, b := range ctl.inFlight {
+		list = append(list, b.ControlBead)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
+
+// ErrUnknownBead is returned by Cancel for a bead that is not running.
+var ErrUnknownBead = errors.New("bead is not running")
+
+// Cancel kills the agent working on beadID. The bead counts as failed and
+// is not started again in this run.
+func (ctl *Controller) Cancel(beadID string) error {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	b, ok := ctl.inFlight[beadID]
+	if !ok {
+		return ErrUnknownBead
+	}
+	b.cancel()
+	return nil
+}
+
+// Pause stops the run from starting beads; running agents and merges
+// finish.
+func (ctl *Controller) Pause() { ctl.setPaused(true) }
+
+// Resume undoes Pause.
+func (ctl *Controller) Resume() { ctl.setPaused(false) }
+
+func (ctl *Controller) setPaused(paused bool) {
+	ctl.mu.Lock()
+	if ctl.status.State != ControlDone {
+		ctl.status.State = ControlRunning
+		if paused {
+			ctl.status.State = ControlPaused
+		}
+	}
+	ctl.mu.Unlock()
+	ctl.notify()
+}
+
+// SetMaxParallel changes the number of agents the run keeps busy. Beads
+// beyond a lowered limit finish; no new ones start until the run is below
+// it.
+func (ctl *Controller) SetMaxParallel(n int) error {
+	if n < 1 || n > MaxControlParallel {
+		return fmt.Errorf("max parallel must be between 1 and %d", MaxControlParallel)
+	}
+	ctl.mu.Lock()
+	switch {
+	case ctl.status.State == ControlDone:
+		ctl.mu.Unlock()
+		return errors.New("no run in progress")
+	case n > 1 && !ctl.worktrees:
+		ctl.mu.Unlock()
+		return errors.New("the run started sequentially, without worktrees")
+	}
+	ctl.status.MaxParallel = n
+	ctl.mu.Unlock()
+	ctl.notify()
+	return nil
+}
+
+// notify wakes the scheduler to apply a change.
+func (ctl *Controller) notify() {
+	select {
+	case ctl.changed <- struct{}{}:
+	default: // already pending
+	}
+}
+
+// Subscribe returns a channel of the run's trace events, starting with
+// those already emitted, and a function that ends the subscription. A
+// subscriber that falls too far behind is dropped: its channel is closed.
+func (ctl *Controller) Subscribe() (<-chan trace.TraceEvent, func()) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	ch := make(chan trace.TraceEvent, len(ctl.events)+256)
+	for _, e := range ctl.events {
+		ch <- e
+	}
+	ctl.subscribers[ch] = true
+	return ch, func() {
+		ctl.mu.Lock()
+		defer ctl.mu.Unlock()
+		if ctl.subscribers[ch] {
+			delete(ctl.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// The methods below are called by Core.
+
+// start resets the Controller for a new run.
+func (ctl *Controller) start(c *Core, worktrees bool) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	ctl.status = ControlStatus{
+		WorkDir:     c.WorkDir,
+		RootBead:    c.RootBead,
+		State:       ControlRunning,
+		MaxParallel: max(c.MaxParallel, 1),
+		StartedAt:   time.Now(),
+	}
+	if c.Journal != nil {
+		ctl.status.RunID = c.Journal.RunID()
+	}
+	ctl.worktrees = worktrees
+	clear(ctl.inFlight)
+	clear(ctl.settled)
+	clear(ctl.spans)
+	ctl.events = nil
+}
+
+// finish records the end of the run.
+func (ctl *Controller) finish(result *RunSummary) {
+	ctl.update(result)
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	ctl.status.State = ControlDone
+	reason := result.StopReason
+	ctl.status.StopReason = &reason
+}
+
+// update copies the run's progress into the status.
+func (ctl *Controller) update(result *RunSummary) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	ctl.status.Iterations = result.Iterations
+	ctl.status.Succeeded = result.Succeeded
+	ctl.status.Questions = result.Questions
+	ctl.status.Failed = result.Failed
+	ctl.status.TimedOut = result.TimedOut
+	ctl.status.Usage = result.Usage
+}
+
+// settings returns what the scheduler needs each round.
+func (ctl *Controller) settings() (maxParallel int, paused bool) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	return ctl.status.MaxParallel, ctl.status.State == ControlPaused
+}
+
+// beadStarted registers a running bead and returns the context its agent
+// runs under, which Cancel cancels.
+func (ctl *Controller) beadStarted(ctx context.Context, b beads.Bead) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	ctl.inFlight[b.ID] = &controlBead{ControlBead: ControlBead{ID: b.ID, Title: b.Title, StartedAt: time.Now()}, cancel: cancel}
+	delete(ctl.settled, b.ID) // retried
+	return ctx, cancel
+}
+
+// beadDone records the outcome of a bead whose agent finished.
+func (ctl *Controller) beadDone(beadID string, outcome Outcome) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	delete(ctl.inFlight, beadID)
+	ctl.settled[beadID] = outcome
+}
+
+// emit records a trace event and sends it to subscribers.
+func (ctl *Controller) emit(e trace.TraceEvent) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	switch e.Type {
+	case trace.EventIterationStart:
+		ctl.spans[e.SpanID] = e.Attributes["bead_id"]
+	case trace.EventIterationEnd:
+		delete(ctl.spans, e.SpanID)
+	case trace.EventToolStart:
+		beadID := ctl.spans[e.ParentID]
+		ctl.spans[e.SpanID] = beadID
+		if b, ok := ctl.inFlight[beadID]; ok {
+			b.Tool, b.toolSpan = describeToolEvent(e), e.SpanID
+		}
+	case trace.EventToolEnd:
+		if b, ok := ctl.inFlight[ctl.spans[e.SpanID]]; ok && b.toolSpan == e.SpanID {
+			b.Tool, b.toolSpan = "", ""
+		}
+		delete(ctl.spans, e.SpanID)
+	}
+	if len(ctl.events) < maxControlEvents {
+		ctl.events = append(ctl.events, e)
+	}
+	for ch := range ctl.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(ctl.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// describeToolEvent summarizes a tool call as "name argument".
+func describeToolEvent(e trace.TraceEvent) string {
+	for _, k := range []string{"file_path", "command", "pattern", "query"} {
+		if v := e.Attributes[k]; v != "" {
+			return e.Name + " " + v
+		}
+	}
+	return e.Name
+}
+
+// tracer returns a TraceEmitter that reports to the Controller's
+// subscribers and forwards to next, which may be nil.
+func (ctl *Controller) tracer(next TraceEmitter) TraceEmitter {
+	return &controlTracer{ctl: ctl, next: next}
+}
+
+// controlTracer turns TraceEmitter calls into trace events for a
+// Controller. It reuses the span IDs next hands out, so both see the same
+// spans.
+type controlTracer struct {
+	ctl  *Controller
+	next TraceEmitter
+
+	mu       sync.Mutex
+	traceID  string
+	loopSpan string
+}
+
+func (t *controlTracer) event(typ trace.EventType, spanID, parentID, name string, attrs map[string]string) {
+	t.mu.Lock()
+	traceID := t.traceID
+	t.mu.Unlock()
+	t.ctl.emit(trace.TraceEvent{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		ParentID:   parentID,
+		Type:       typ,
+		Name:       name,
+		Timestamp:  time.Now(),
+		Attributes: attrs,
+	})
+}
+
+// spanID returns id, or a new span ID if next handed out none.
+func spanID(id string) string {
+	if id == "" {
+		return trace.NewSpanID()
+	}
+	return id
+}
+
+func (t *controlTracer) StartLoop(model, epic, workdir string, maxIterations int) string {
+	traceID := trace.NewTraceID()
+	if t.next != nil {
+		if id := t.next.StartLoop(model, epic, workdir, maxIterations); id != "" {
+			traceID = id
+		}
+	}
+	t.mu.Lock()
+	t.traceID, t.loopSpan = traceID, trace.NewSpanID()
+	loopSpan := t.loopSpan
+	t.mu.Unlock()
+	t.event(trace.EventLoopStart, loopSpan, "", "ralph-loop", map[string]string{
+		"model":          model,
+		"epic":           epic,
+		"workdir":        workdir,
+		"max_iterations": fmt.Sprintf("%d", maxIterations),
+	})
+	return traceID
+}
+
+func (t *controlTracer) EndLoopWithAttrs(stopReason string, iterations, succeeded, failed int, extraAttrs map[string]string) {
+	if t.next != nil {
+		t.next.EndLoopWithAttrs(stopReason, iterations, succeeded, failed, extraAttrs)
+	}
+	attrs := map[string]string{
+		"stop_reason": stopReason,
+		"iterations":  fmt.Sprintf("%d", iterations),
+		"succeeded":   fmt.Sprintf("%d", succeeded),
+		"failed":      fmt.Sprintf("%d", failed),
+	}
+	for k, v := range extraAttrs {
+		attrs[k] = v
+	}
+	t.event(trace.EventLoopEnd, t.loop(), "", "ralph-loop", attrs)
+}
+
+func (t *controlTracer) StartIteration(beadID, beadTitle string, iterNum int) string {
+	var id string
+	if t.next != nil {
+		id = t.next.StartIteration(beadID, beadTitle, iterNum)
+	}
+	id = spanID(id)
+	t.event(trace.EventIterationStart, id, t.loop(), fmt.Sprintf("iteration-%d", iterNum), map[string]string{
+		"bead_id":    beadID,
+		"bead_title": beadTitle,
+		"iteration":  fmt.Sprintf("%d", iterNum),
+	})
+	return id
+}
+
+func (t *controlTracer) EndIterationWithAttrs(spanID string, outcome string, durationMs int64, extraAttrs map[string]string) {
+	if t.next != nil {
+		t.next.EndIterationWithAttrs(spanID, outcome, durationMs, extraAttrs)
+	}
+	attrs := map[string]string{
+		"outcome":     outcome,
+		"duration_ms": fmt.Sprintf("%d", durationMs),
+	}
+	for k, v := range extraAttrs {
+		attrs[k] = v
+	}
+	t.event(trace.EventIterationEnd, spanID, t.loop(), "iteration-end", attrs)
+}
+
+func (t *controlTracer) StartToolWithParent(toolName string, attrs map[string]string, parentSpanID string) string {
+	var id string
+	if t.next != nil {
+		id = t.next.StartToolWithParent(toolName, attrs, parentSpanID)
+	}
+	id = spanID(id)
+	t.event(trace.EventToolStart, id, parentSpanID, toolName, attrs)
+	return id
+}
+
+func (t *controlTracer) EndTool(spanID string, attrs map[string]string) {
+	if t.next != nil {
+		t.next.EndTool(spanID, attrs)
+	}
+	t.event(trace.EventToolEnd, spanID, "", "tool-end", attrs)
+}
+
+func (t *controlTracer) loop() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.loopSpan
+}
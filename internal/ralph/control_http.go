@@ -0,0 +1,277 @@
+package ralph
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"devdeploy/internal/trace"
+)
+
+// The control API is JSON over HTTP on a unix socket:
+//
+//	GET  /status                   ControlStatus
+//	GET  /beads                    []ControlBead
+//	POST /beads/{id}/cancel        cancel the bead's agent
+//	POST /pause, POST /resume      stop or restart scheduling new beads
+//	PUT  /max-parallel             {"max_parallel": n}
+//	GET  /events                   trace.TraceEvent per line, as they happen
+//
+// Errors are {"error": "..."} with a 4xx status.
+
+// ControlSocketPath returns the control socket of the ralph run in
+// workDir. It lives in the temp dir, named after the absolute workDir, so
+// that devdeploy finds the socket of a loop it started and the path stays
+// within the unix socket length limit.
+func ControlSocketPath(workDir string) string {
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+	sum := sha256.Sum256([]byte(workDir))
+	return filepath.Join(os.TempDir(), "ralph-"+hex.EncodeToString(sum[:6])+".sock")
+}
+
+// ListenControl listens on the unix socket at path. A socket left behind
+// by a ralph that is gone is replaced; one that still answers is an error.
+func ListenControl(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another ralph is listening on %s", path)
+	}
+	_ = os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	return l, nil
+}
+
+// Serve answers control API requests on l until ctx is done, then closes
+// l and removes its socket file.
+func (ctl *Controller) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:     ctl.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	err := srv.Serve(l)
+	if addr, ok := l.Addr().(*net.UnixAddr); ok {
+		_ = os.Remove(addr.Name)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Handler returns the control API's HTTP handler.
+func (ctl *Controller) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ctl.Status())
+	})
+	mux.HandleFunc("GET /beads", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ctl.InFlight())
+	})
+	mux.HandleFunc("POST /beads/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		if err := ctl.Cancel(r.PathValue("id")); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		ctl.Pause()
+		writeJSON(w, http.StatusOK, ctl.Status())
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		ctl.Resume()
+		writeJSON(w, http.StatusOK, ctl.Status())
+	})
+	mux.HandleFunc("PUT /max-parallel", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			MaxParallel int `json:"max_parallel"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := ctl.SetMaxParallel(req.MaxParallel); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, ctl.Status())
+	})
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		events, unsubscribe := ctl.Subscribe()
+		defer unsubscribe()
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		for {
+			if flusher != nil && len(events) == 0 {
+				flusher.Flush()
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					return // fell behind
+				}
+				if enc.Encode(e) != nil {
+					return
+				}
+			}
+		}
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// ControlClient talks to a ralph run's control API.
+type ControlClient struct {
+	http *http.Client
+}
+
+// NewControlClient returns a client for the control socket at path, e.g.
+// ControlSocketPath(workDir).
+func NewControlClient(path string) *ControlClient {
+	return &ControlClient{http: &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}}
+}
+
+// Status returns the run's state.
+func (c *ControlClient) Status(ctx context.Context) (*ControlStatus, error) {
+	var s ControlStatus
+	if err := c.do(ctx, http.MethodGet, "/status", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// InFlight returns the beads whose agents are running.
+func (c *ControlClient) InFlight(ctx context.Context) ([]ControlBead, error) {
+	var list []ControlBead
+	if err := c.do(ctx, http.MethodGet, "/beads", nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Cancel kills the agent working on beadID.
+func (c *ControlClient) Cancel(ctx context.Context, beadID string) error {
+	return c.do(ctx, http.MethodPost, "/beads/"+beadID+"/cancel", nil, nil)
+}
+
+// Pause stops the run from starting beads.
+func (c *ControlClient) Pause(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/pause", nil, nil)
+}
+
+// Resume undoes Pause.
+func (c *ControlClient) Resume(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/resume", nil, nil)
+}
+
+// SetMaxParallel changes the number of agents the run keeps busy.
+func (c *ControlClient) SetMaxParallel(ctx context.Context, n int) error {
+	return c.do(ctx, http.MethodPut, "/max-parallel", map[string]int{"max_parallel": n}, nil)
+}
+
+// Events calls fn with every trace event of the run, starting with those
+// already emitted, until ctx is done or the run's server goes away.
+func (c *ControlClient) Events(ctx context.Context, fn func(trace.TraceEvent)) error {
+	resp, err := c.request(ctx, http.MethodGet, "/events", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e trace.TraceEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("decoding event: %w", err)
+		}
+		fn(e)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return sc.Err()
+}
+
+func (c *ControlClient) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// request sends a request and turns error responses into errors.
+func (c *ControlClient) request(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	// The host is ignored: the transport always dials the socket.
+	req, err := http.NewRequestWithContext(ctx, method, "http://ralph"+path, r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return nil, errors.New(e.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,385 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"devdeploy/internal/beads"
+	"devdeploy/internal/trace"
+)
+
+// serveTestControl serves ctl on a socket in a temp dir and returns a
+// client for it.
+func serveTestControl(t *testing.T, ctl *Controller) *ControlClient {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "ralph.sock")
+	l, err := ListenControl(socket)
+	if err != nil {
+		t.Fatalf("ListenControl: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = ctl.Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+	})
+	if _, err := ListenControl(socket); err == nil {
+		t.Error("second ListenControl on a live socket: want error")
+	}
+	return NewControlClient(socket)
+}
+
+// waitUntil polls cond until it holds or a few seconds passed.
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCore_Run_Control(t *testing.T) {
+	bd := newFakeReadyBD([]string{"b-1", "b-2"}, nil)
+	ctl := NewController()
+	client := serveTestControl(t, ctl)
+	ctx := context.Background()
+
+	core := &Core{
+		WorkDir:     t.TempDir(),
+		MaxParallel: 1,
+		Output:      &bytes.Buffer{},
+		Control:     ctl,
+		RunBD:       bd.run,
+		FetchPrompt: func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+			return &PromptData{ID: beadID, Title: beadID}, nil
+		},
+		Render: func(data *PromptData) (string, error) { return data.ID, nil },
+		Execute: func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+			if prompt == "b-1" {
+				<-ctx.Done() // runs until cancelled
+				return &AgentResult{ExitCode: -1}, nil
+			}
+			return &AgentResult{}, nil
+		},
+		AssessFn: func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+			if r.ExitCode != 0 {
+				return OutcomeFailure, "killed"
+			}
+			bd.close(beadID)
+			return OutcomeSuccess, ""
+		},
+	}
+	type runResult struct {
+		summary *RunSummary
+		err     error
+	}
+	done := make(chan runResult, 1)
+	go func() {
+		s, err := core.Run(ctx)
+		done <- runResult{s, err}
+	}()
+
+	waitUntil(t, "b-1 to start", func() bool {
+		list, err := client.InFlight(ctx)
+		return err == nil && len(list) == 1 && list[0].ID == "b-1"
+	})
+	if err := client.Pause(ctx); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := client.SetMaxParallel(ctx, 2); err == nil {
+		t.Error("SetMaxParallel(2) on a sequential run: want error")
+	}
+	if err := client.Cancel(ctx, "b-9"); err == nil {
+		t.Error("Cancel of a bead that is not running: want error")
+	}
+	if err := client.Cancel(ctx, "b-1"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	// Paused: b-2 waits.
+	waitUntil(t, "b-1 to finish", func() bool {
+		s, err := client.Status(ctx)
+		return err == nil && s.Failed == 1
+	})
+	time.Sleep(20 * time.Millisecond)
+	s, err := client.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if s.State != ControlPaused || s.Iterations != 1 || len(s.InFlight) != 0 {
+		t.Errorf("paused status = %+v, want paused after 1 iteration, nothing in flight", s)
+	}
+
+	if err := client.Resume(ctx); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	var r runResult
+	select {
+	case r = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not finish after Resume")
+	}
+	if r.err != nil {
+		t.Fatalf("Run: %v", r.err)
+	}
+	if r.summary.Succeeded != 1 || r.summary.Failed != 1 {
+		t.Errorf("succeeded/failed = %d/%d, want 1/1", r.summary.Succeeded, r.summary.Failed)
+	}
+	s, _ = client.Status(ctx)
+	if s == nil || s.State != ControlDone || s.StopReason == nil {
+		t.Fatalf("final status = %+v, want done with a stop reason", s)
+	}
+	if s.Settled["b-1"] != OutcomeFailure || s.Settled["b-2"] != OutcomeSuccess {
+		t.Errorf("settled = %v, want b-1 failure, b-2 success", s.Settled)
+	}
+
+	// A late subscriber gets the whole run.
+	evCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	var types []trace.EventType
+	_ = client.Events(evCtx, func(e trace.TraceEvent) { types = append(types, e.Type) })
+	want := []trace.EventType{
+		trace.EventLoopStart,
+		trace.EventIterationStart, trace.EventIterationEnd,
+		trace.EventIterationStart, trace.EventIterationEnd,
+		trace.EventLoopEnd,
+	}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+}
+
+func TestCore_Run_ControlRaisesMaxParallel(t *testing.T) {
+	prefix := fmt.Sprintf("ctl%d", time.Now().UnixNano())
+	ids := []string{prefix + "-1", prefix + "-2", prefix + "-3"}
+	bd := newFakeReadyBD(ids, nil)
+	c := newSchedulerTestCore(t, bd)
+	c.Control = NewController()
+
+	var started atomic.Int32
+	release := make(chan struct{})
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		started.Add(1)
+		<-release
+		return &AgentResult{}, nil
+	}
+	c.AssessFn = func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Run(context.Background())
+		done <- err
+	}()
+
+	waitUntil(t, "two beads to start", func() bool { return started.Load() == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if n := started.Load(); n != 2 {
+		t.Fatalf("%d beads started with MaxParallel 2", n)
+	}
+	if err := c.Control.SetMaxParallel(3); err != nil {
+		t.Fatalf("SetMaxParallel: %v", err)
+	}
+	waitUntil(t, "the third bead to start", func() bool { return started.Load() == 3 })
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestController_SetMaxParallel(t *testing.T) {
+	ctl := NewController()
+	if err := ctl.SetMaxParallel(2); err == nil {
+		t.Error("SetMaxParallel with no run in progress: want error")
+	}
+
+	tests := []struct {
+		name      string
+		worktrees bool
+		n         int
+		wantErr   bool
+	}{
+		{"zero", true, 0, true},
+		{"negative", true, -1, true},
+		{"one", true, 1, false},
+		{"limit", true, MaxControlParallel, false},
+		{"above limit", true, MaxControlParallel + 1, true},
+		{"sequential run", false, 2, true},
+		{"sequential run stays sequential", false, 1, false},
+	}
+	for _, tt := range tests {
+		ctl.start(&Core{MaxParallel: 4}, tt.worktrees)
+		err := ctl.SetMaxParallel(tt.n)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: SetMaxParallel(%d) = %v, want error %v", tt.name, tt.n, err, tt.wantErr)
+		}
+		want := 4
+		if !tt.wantErr {
+			want = tt.n
+		}
+		if got, _ := ctl.settings(); got != want {
+			t.Errorf("%s: max parallel = %d, want %d", tt.name, got, want)
+		}
+	}
+}
+
+func TestControlClient_PauseResumeCancel(t *testing.T) {
+	ctl := NewController()
+	client := serveTestControl(t, ctl)
+	ctx := context.Background()
+
+	// Before and after a run there is nothing to pause.
+	if err := client.Pause(ctx); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if s, err := client.Status(ctx); err != nil || s.State != ControlDone {
+		t.Fatalf("status before the run = %+v, %v; want done", s, err)
+	}
+
+	ctl.start(&Core{WorkDir: "/repo", MaxParallel: 2}, true)
+	agentCtx, cancelAgent := ctl.beadStarted(ctx, beads.Bead{ID: "b-1", Title: "first"})
+	defer cancelAgent()
+	for _, step := range []struct {
+		do   func(context.Context) error
+		want string
+	}{
+		{client.Pause, ControlPaused},
+		{client.Pause, ControlPaused},
+		{client.Resume, ControlRunning},
+		{client.Pause, ControlPaused},
+	} {
+		if err := step.do(ctx); err != nil {
+			t.Fatalf("%s: %v", step.want, err)
+		}
+		s, err := client.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+		if s.State != step.want {
+			t.Errorf("state = %s, want %s", s.State, step.want)
+		}
+	}
+	if _, paused := ctl.settings(); !paused {
+		t.Error("scheduler does not see the pause")
+	}
+
+	if err := client.Cancel(ctx, "b-2"); err == nil || !strings.Contains(err.Error(), ErrUnknownBead.Error()) {
+		t.Errorf("Cancel of a bead that is not running = %v, want %q", err, ErrUnknownBead)
+	}
+	if agentCtx.Err() != nil {
+		t.Fatal("agent cancelled by cancelling another bead")
+	}
+	if err := client.Cancel(ctx, "b-1"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if !errors.Is(agentCtx.Err(), context.Canceled) {
+		t.Errorf("agent context err = %v, want cancelled", agentCtx.Err())
+	}
+
+	ctl.beadDone("b-1", OutcomeFailure)
+	ctl.finish(&RunSummary{Iterations: 1, Failed: 1})
+	if err := client.Resume(ctx); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	s, err := client.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if s.State != ControlDone || len(s.InFlight) != 0 || s.Settled["b-1"] != OutcomeFailure {
+		t.Errorf("status after the run = %+v, want done with b-1 failed", s)
+	}
+}
+
+func TestController_Handler_BadRequests(t *testing.T) {
+	ctl := NewController()
+	ctl.start(&Core{MaxParallel: 2}, true)
+	h := ctl.Handler()
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{http.MethodPut, "/max-parallel", "{", http.StatusBadRequest},
+		{http.MethodPut, "/max-parallel", `{"max_parallel": "3"}`, http.StatusBadRequest},
+		{http.MethodPut, "/max-parallel", `{"max_parallel": 0}`, http.StatusConflict},
+		{http.MethodPut, "/max-parallel", `{}`, http.StatusConflict},
+		{http.MethodPost, "/beads/b-1/cancel", "", http.StatusNotFound},
+		{http.MethodGet, "/pause", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/status", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/nope", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s %s: status %d, want %d", tt.method, tt.path, tt.body, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus == http.StatusBadRequest || tt.wantStatus == http.StatusConflict {
+			var e struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil || e.Error == "" {
+				t.Errorf("%s %s %s: body %q, want a JSON error", tt.method, tt.path, tt.body, rec.Body)
+			}
+		}
+	}
+	if got, _ := ctl.settings(); got != 2 {
+		t.Errorf("max parallel = %d after bad requests, want 2", got)
+	}
+}
+
+func TestController_SubscriberFallsBehind(t *testing.T) {
+	ctl := NewController()
+	ctl.start(&Core{}, false)
+	slow, unsubscribeSlow := ctl.Subscribe()
+	fast, unsubscribeFast := ctl.Subscribe()
+	defer unsubscribeFast()
+
+	// Neither reads; the fast one keeps up by draining between events.
+	n := cap(slow) + 1
+	var got int
+	for i := range n {
+		ctl.emit(trace.TraceEvent{Type: trace.EventToolStart, SpanID: fmt.Sprint(i)})
+		for len(fast) > 0 {
+			<-fast
+			got++
+		}
+	}
+	if got != n {
+		t.Errorf("subscriber that kept up got %d events, want %d", got, n)
+	}
+	var buffered int
+	for range slow {
+		buffered++
+	}
+	if buffered != n-1 {
+		t.Errorf("dropped subscriber got %d events before its channel closed, want %d", buffered, n-1)
+	}
+	unsubscribeSlow() // after the drop: must not close the channel again
+
+	// A new subscriber still gets the whole run replayed.
+	late, unsubscribeLate := ctl.Subscribe()
+	defer unsubscribeLate()
+	if len(late) != n {
+		t.Errorf("late subscriber replayed %d events, want %d", len(late), n)
+	}
+}
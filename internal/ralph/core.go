@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"sync"
+	"slices"
 	"time"
 
+	"devdeploy/internal/agent"
 	"devdeploy/internal/bd"
 	"devdeploy/internal/beads"
+	"devdeploy/internal/config"
 )
 
 // ProgressObserver receives progress updates from Core execution.
@@ -31,14 +33,23 @@ type ProgressObserver interface {
 	OnLoopEnd(result *CoreResult)
 }
 
+// ToolObserver is implemented by a ProgressObserver that also wants the
+// tool calls of each bead's agent while it runs, parsed from its
+// stream-json output. Its methods are called from the agent's output
+// goroutine, concurrently for parallel beads.
+type ToolObserver interface {
+	OnToolStart(beadID string, call agent.ToolCall)
+	OnToolEnd(beadID, callID string, attrs map[string]string)
+}
+
 // NoopObserver is a ProgressObserver that does nothing.
 // Embed this in your observer to avoid implementing unused methods.
 type NoopObserver struct{}
 
-func (NoopObserver) OnLoopStart(string)            {}
-func (NoopObserver) OnBeadStart(beads.Bead)        {}
-func (NoopObserver) OnBeadComplete(BeadResult)     {}
-func (NoopObserver) OnLoopEnd(*CoreResult)         {}
+func (NoopObserver) OnLoopStart(string)        {}
+func (NoopObserver) OnBeadStart(beads.Bead)    {}
+func (NoopObserver) OnBeadComplete(BeadResult) {}
+func (NoopObserver) OnLoopEnd(*CoreResult)     {}
 
 // Core orchestrates parallel agent execution for a bead tree.
 type Core struct {
@@ -58,143 +69,248 @@ type Core struct {
 	// Zero means use DefaultTimeout (10m).
 	AgentTimeout time.Duration
 
+	// Model overrides the agent model. Empty means RunAgent's default.
+	Model string
+
+	// Env holds extra KEY=value pairs for agent processes.
+	Env []string
+
+	// Backend is the agent CLI backend. Nil means the Cursor "agent" CLI.
+	Backend agent.Backend
+
+	// A bead's model:<name>, timeout:<duration> and agent:<backend> labels
+	// override Model, AgentTimeout and Backend for that bead.
+	// BackendByName builds the backend an agent: label names; nil means
+	// agent.FromConfig with just the name.
+	BackendByName func(name string) (agent.Backend, error)
+
+	// EscalationModel, when set, gives a bead that failed or timed out one
+	// more attempt on this (stronger) model. Retry attempts run on it too.
+	EscalationModel string
+
+	// Retry gives beads that failed or timed out more attempts in the run,
+	// after a backoff. A retry's prompt includes the previous attempt's
+	// error, stderr and diff. Once a bead is out of attempts it is
+	// labeled ralph-failed, which later runs skip.
+	Retry RetryPolicy
+
+	// MaxIterations caps the number of beads started. Zero means no limit.
+	MaxIterations int
+
+	// ConsecutiveFailureLimit stops the loop after this many failed or
+	// timed-out beads in a row. Zero means DefaultConsecutiveFailureLimit;
+	// negative disables the limit.
+	ConsecutiveFailureLimit int
+
+	// WallClockTimeout bounds the whole run; in-flight agents are killed
+	// when it expires. Zero means DefaultWallClockTimeout; negative
+	// disables the limit.
+	WallClockTimeout time.Duration
+
 	// Output is where logs are written. Defaults to os.Stdout.
 	Output io.Writer
 
 	// Observer receives progress updates. Optional.
 	Observer ProgressObserver
 
+	// Tracer receives the run as loop, iteration and tool call spans.
+	// Optional.
+	Tracer TraceEmitter
+
+	// Journal records the run so it can be resumed. Optional. If it was
+	// opened with OpenJournal, Run first recovers the earlier run: it
+	// finishes pending merges, removes abandoned worktrees and skips beads
+	// that already succeeded and landed.
+	Journal *Journal
+
+	// Reporter writes a machine-readable report of the run. Optional.
+	Reporter *Reporter
+
+	// VerifyCommand is a shell command (e.g. "go test ./...") that gates
+	// merges. It runs in the bead's worktree before merging; on failure
+	// the bead is reopened and counted as failed. It runs again on the
+	// target branch after merging; on failure the merge is reverted.
+	// Empty disables verification.
+	VerifyCommand string
+
+	// VerifyTimeout bounds each run of VerifyCommand. Zero means
+	// DefaultVerifyTimeout.
+	VerifyTimeout time.Duration
+
+	// Review enables a reviewer pass: once a bead is closed (and verified),
+	// a second agent reviews the diff against the bead description. If it
+	// requests changes, the bead is reopened with the comments in its notes,
+	// which the next attempt's prompt includes.
+	Review bool
+
+	// ReviewModel is the reviewer's model. Empty means RunAgentOpus's
+	// default.
+	ReviewModel string
+
+	// MergeStrategy is how successful beads' branches land on the target
+	// branch. Empty means MergeStrategyMerge.
+	MergeStrategy MergeStrategy
+
+	// ConflictAttempts is how many agents may try to resolve a merge's
+	// conflicts; each resolution must also pass VerifyCommand. Zero means
+	// DefaultConflictAttempts.
+	ConflictAttempts int
+
+	// PullRequests lands successful beads as pull requests instead of
+	// merging them locally: each bead's branch is pushed to PushRemote and
+	// opened against the run's branch with `gh pr create`, and the bead is
+	// labeled pr:<n>. The run's branch must be pushed to PushRemote and
+	// not be ahead of it. Beads always run in worktrees then, and beads that
+	// depend on one start from the run's branch, without its changes.
+	PullRequests bool
+
+	// PushRemote is the remote PullRequests pushes to. Empty means
+	// DefaultPushRemote.
+	PushRemote string
+
+	// WorktreePool reuses up to MaxParallel worktrees across beads instead
+	// of adding and removing one per bead, keeping ignored build output
+	// (see WorktreeManager.SetPoolSize). Pooled worktrees that earlier runs
+	// left behind are garbage-collected at startup.
+	WorktreePool bool
+
+	// Templates overrides the built-in bead and merge conflict prompts
+	// (see LoadTemplates). Optional.
+	Templates *Templates
+
+	// Prices estimates the cost of agent runs whose CLI reports tokens but
+	// no cost. Optional.
+	Prices map[string]config.ModelPrice
+
+	// Budget stops the loop (StopBudget) once the cost of the run's agents
+	// reaches this many USD. In-flight beads still finish; conflict
+	// resolution agents are not counted. Zero means no budget.
+	Budget float64
+
+	// Transcripts records every agent run (bead agent, reviewer, merge
+	// resolver) with its prompt, stream-json output and stderr. Optional.
+	Transcripts *Transcripts
+
+	// AgentOutput receives the agents' raw stdout as it streams. Nil tees
+	// it to os.Stdout, like RunAgent, unless Transcripts records it; use
+	// io.Discard to drop it. Observers see it either way.
+	AgentOutput io.Writer
+
+	// Slots, when set, is a limit on running agents shared with other
+	// Cores (see Watcher). Each bead holds a slot besides its MaxParallel
+	// one while its agent runs.
+	Slots *Slots
+
+	// Exclude lists beads that are never started, e.g. beads a Watcher
+	// saw fail in an earlier pass. Optional.
+	Exclude map[string]bool
+
+	// Control exposes the run to the control API: status, in-flight
+	// beads, cancelling a bead, pausing, changing MaxParallel and trace
+	// events. Optional.
+	Control *Controller
+
+	// tracer is Tracer, wrapped to also feed Control during Run.
+	tracer TraceEmitter
+
 	// Test hooks (nil means use real implementations)
 	RunBD       BDRunner
 	FetchPrompt func(runBD BDRunner, workDir, beadID string) (*PromptData, error)
 	Render      func(data *PromptData) (string, error)
 	Execute     func(ctx context.Context, workDir, prompt string) (*AgentResult, error)
 	AssessFn    func(workDir, beadID string, result *AgentResult) (Outcome, string)
+	ReviewFn    func(ctx context.Context, workDir, prompt string) (*AgentResult, error)
+	RunGH       GHRunner
 }
 
-// CoreResult holds the aggregate results of a Core.Run invocation.
-type CoreResult struct {
-	Succeeded int
-	Questions int
-	Failed    int
-	TimedOut  int
-	Duration  time.Duration
-}
-
-// Run executes the ralph loop until no more beads are ready.
-// Each iteration:
-//  1. Queries ready beads (filtered by RootBead if set)
-//  2. Executes beads in parallel (up to MaxParallel)
-//  3. Merges results back to the main branch
-func (c *Core) Run(ctx context.Context) (*CoreResult, error) {
+// CoreResult is the name observers use for the RunSummary of a Core.Run.
+type CoreResult = RunSummary
+
+// Run executes the ralph loop until no more beads are ready or a limit
+// stops it; the returned summary's StopReason says which.
+//
+// Up to MaxParallel agents run at once. Whenever an agent finishes, its
+// work is queued for merging back to the main branch (merges run one at a
+// time) and `bd ready` is queried again, so a free slot is refilled right
+// away and beads unblocked by a merge start as soon as it lands.
+//
+// Beads that fail or time out are retried as Retry and EscalationModel
+// allow; after that they are not retried within the same run: bd keeps
+// offering them because they stay open, so they are skipped instead.
+// Beads labeled ralph-failed are never started.
+func (c *Core) Run(ctx context.Context) (*RunSummary, error) {
 	start := time.Now()
-	result := &CoreResult{}
+	result := &RunSummary{}
 
 	out := c.Output
 	if out == nil {
 		out = os.Stdout
 	}
+	// Agents and the merge queue log concurrently.
+	out = &syncWriter{w: out}
+
+	c.tracer = c.Tracer
+	if c.Control != nil {
+		c.Control.start(c, c.MaxParallel > 1)
+		defer c.Control.finish(result)
+		c.tracer = c.Control.tracer(c.Tracer)
+	}
 
 	// Notify observer of loop start
 	if c.Observer != nil {
 		c.Observer.OnLoopStart(c.RootBead)
 	}
+	if c.tracer != nil {
+		c.tracer.StartLoop(c.Model, c.RootBead, c.WorkDir, c.MaxIterations)
+	}
 
-	// Initialize worktree manager for parallel execution
+	// Initialize worktree manager for parallel execution; pull requests
+	// need a branch per bead too.
 	var wtMgr *WorktreeManager
-	if c.MaxParallel > 1 {
+	if c.MaxParallel > 1 || c.PullRequests {
 		var err error
 		wtMgr, err = NewWorktreeManager(c.WorkDir)
 		if err != nil {
+			c.endTrace("error", result)
 			return nil, fmt.Errorf("creating worktree manager: %w", err)
 		}
-	}
-
-	for {
-		// Check context cancellation
-		if ctx.Err() != nil {
-			break
-		}
-
-		// 1. Query ready beads
-		ready, err := c.readyBeads()
-		if err != nil {
-			return nil, fmt.Errorf("fetching ready beads: %w", err)
+		if c.WorktreePool {
+			wtMgr.SetPoolSize(max(c.MaxParallel, 1))
 		}
-		if len(ready) == 0 {
-			break // No more work
+		if err := wtMgr.PrunePool(); err != nil {
+			writef(out, "  warning: pruning pooled worktrees: %v\n", err)
 		}
+	}
 
-		writef(out, "Found %d ready bead(s)\n", len(ready))
-
-		// 2. Execute in parallel (limited by MaxParallel)
-		batchSize := len(ready)
-		if c.MaxParallel > 0 && batchSize > c.MaxParallel {
-			batchSize = c.MaxParallel
-		}
-		batch := ready[:batchSize]
-
-		results := c.executeParallel(ctx, wtMgr, batch, out)
-
-		// 3. Process results and merge back
-		for _, r := range results {
-			switch r.Outcome {
-			case OutcomeSuccess:
-				result.Succeeded++
-			case OutcomeQuestion:
-				result.Questions++
-			case OutcomeFailure:
-				result.Failed++
-			case OutcomeTimeout:
-				result.TimedOut++
-			}
+	// The wall-clock limit also kills in-flight agents, so derive it from ctx.
+	parentCtx := ctx
+	if wall := c.wallClockTimeout(); wall > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wall)
+		defer cancel()
+	}
 
-			// Merge successful work back if using worktrees
-			if r.WorktreePath != "" && r.Outcome == OutcomeSuccess && r.BranchName != "" {
-				// Create worktree manager on-demand if we don't have one
-				// This handles cases where MaxParallel was 1 but worktrees were created anyway
-				mergeWtMgr := wtMgr
-				if mergeWtMgr == nil {
-					var err error
-					mergeWtMgr, err = NewWorktreeManager(c.WorkDir)
-					if err != nil {
-						writef(out, "[%s] ERROR: failed to create worktree manager for merge: %v\n", r.BeadID, err)
-						result.Failed++
-						continue
-					}
-				}
-				writef(out, "[%s] merging %s into %s\n", r.BeadID, r.BranchName, mergeWtMgr.Branch())
-				if err := c.mergeBack(ctx, mergeWtMgr, r); err != nil {
-					writef(out, "[%s] ERROR: merge failed: %v\n", r.BeadID, err)
-					// Don't fail the entire run, but make the error visible
-					result.Failed++
-				} else {
-					writef(out, "[%s] ✓ merged successfully\n", r.BeadID)
-				}
-			} else if r.BranchName != "" && r.Outcome == OutcomeSuccess {
-				// Branch was created but worktree wasn't (shouldn't happen, but handle it)
-				writef(out, "[%s] WARNING: branch %s exists but no worktree was created - merge skipped\n", r.BeadID, r.BranchName)
-			}
+	var done map[string]bool
+	if c.Journal != nil && len(c.Journal.State().Beads) > 0 {
+		done = c.recoverRun(ctx, wtMgr, result, out)
+	}
 
-			// Clean up worktree
-			if r.WorktreePath != "" {
-				cleanupWtMgr := wtMgr
-				if cleanupWtMgr == nil {
-					var err error
-					cleanupWtMgr, err = NewWorktreeManager(c.WorkDir)
-					if err != nil {
-						writef(out, "  warning: failed to create worktree manager for cleanup: %v\n", err)
-						continue
-					}
-				}
-				if err := cleanupWtMgr.RemoveWorktree(r.WorktreePath); err != nil {
-					writef(out, "  warning: failed to remove worktree: %v\n", err)
-				}
-			}
-		}
+	if err := c.schedule(ctx, parentCtx, wtMgr, done, result, out); err != nil {
+		c.endTrace("error", result)
+		return nil, err
 	}
 
 	result.Duration = time.Since(start)
+	c.record(out, JournalEvent{Type: EventRunEnd, StopReason: &result.StopReason})
+	if c.Reporter != nil {
+		var runID string
+		if c.Journal != nil {
+			runID = c.Journal.RunID()
+		}
+		if err := c.Reporter.finish(runID, c.RootBead, result); err != nil {
+			writef(out, "  warning: writing report: %v\n", err)
+		}
+	}
 
 	// Print summary
 	writef(out, "\nCore loop complete:\n")
@@ -210,8 +326,19 @@ func (c *Core) Run(ctx context.Context) (*CoreResult, error) {
 	if result.TimedOut > 0 {
 		writef(out, "  ⏱ %d timeouts\n", result.TimedOut)
 	}
+	if result.Skipped > 0 {
+		writef(out, "  ↷ %d skipped (failed earlier in this run)\n", result.Skipped)
+	}
+	if result.Usage.Tokens() > 0 {
+		writef(out, "  Usage: %s\n", FormatUsage(result.Usage))
+	}
+	if result.StopReason != StopNormal {
+		writef(out, "  Stopped: %s\n", result.StopReason)
+	}
 	writef(out, "  Duration: %s\n", FormatDuration(result.Duration))
 
+	c.endTrace(result.StopReason.String(), result)
+
 	// Notify observer of loop end
 	if c.Observer != nil {
 		c.Observer.OnLoopEnd(result)
@@ -220,6 +347,46 @@ func (c *Core) Run(ctx context.Context) (*CoreResult, error) {
 	return result, nil
 }
 
+// endTrace ends the loop span, if the run is traced.
+func (c *Core) endTrace(stopReason string, result *RunSummary) {
+	if c.tracer != nil {
+		c.tracer.EndLoopWithAttrs(stopReason, result.Iterations, result.Succeeded, result.Failed, usageAttrs(result.Usage))
+	}
+}
+
+// consecutiveFailureLimit returns the effective failure limit; 0 disables it.
+func (c *Core) consecutiveFailureLimit() int {
+	switch {
+	case c.ConsecutiveFailureLimit < 0:
+		return 0
+	case c.ConsecutiveFailureLimit == 0:
+		return DefaultConsecutiveFailureLimit
+	default:
+		return c.ConsecutiveFailureLimit
+	}
+}
+
+// wallClockTimeout returns the effective run timeout; 0 disables it.
+func (c *Core) wallClockTimeout() time.Duration {
+	switch {
+	case c.WallClockTimeout < 0:
+		return 0
+	case c.WallClockTimeout == 0:
+		return DefaultWallClockTimeout
+	default:
+		return c.WallClockTimeout
+	}
+}
+
+// contextStopReason tells a caller cancellation apart from the wall-clock
+// limit, given the context passed to Run.
+func contextStopReason(parent context.Context) StopReason {
+	if parent.Err() != nil {
+		return StopContextCancelled
+	}
+	return StopWallClock
+}
+
 // beadExecResult holds the outcome of executing a single bead.
 type beadExecResult struct {
 	BeadID       string
@@ -227,6 +394,11 @@ type beadExecResult struct {
 	Duration     time.Duration
 	WorktreePath string
 	BranchName   string
+	Attempt      int        // 1 for the bead's first attempt in the run
+	BaseRev      string     // HEAD of the agent's tree before it ran
+	Model        string     // the agent's model; empty means the CLI default
+	Cancelled    bool       // through the control API
+	Detail       BeadResult // what the observer saw, for reports
 }
 
 // readyBeads fetches beads that are ready to work on.
@@ -246,48 +418,54 @@ func (c *Core) readyBeads() ([]beads.Bead, error) {
 		return nil, err
 	}
 
-	return parseReadyBeads(out)
-}
-
-// executeParallel runs agents for a batch of beads concurrently.
-func (c *Core) executeParallel(ctx context.Context, wtMgr *WorktreeManager, batch []beads.Bead, out io.Writer) []beadExecResult {
-	results := make([]beadExecResult, len(batch))
-	var wg sync.WaitGroup
-
-	for i, bead := range batch {
-		wg.Add(1)
-		go func(idx int, b beads.Bead) {
-			defer wg.Done()
-			results[idx] = c.executeBead(ctx, wtMgr, &b, out)
-		}(i, bead)
+	ready, err := parseReadyBeads(out)
+	if err != nil {
+		return nil, err
 	}
-
-	wg.Wait()
-	return results
+	// Beads ralph gave up on wait for a human.
+	return slices.DeleteFunc(ready, func(b beads.Bead) bool {
+		return hasLabel(b.Labels, beads.LabelRalphFailed)
+	}), nil
 }
 
-// executeBead runs an agent for a single bead.
-func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *beads.Bead, out io.Writer) beadExecResult {
+// executeBead runs an agent for a single bead. iterNum is its position
+// among the beads started in this run, attempt counts the bead's attempts
+// from 1. On a retry, prev is the attempt before it.
+func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *beads.Bead, iterNum, attempt int, prev *FailedAttempt, out io.Writer) beadExecResult {
 	start := time.Now()
-	result := beadExecResult{BeadID: bead.ID}
+	result := beadExecResult{BeadID: bead.ID, Attempt: attempt}
 
-	// For observer notifications
+	// For observer notifications and reports
 	var agentResult *AgentResult
+	var review *Review
+	var iterSpan string
 	notifyComplete := func(outcome Outcome, errMsg string) {
+		br := BeadResult{
+			Bead:     *bead,
+			Outcome:  outcome,
+			Duration: result.Duration,
+			Review:   review,
+			Model:    result.Model,
+			Attempt:  attempt,
+		}
+		if agentResult != nil {
+			br.ChatID = agentResult.ChatID
+			br.ExitCode = agentResult.ExitCode
+			br.Stderr = agentResult.Stderr
+			br.Usage = agentResult.Usage.Priced(c.Prices)
+			br.Transcript = agentResult.Transcript
+		}
+		if review != nil {
+			br.Usage = br.Usage.Add(review.Usage)
+		}
+		if errMsg != "" {
+			br.ErrorMessage = errMsg
+		}
+		result.Detail = br
+		if c.tracer != nil {
+			c.tracer.EndIterationWithAttrs(iterSpan, outcome.String(), br.Duration.Milliseconds(), iterationAttrs(br))
+		}
 		if c.Observer != nil {
-			br := BeadResult{
-				Bead:     *bead,
-				Outcome:  outcome,
-				Duration: result.Duration,
-			}
-			if agentResult != nil {
-				br.ChatID = agentResult.ChatID
-				br.ExitCode = agentResult.ExitCode
-				br.Stderr = agentResult.Stderr
-			}
-			if errMsg != "" {
-				br.ErrorMessage = errMsg
-			}
 			c.Observer.OnBeadComplete(br)
 		}
 	}
@@ -296,6 +474,23 @@ func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *be
 	if c.Observer != nil {
 		c.Observer.OnBeadStart(*bead)
 	}
+	if c.tracer != nil {
+		iterSpan = c.tracer.StartIteration(bead.ID, bead.Title, iterNum)
+	}
+
+	// Per-bead agent settings
+	setup, err := c.beadAgent(bead, attempt)
+	if err != nil {
+		writef(out, "[%s] %v\n", bead.ID, err)
+		result.Outcome = OutcomeFailure
+		result.Duration = time.Since(start)
+		notifyComplete(result.Outcome, err.Error())
+		return result
+	}
+	result.Model = setup.Model
+	if setup.Escalated {
+		writef(out, "[%s] retrying on %s\n", bead.ID, setup.Model)
+	}
 
 	// Determine execution directory
 	execDir := c.WorkDir
@@ -312,6 +507,7 @@ func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *be
 		result.WorktreePath = worktreePath
 		result.BranchName = branchName
 	}
+	c.record(out, JournalEvent{Type: EventBeadStart, BeadID: bead.ID, WorktreePath: result.WorktreePath, BranchName: result.BranchName})
 
 	// Fetch prompt data
 	fetchPrompt := c.FetchPrompt
@@ -326,11 +522,12 @@ func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *be
 		notifyComplete(result.Outcome, err.Error())
 		return result
 	}
+	promptData.PreviousAttempt = prev
 
 	// Render prompt
 	render := c.Render
 	if render == nil {
-		render = RenderPrompt
+		render = c.Templates.RenderPrompt
 	}
 	prompt, err := render(promptData)
 	if err != nil {
@@ -341,13 +538,18 @@ func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *be
 		return result
 	}
 
+	// A retry sees everything the agent changed from here on.
+	baseRev := headRev(execDir)
+	result.BaseRev = baseRev
+
 	// Execute agent
 	if c.Execute != nil {
 		agentResult, err = c.Execute(ctx, execDir, prompt)
 	} else {
-		var opts []Option
-		if c.AgentTimeout > 0 {
-			opts = append(opts, WithTimeout(c.AgentTimeout))
+		opts := append(c.agentOptionsFor(setup), c.transcriptOptions(bead.ID, TranscriptAgent)...)
+		tools, _ := c.Observer.(ToolObserver)
+		if tools != nil || c.tracer != nil {
+			opts = append(opts, WithToolObserver(newBeadToolObserver(bead.ID, tools, c.tracer, iterSpan)))
 		}
 		agentResult, err = RunAgent(ctx, execDir, prompt, opts...)
 	}
@@ -368,6 +570,36 @@ func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *be
 	}
 	outcome, summary := assessFn(c.WorkDir, bead.ID, agentResult)
 
+	// Closed is not enough: the work has to pass verification to be merged.
+	if outcome == OutcomeSuccess && c.VerifyCommand != "" {
+		writef(out, "[%s] verifying: %s\n", bead.ID, c.VerifyCommand)
+		if err := c.verify(ctx, execDir); err != nil {
+			outcome = OutcomeFailure
+			summary = fmt.Sprintf("pre-merge verification failed: %v", err)
+			if err := c.reopenBead(bead.ID, ""); err != nil {
+				writef(out, "[%s] warning: reopening bead: %v\n", bead.ID, err)
+			}
+		}
+	}
+
+	if outcome == OutcomeSuccess && c.Review && baseRev != "" {
+		var target string
+		if wtMgr != nil {
+			target = wtMgr.Branch()
+		}
+		review = c.reviewBead(ctx, execDir, baseRev, target, promptData, out)
+		switch review.Verdict {
+		case ReviewChangesRequested:
+			outcome = OutcomeFailure
+			summary = "review requested changes:\n" + review.Comments
+			if err := c.reopenBead(bead.ID, reviewNotes(promptData.Notes, review)); err != nil {
+				writef(out, "[%s] warning: reopening bead: %v\n", bead.ID, err)
+			}
+		case ReviewError:
+			writef(out, "[%s] warning: review failed, not blocking merge: %s\n", bead.ID, review.Comments)
+		}
+	}
+
 	result.Outcome = outcome
 	result.Duration = agentResult.Duration
 
@@ -375,6 +607,9 @@ func (c *Core) executeBead(ctx context.Context, wtMgr *WorktreeManager, bead *be
 	if summary != "" && outcome != OutcomeSuccess {
 		writef(out, "  %s\n", summary)
 	}
+	if agentResult.Transcript != "" && outcome != OutcomeSuccess {
+		writef(out, "  transcript: %s\n", agentResult.Transcript+TranscriptStdoutExt)
+	}
 
 	notifyComplete(result.Outcome, summary)
 	return result
@@ -385,14 +620,58 @@ func (c *Core) mergeBack(ctx context.Context, wtMgr *WorktreeManager, r beadExec
 	// Find the correct repository path for merging.
 	// Use the worktree that has the target branch checked out, not the main repo.
 	mergeRepo := wtMgr.MergeRepo(wtMgr.Branch())
+	title := ""
+	if c.MergeStrategy == MergeStrategySquash {
+		// The squash commit's message starts with the bead title.
+		fetchPrompt := c.FetchPrompt
+		if fetchPrompt == nil {
+			fetchPrompt = FetchPromptData
+		}
+		if data, err := fetchPrompt(c.RunBD, c.WorkDir, r.BeadID); err == nil {
+			title = data.Title
+		}
+	}
 	return MergeWithAgentResolution(
 		ctx,
 		mergeRepo,
 		wtMgr.Branch(),
 		r.BranchName,
 		r.BeadID,
-		"", // beadTitle not needed
+		title,
+		c.MergeStrategy,
+		ConflictResolution{MaxAttempts: c.ConflictAttempts, Verify: c.verify},
 		c.AgentTimeout,
+		c.Templates,
+		append(c.agentOptions(), c.transcriptOptions(r.BeadID, TranscriptMerge)...)...,
 	)
 }
 
+// agentOptions returns the RunAgent options derived from the Core config.
+func (c *Core) agentOptions() []Option {
+	return c.agentOptionsFor(beadAgent{Model: c.Model, Timeout: c.AgentTimeout, Backend: c.Backend})
+}
+
+// agentOptionsFor returns the RunAgent options for an agent set up as a.
+func (c *Core) agentOptionsFor(a beadAgent) []Option {
+	opts := a.options()
+	if len(c.Env) > 0 {
+		opts = append(opts, WithEnv(c.Env))
+	}
+	output := c.AgentOutput
+	if output == nil {
+		output = os.Stdout
+		if c.Transcripts != nil {
+			output = io.Discard
+		}
+	}
+	return append(opts, WithStdoutWriter(output))
+}
+
+// transcriptOptions returns the option recording the next role agent run
+// on beadID, if transcripts are enabled.
+func (c *Core) transcriptOptions(beadID, role string) []Option {
+	if c.Transcripts == nil {
+		return nil
+	}
+	return []Option{WithTranscript(c.Transcripts.path(beadID, role))}
+}
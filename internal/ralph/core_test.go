@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"devdeploy/internal/agent"
 	"devdeploy/internal/beads"
+	"devdeploy/internal/config"
 )
 
 // mockBDForCore returns a BDRunner that returns beads one at a time.
@@ -187,6 +190,180 @@ func TestCore_Run_ContextCancellation(t *testing.T) {
 	}
 }
 
+// staticBD returns a BDRunner whose "bd ready" always offers the same beads,
+// like bd does for beads that stay open.
+func staticBD(beadsList []beads.Bead) BDRunner {
+	return func(dir string, args ...string) ([]byte, error) {
+		entries := make([]bdReadyEntry, len(beadsList))
+		for i, b := range beadsList {
+			entries[i] = bdReadyEntry{ID: b.ID, Title: b.Title, Status: b.Status, Priority: b.Priority}
+		}
+		return json.Marshal(entries)
+	}
+}
+
+// countingBD offers a fresh bead on every call, so the loop never runs dry.
+func countingBD() BDRunner {
+	var n int32
+	return func(dir string, args ...string) ([]byte, error) {
+		id := atomic.AddInt32(&n, 1)
+		return json.Marshal([]bdReadyEntry{{ID: fmt.Sprintf("bead-%d", id), Title: "Endless", Status: "open"}})
+	}
+}
+
+func newLimitTestCore(runBD BDRunner, outcome Outcome) *Core {
+	execFn, _ := mockExecute()
+	return &Core{
+		WorkDir:     "/tmp/test",
+		MaxParallel: 1,
+		Output:      &bytes.Buffer{},
+		RunBD:       runBD,
+		FetchPrompt: func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+			return &PromptData{ID: beadID, Title: "Test"}, nil
+		},
+		Render:   func(data *PromptData) (string, error) { return "prompt", nil },
+		Execute:  execFn,
+		AssessFn: mockAssess(outcome),
+	}
+}
+
+func TestCore_Run_StopReasons(t *testing.T) {
+	tests := []struct {
+		name      string
+		core      *Core
+		want      StopReason
+		wantIters int
+	}{
+		{
+			name:      "runs dry",
+			core:      newLimitTestCore(mockBDForCore([]beads.Bead{{ID: "a"}, {ID: "b"}}), OutcomeSuccess),
+			want:      StopNormal,
+			wantIters: 2,
+		},
+		{
+			name: "max iterations",
+			core: func() *Core {
+				c := newLimitTestCore(countingBD(), OutcomeSuccess)
+				c.MaxIterations = 3
+				return c
+			}(),
+			want:      StopMaxIterations,
+			wantIters: 3,
+		},
+		{
+			name:      "consecutive failures",
+			core:      newLimitTestCore(countingBD(), OutcomeFailure),
+			want:      StopConsecutiveFails,
+			wantIters: DefaultConsecutiveFailureLimit,
+		},
+		{
+			name: "budget",
+			core: func() *Core {
+				c := newLimitTestCore(countingBD(), OutcomeSuccess)
+				c.Prices = map[string]config.ModelPrice{"m": {Input: 1}}
+				c.Budget = 2.5
+				c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+					// $1 per bead, from the price table.
+					return &AgentResult{Usage: agent.Usage{Model: "m", InputTokens: 1_000_000}}, nil
+				}
+				return c
+			}(),
+			want:      StopBudget,
+			wantIters: 3,
+		},
+		{
+			name:      "failed bead re-offered",
+			core:      newLimitTestCore(staticBD([]beads.Bead{{ID: "stuck", Status: "open"}}), OutcomeFailure),
+			want:      StopAllBeadsSkipped,
+			wantIters: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.core.Run(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.StopReason != tt.want {
+				t.Errorf("StopReason = %s, want %s", result.StopReason, tt.want)
+			}
+			if result.Iterations != tt.wantIters {
+				t.Errorf("Iterations = %d, want %d", result.Iterations, tt.wantIters)
+			}
+		})
+	}
+}
+
+func TestCore_Run_SkipsFailedBeadsButContinues(t *testing.T) {
+	// "stuck" fails and stays open; "next" becomes ready afterwards.
+	var calls int32
+	runBD := func(dir string, args ...string) ([]byte, error) {
+		entries := []bdReadyEntry{{ID: "stuck", Title: "Stuck", Status: "open"}}
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+		case 2:
+			entries = append(entries, bdReadyEntry{ID: "next", Title: "Next", Status: "open"})
+		default:
+			// "next" closed after succeeding
+		}
+		return json.Marshal(entries)
+	}
+	c := newLimitTestCore(runBD, OutcomeSuccess)
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		if beadID == "stuck" {
+			return OutcomeFailure, "boom"
+		}
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 1 {
+		t.Errorf("succeeded/failed = %d/%d, want 1/1", result.Succeeded, result.Failed)
+	}
+	if result.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1", result.Skipped)
+	}
+	if result.StopReason != StopAllBeadsSkipped {
+		t.Errorf("StopReason = %s, want %s", result.StopReason, StopAllBeadsSkipped)
+	}
+}
+
+func TestCore_Run_WallClock(t *testing.T) {
+	c := newLimitTestCore(countingBD(), OutcomeSuccess)
+	c.WallClockTimeout = 50 * time.Millisecond
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		<-ctx.Done()
+		return &AgentResult{ExitCode: 1, TimedOut: true}, nil
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.StopReason != StopWallClock {
+		t.Errorf("StopReason = %s, want %s", result.StopReason, StopWallClock)
+	}
+	if result.StopReason.ExitCode() != 4 {
+		t.Errorf("ExitCode = %d, want 4", result.StopReason.ExitCode())
+	}
+}
+
+func TestCore_Run_ContextCancelledStopReason(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := newLimitTestCore(countingBD(), OutcomeSuccess).Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.StopReason != StopContextCancelled {
+		t.Errorf("StopReason = %s, want %s", result.StopReason, StopContextCancelled)
+	}
+}
+
 // testObserver records all observer callbacks for verification.
 type testObserver struct {
 	NoopObserver
@@ -14,12 +14,21 @@
 //	}
 //	result, err := core.Run(ctx)
 //
+// Core's fields switch on the rest: Retry and EscalationModel, VerifyCommand,
+// Review, MergeStrategy, PullRequests, WorktreePool, Journal (resuming),
+// Reporter, Transcripts, Budget and Control, each documented where it is
+// declared. Watcher keeps running passes as beads become ready, and
+// BuildPlan shows an epic's expected execution without running agents.
+//
 // # Progress Observation
 //
 // Implement ProgressObserver to receive live updates:
 //
 //	core.Observer = myObserver  // receives OnBeadStart, OnBeadComplete, etc.
 //
+// Core.Tracer receives the run as a span tree, which the ralph/tui package
+// renders live.
+//
 // # Testing
 //
 // Core supports test hooks for all external dependencies:
@@ -1,16 +1,15 @@
 package ralph
 
 import (
-	"bufio"
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"strings"
 	"time"
+
+	"devdeploy/internal/agent"
 )
 
 // DefaultTimeout is the per-agent execution timeout.
@@ -27,53 +26,91 @@ type AgentResult struct {
 	TimedOut bool // true if the agent was killed due to timeout
 
 	// ChatID is the chat session ID from the agent, extracted from the result event.
-	// Useful for debugging failed runs by finding the chat in Cursor.
+	// Useful for debugging failed runs by finding the chat in the agent's UI.
 	ChatID string
 
 	// ErrorMessage is the error message from the agent's result event, if any.
 	ErrorMessage string
+
+	// ResultText is the agent's final answer from the result event, if the
+	// backend reports one.
+	ResultText string
+
+	// Usage is the run's token usage and, when the CLI reports it, cost.
+	Usage agent.Usage
+
+	// Transcript is the path prefix of the run's transcript files (see
+	// WithTranscript), or empty when none was written.
+	Transcript string
 }
 
 // CommandFactory builds an *exec.Cmd for the given context, working directory,
-// and arguments. The default factory uses exec.CommandContext with "agent" as
-// the binary. Tests can inject a factory that invokes a helper process instead.
+// and arguments. By default the backend's binary is run with
+// exec.CommandContext. Tests can inject a factory that invokes a helper
+// process instead.
 type CommandFactory func(ctx context.Context, workDir string, args ...string) *exec.Cmd
 
-// defaultCommandFactory creates a real "agent" command.
-func defaultCommandFactory(ctx context.Context, workDir string, args ...string) *exec.Cmd {
-	cmd := exec.CommandContext(ctx, "agent", args...)
-	cmd.Dir = workDir
-	return cmd
+// backendCommandFactory creates a command running the backend's binary.
+func backendCommandFactory(b agent.Backend) CommandFactory {
+	return func(ctx context.Context, workDir string, args ...string) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, b.Binary(), args...)
+		cmd.Dir = workDir
+		return cmd
+	}
 }
 
 // runAgentInternal is the shared implementation for running an agent process.
 // It applies options, creates a timeout context, builds command arguments,
 // captures stdout/stderr, runs the command, and returns the result.
+//
+// defaultModel is a Cursor model name, so it only applies to the Cursor
+// backend; other backends fall back to their CLI's default model.
 func runAgentInternal(ctx context.Context, workDir, prompt, defaultModel string, opts ...Option) (*AgentResult, error) {
 	cfg := options{
-		timeout:        DefaultTimeout,
-		commandFactory: defaultCommandFactory,
-		stdoutWriter:   os.Stdout,
-		model:          defaultModel,
+		timeout:      DefaultTimeout,
+		stdoutWriter: os.Stdout,
+		backend:      agent.Cursor{},
 	}
 	for _, o := range opts {
 		o(&cfg)
 	}
+	if cfg.commandFactory == nil {
+		cfg.commandFactory = backendCommandFactory(cfg.backend)
+	}
 
 	// Derive a timeout context so the process is killed on expiry.
 	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
 	defer cancel()
 
 	model := cfg.model
-	if model == "" {
+	if model == "" && cfg.backend.Name() == agent.BackendCursor {
 		model = defaultModel
 	}
-	args := []string{"--model", model, "--print", "--force", "--output-format", "stream-json", prompt}
-	cmd := cfg.commandFactory(ctx, workDir, args...)
+	cmd := cfg.commandFactory(ctx, workDir, cfg.backend.HeadlessArgs(model, prompt)...)
+	if len(cfg.env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, cfg.env...)
+	}
 
-	// Capture stdout: tee to live writer + buffer.
+	// Capture stdout: tee to live writer + buffer, and to the tool-call
+	// parser when someone is watching.
 	var stdoutBuf bytes.Buffer
 	cmd.Stdout = io.MultiWriter(&stdoutBuf, cfg.stdoutWriter)
+	if cfg.transcript != "" {
+		f, err := openTranscript(cfg.transcript, prompt)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, f)
+	}
+	if cfg.toolObserver != nil {
+		tools := agent.NewToolStream(cfg.toolObserver)
+		defer tools.Close()
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, tools)
+	}
 
 	// Capture stderr into a buffer.
 	var stderrBuf bytes.Buffer
@@ -103,16 +140,30 @@ func runAgentInternal(ctx context.Context, workDir, prompt, defaultModel string,
 		Duration: duration,
 		TimedOut: timedOut,
 	}
+	if cfg.transcript != "" {
+		result.Transcript = cfg.transcript
+		if stderrBuf.Len() > 0 {
+			if err := os.WriteFile(cfg.transcript+TranscriptStderrExt, stderrBuf.Bytes(), 0644); err != nil {
+				return nil, fmt.Errorf("writing transcript: %w", err)
+			}
+		}
+	}
 
-	// Parse chatId and error from the agent's stdout (stream-json format)
-	result.ChatID, result.ErrorMessage = parseAgentResultEvent(stdoutBuf.String())
+	// Extract chat ID, error and final answer with the backend's parser.
+	parsed := agent.ParseOutput(cfg.backend.NewParser(), stdoutBuf.String())
+	result.ChatID, result.ErrorMessage, result.ResultText = parsed.ChatID, parsed.ErrorMessage, parsed.Text
+	result.Usage = parsed.Usage
+	if result.Usage.Model == "" {
+		result.Usage.Model = model
+	}
 
 	return result, nil
 }
 
 // RunAgent spawns an agent process with the given prompt and captures its
-// output. It uses "agent --model composer-1 --print --force --output-format stream-json" as the
-// command line. The process is killed if ctx expires or the timeout elapses.
+// output. With the default Cursor backend the command line is
+// "agent --model composer-1 --print --force --output-format stream-json".
+// The process is killed if ctx expires or the timeout elapses.
 //
 // stdout is tee'd to os.Stdout in real time for observability while also being
 // captured in the returned AgentResult.
@@ -126,6 +177,10 @@ type options struct {
 	commandFactory CommandFactory
 	stdoutWriter   io.Writer
 	model          string
+	env            []string
+	backend        agent.Backend
+	toolObserver   agent.ToolObserver
+	transcript     string
 }
 
 // Option configures RunAgent behaviour.
@@ -152,49 +207,31 @@ func WithModel(model string) Option {
 	return func(o *options) { o.model = model }
 }
 
-// RunAgentOpus runs an opus model agent for verification passes.
-// Uses "agent --model claude-4.5-opus-high-thinking --print --force --output-format stream-json".
-func RunAgentOpus(ctx context.Context, workDir string, prompt string, opts ...Option) (*AgentResult, error) {
-	return runAgentInternal(ctx, workDir, prompt, "claude-4.5-opus-high-thinking", opts...)
+// WithEnv appends KEY=value pairs to the agent process environment.
+func WithEnv(env []string) Option {
+	return func(o *options) { o.env = env }
 }
 
-// parseAgentResultEvent parses the agent's stdout for the final "result" event
-// and extracts chatId and error message.
-// The agent outputs JSON lines, and the result event has the format:
-// {"type":"result","chatId":"...","error":"...","duration_ms":...}
-func parseAgentResultEvent(stdout string) (chatID, errorMsg string) {
-	scanner := bufio.NewScanner(strings.NewReader(stdout))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
-
-		var event map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &event); err != nil {
-			continue
-		}
+// WithBackend selects the agent CLI backend (default: Cursor "agent").
+func WithBackend(b agent.Backend) Option {
+	return func(o *options) { o.backend = b }
+}
 
-		eventType, _ := event["type"].(string)
-		if eventType != "result" {
-			continue
-		}
+// WithToolObserver reports the agent's tool calls as they happen, parsed
+// from its stream-json output.
+func WithToolObserver(obs agent.ToolObserver) Option {
+	return func(o *options) { o.toolObserver = obs }
+}
 
-		// Extract chatId - may be at top level or nested
-		if id, ok := event["chatId"].(string); ok {
-			chatID = id
-		} else if id, ok := event["chat_id"].(string); ok {
-			chatID = id
-		}
+// WithTranscript records the run under the path prefix p: the prompt in
+// p.prompt.md, stdout as it streams in p.jsonl and stderr in p.stderr.
+func WithTranscript(p string) Option {
+	return func(o *options) { o.transcript = p }
+}
 
-		// Extract error message
-		if errStr, ok := event["error"].(string); ok && errStr != "" {
-			errorMsg = errStr
-		} else if errObj, ok := event["error"].(map[string]interface{}); ok {
-			if msg, ok := errObj["message"].(string); ok {
-				errorMsg = msg
-			}
-		}
-	}
-	return chatID, errorMsg
+// RunAgentOpus runs an opus model agent for verification passes.
+// With the default Cursor backend it uses
+// "agent --model claude-4.5-opus-high-thinking --print --force --output-format stream-json".
+func RunAgentOpus(ctx context.Context, workDir string, prompt string, opts ...Option) (*AgentResult, error) {
+	return runAgentInternal(ctx, workDir, prompt, "claude-4.5-opus-high-thinking", opts...)
 }
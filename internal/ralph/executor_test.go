@@ -4,11 +4,17 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"devdeploy/internal/agent"
 )
 
 // ---------------------------------------------------------------------------
@@ -43,9 +49,16 @@ func TestHelperProcess(t *testing.T) {
 		}
 	case "stderr":
 		fmt.Fprint(os.Stderr, "agent error output")
+	case "env":
+		fmt.Print(os.Getenv("DD_AGENT_VAR"))
 	case "exit":
 		code, _ := strconv.Atoi(os.Getenv("DD_EXIT_CODE"))
 		os.Exit(code)
+	case "stream":
+		// A Cursor stream-json run with one tool call.
+		fmt.Println(`{"type":"tool_call","subtype":"started","call_id":"c1","tool_call":{"shellToolCall":{"args":{"command":"go test ./..."}}}}`)
+		fmt.Println(`{"type":"tool_call","subtype":"completed","call_id":"c1","tool_call":{"shellToolCall":{"result":{"success":{}}}}}`)
+		fmt.Println(`{"type":"result","chatId":"chat-s","result":"All done."}`)
 	case "slow":
 		// Sleep longer than the test timeout to trigger kill.
 		time.Sleep(30 * time.Second)
@@ -105,6 +118,51 @@ func TestRunAgent_CapturesStdout(t *testing.T) {
 	if result.Duration <= 0 {
 		t.Error("duration should be positive")
 	}
+	// No usage in the output: the model is the one the agent was started with.
+	if result.Usage.Model != "composer-1" || result.Usage.Tokens() != 0 {
+		t.Errorf("usage = %+v, want composer-1 without tokens", result.Usage)
+	}
+}
+
+// toolLog records tool calls reported through WithToolObserver.
+type toolLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *toolLog) ToolStarted(call agent.ToolCall) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, "start "+call.Name+" "+call.Attrs["command"])
+}
+
+func (l *toolLog) ToolEnded(id string, attrs map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, "end "+id+" "+attrs["status"])
+}
+
+func TestRunAgent_WithToolObserver(t *testing.T) {
+	tools := &toolLog{}
+	result, err := RunAgent(
+		context.Background(),
+		t.TempDir(),
+		"prompt",
+		WithCommandFactory(helperFactory("stream")),
+		WithStdoutWriter(io.Discard),
+		WithTimeout(5*time.Second),
+		WithToolObserver(tools),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"start shell go test ./...", "end c1 ok"}
+	if strings.Join(tools.events, "|") != strings.Join(want, "|") {
+		t.Errorf("tool events = %v, want %v", tools.events, want)
+	}
+	if result.ChatID != "chat-s" || result.ResultText != "All done." {
+		t.Errorf("chat/result = %q/%q, want chat-s/All done.", result.ChatID, result.ResultText)
+	}
 }
 
 func TestRunAgent_CapturesStderr(t *testing.T) {
@@ -125,6 +183,102 @@ func TestRunAgent_CapturesStderr(t *testing.T) {
 	}
 }
 
+func TestRunAgent_WithTranscript(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "run", "b-1", "agent-1")
+	for _, mode := range []string{"stream", "stderr"} {
+		result, err := RunAgent(
+			context.Background(),
+			t.TempDir(),
+			"do the thing",
+			WithCommandFactory(helperFactory(mode)),
+			WithStdoutWriter(io.Discard),
+			WithTimeout(5*time.Second),
+			WithTranscript(prefix),
+		)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", mode, err)
+		}
+		if result.Transcript != prefix {
+			t.Errorf("%s: transcript = %q, want %q", mode, result.Transcript, prefix)
+		}
+		if got, _ := os.ReadFile(prefix + TranscriptPromptExt); string(got) != "do the thing" {
+			t.Errorf("%s: prompt file = %q", mode, got)
+		}
+		got, _ := os.ReadFile(prefix + TranscriptStdoutExt)
+		if string(got) != result.Stdout {
+			t.Errorf("%s: stdout file = %q, want %q", mode, got, result.Stdout)
+		}
+	}
+	// The second run overwrote the first; only it wrote stderr.
+	if got, _ := os.ReadFile(prefix + TranscriptStderrExt); string(got) != "agent error output" {
+		t.Errorf("stderr file = %q, want agent error output", got)
+	}
+}
+
+func TestRunAgent_WithEnv(t *testing.T) {
+	var live bytes.Buffer
+	result, err := RunAgent(
+		context.Background(),
+		t.TempDir(),
+		"test",
+		WithCommandFactory(helperFactory("env")),
+		WithStdoutWriter(&live),
+		WithTimeout(5*time.Second),
+		WithEnv([]string{"DD_AGENT_VAR=from-project"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "from-project" {
+		t.Errorf("stdout = %q, want %q", result.Stdout, "from-project")
+	}
+}
+
+func TestRunAgent_WithBackend(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "fake-claude")
+	// Emit a Claude-style result event carrying the --model value.
+	script := "#!/bin/sh\necho '{\"type\":\"result\",\"is_error\":false,\"session_id\":\"'\"$2\"'\"}'\n"
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var live bytes.Buffer
+	result, err := RunAgent(
+		context.Background(),
+		t.TempDir(),
+		"test",
+		WithBackend(agent.Claude{Command: bin}),
+		WithModel("custom-model"),
+		WithStdoutWriter(&live),
+		WithTimeout(5*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ChatID != "custom-model" {
+		t.Errorf("chatID = %q, want %q (stdout %q)", result.ChatID, "custom-model", result.Stdout)
+	}
+}
+
+func TestRunAgent_NonCursorBackendOmitsDefaultModel(t *testing.T) {
+	var live bytes.Buffer
+	result, err := RunAgent(
+		context.Background(),
+		t.TempDir(),
+		"hi",
+		WithBackend(agent.Aider{}),
+		WithCommandFactory(helperFactory("echo")),
+		WithStdoutWriter(&live),
+		WithTimeout(5*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "--yes-always --no-pretty --no-stream --message hi"
+	if result.Stdout != want {
+		t.Errorf("stdout = %q, want %q", result.Stdout, want)
+	}
+}
+
 func TestRunAgent_NonZeroExit(t *testing.T) {
 	var live bytes.Buffer
 	result, err := RunAgent(
@@ -208,94 +362,3 @@ func TestRunAgent_InvalidWorkDir(t *testing.T) {
 		t.Fatal("expected error for invalid work dir")
 	}
 }
-
-// ---------------------------------------------------------------------------
-// parseAgentResultEvent tests
-// ---------------------------------------------------------------------------
-
-func TestParseAgentResultEvent_ChatID(t *testing.T) {
-	stdout := `{"type":"system","content":"system prompt"}
-{"type":"tool_call","subtype":"started","name":"read","arguments":{"path":"foo.go"}}
-{"type":"result","chatId":"chat-abc123","duration_ms":5000}
-`
-	chatID, errMsg := parseAgentResultEvent(stdout)
-	if chatID != "chat-abc123" {
-		t.Errorf("chatID = %q, want %q", chatID, "chat-abc123")
-	}
-	if errMsg != "" {
-		t.Errorf("errorMsg = %q, want empty", errMsg)
-	}
-}
-
-func TestParseAgentResultEvent_ChatIDSnakeCase(t *testing.T) {
-	stdout := `{"type":"result","chat_id":"chat-xyz789","duration_ms":3000}
-`
-	chatID, errMsg := parseAgentResultEvent(stdout)
-	if chatID != "chat-xyz789" {
-		t.Errorf("chatID = %q, want %q", chatID, "chat-xyz789")
-	}
-	if errMsg != "" {
-		t.Errorf("errorMsg = %q, want empty", errMsg)
-	}
-}
-
-func TestParseAgentResultEvent_ErrorString(t *testing.T) {
-	stdout := `{"type":"result","chatId":"chat-err001","error":"Agent crashed unexpectedly"}
-`
-	chatID, errMsg := parseAgentResultEvent(stdout)
-	if chatID != "chat-err001" {
-		t.Errorf("chatID = %q, want %q", chatID, "chat-err001")
-	}
-	if errMsg != "Agent crashed unexpectedly" {
-		t.Errorf("errorMsg = %q, want %q", errMsg, "Agent crashed unexpectedly")
-	}
-}
-
-func TestParseAgentResultEvent_ErrorObject(t *testing.T) {
-	stdout := `{"type":"result","chatId":"chat-err002","error":{"message":"Detailed error message","code":500}}
-`
-	chatID, errMsg := parseAgentResultEvent(stdout)
-	if chatID != "chat-err002" {
-		t.Errorf("chatID = %q, want %q", chatID, "chat-err002")
-	}
-	if errMsg != "Detailed error message" {
-		t.Errorf("errorMsg = %q, want %q", errMsg, "Detailed error message")
-	}
-}
-
-func TestParseAgentResultEvent_NoResult(t *testing.T) {
-	stdout := `{"type":"system","content":"system prompt"}
-{"type":"tool_call","name":"read"}
-`
-	chatID, errMsg := parseAgentResultEvent(stdout)
-	if chatID != "" {
-		t.Errorf("chatID = %q, want empty", chatID)
-	}
-	if errMsg != "" {
-		t.Errorf("errorMsg = %q, want empty", errMsg)
-	}
-}
-
-func TestParseAgentResultEvent_EmptyStdout(t *testing.T) {
-	chatID, errMsg := parseAgentResultEvent("")
-	if chatID != "" {
-		t.Errorf("chatID = %q, want empty", chatID)
-	}
-	if errMsg != "" {
-		t.Errorf("errorMsg = %q, want empty", errMsg)
-	}
-}
-
-func TestParseAgentResultEvent_InvalidJSON(t *testing.T) {
-	stdout := `not json
-{"type":"result","chatId":"chat-123"}
-more garbage`
-	chatID, errMsg := parseAgentResultEvent(stdout)
-	// Should still extract from the valid line
-	if chatID != "chat-123" {
-		t.Errorf("chatID = %q, want %q", chatID, "chat-123")
-	}
-	if errMsg != "" {
-		t.Errorf("errorMsg = %q, want empty", errMsg)
-	}
-}
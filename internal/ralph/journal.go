@@ -0,0 +1,319 @@
+package ralph
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalDirName is the journal directory under the repo's common git dir.
+const JournalDirName = "ralph/runs"
+
+// Journal event types.
+const (
+	EventRunStart  = "run_start"
+	EventResume    = "resume"
+	EventBeadStart = "bead_start"
+	EventBeadDone  = "bead_done"
+	EventMergeDone = "merge_done"
+	EventRunEnd    = "run_end"
+)
+
+// JournalEvent is one line of a run journal.
+type JournalEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+
+	// run_start
+	RunID    string `json:"run_id,omitempty"`
+	RootBead string `json:"root_bead,omitempty"`
+	WorkDir  string `json:"workdir,omitempty"`
+
+	// bead_start, bead_done, merge_done
+	BeadID       string   `json:"bead_id,omitempty"`
+	WorktreePath string   `json:"worktree,omitempty"`
+	BranchName   string   `json:"branch,omitempty"`
+	Outcome      *Outcome `json:"outcome,omitempty"`
+	Commit       string   `json:"commit,omitempty"` // merge_done: target HEAD after merging
+	PR           string   `json:"pr,omitempty"`     // merge_done: pull request opened instead
+	Error        string   `json:"error,omitempty"`
+
+	// run_end
+	StopReason *StopReason `json:"stop_reason,omitempty"`
+}
+
+// MergeStatus tracks whether a successful bead's branch has been merged.
+type MergeStatus int
+
+const (
+	MergeNone    MergeStatus = iota // Nothing to merge (not successful, or no worktree).
+	MergePending                    // Succeeded in a worktree; merge not finished.
+	MergeDone                       // Merged back, or opened as a pull request.
+	MergeFailed                     // Merge attempted and failed.
+)
+
+// BeadRecord is the journaled state of one bead in a run.
+type BeadRecord struct {
+	ID           string
+	WorktreePath string
+	BranchName   string
+	Finished     bool
+	Outcome      Outcome // valid when Finished
+	Merge        MergeStatus
+	PR           string // pull request URL, with Core.PullRequests
+}
+
+// RunState is a run's state rebuilt by replaying its journal.
+type RunState struct {
+	RunID      string
+	RootBead   string
+	WorkDir    string
+	Beads      map[string]*BeadRecord
+	Order      []string // bead IDs in first-started order
+	Ended      bool
+	StopReason StopReason
+}
+
+// bead returns the record for id, creating it on first use.
+func (s *RunState) bead(id string) *BeadRecord {
+	if r, ok := s.Beads[id]; ok {
+		return r
+	}
+	r := &BeadRecord{ID: id}
+	s.Beads[id] = r
+	s.Order = append(s.Order, id)
+	return r
+}
+
+// apply folds one event into the state.
+func (s *RunState) apply(e JournalEvent) {
+	switch e.Type {
+	case EventRunStart:
+		s.RunID, s.RootBead, s.WorkDir = e.RunID, e.RootBead, e.WorkDir
+	case EventResume:
+		s.Ended = false
+	case EventBeadStart:
+		// A bead retried after resume starts over.
+		r := s.bead(e.BeadID)
+		*r = BeadRecord{ID: r.ID, WorktreePath: e.WorktreePath, BranchName: e.BranchName}
+	case EventBeadDone:
+		r := s.bead(e.BeadID)
+		r.Finished = true
+		if e.Outcome != nil {
+			r.Outcome = *e.Outcome
+		}
+		if e.WorktreePath != "" {
+			r.WorktreePath, r.BranchName = e.WorktreePath, e.BranchName
+		}
+		if r.Outcome == OutcomeSuccess && r.WorktreePath != "" && r.BranchName != "" {
+			r.Merge = MergePending
+		}
+	case EventMergeDone:
+		r := s.bead(e.BeadID)
+		if e.Error != "" {
+			r.Merge = MergeFailed
+		} else {
+			r.Merge, r.PR = MergeDone, e.PR
+		}
+	case EventRunEnd:
+		s.Ended = true
+		if e.StopReason != nil {
+			s.StopReason = *e.StopReason
+		}
+	}
+}
+
+// Journal is an append-only JSONL record of a ralph run, kept under the
+// repo's common git dir so every worktree of the repo sees it. It is safe
+// for concurrent use.
+type Journal struct {
+	mu    sync.Mutex
+	f     *os.File
+	path  string
+	state *RunState
+}
+
+// JournalDir returns the journal directory for the repository containing
+// workDir: <git-common-dir>/ralph/runs.
+func JournalDir(workDir string) (string, error) {
+	out, err := exec.Command("git", "-C", workDir, "rev-parse", "--git-common-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("resolving git common dir: %w", err)
+	}
+	dir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workDir, dir)
+	}
+	return filepath.Join(dir, JournalDirName), nil
+}
+
+// newRunID returns a sortable, unique run ID like 20260206-153045-a1b2.
+func newRunID() string {
+	var b [2]byte
+	_, _ = rand.Read(b[:])
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// NewJournal starts a journal for a new run and records its start.
+func NewJournal(workDir, rootBead string) (*Journal, error) {
+	dir, err := JournalDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating journal dir: %w", err)
+	}
+	runID := newRunID()
+	path := filepath.Join(dir, runID+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("creating journal: %w", err)
+	}
+	// The absolute workDir tells the runs of the repo's worktrees apart
+	// (see LatestRun).
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+	j := &Journal{f: f, path: path, state: &RunState{Beads: make(map[string]*BeadRecord)}}
+	if err := j.Record(JournalEvent{Type: EventRunStart, RunID: runID, RootBead: rootBead, WorkDir: workDir}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// OpenJournal reopens the journal of an earlier run for resuming. The
+// replayed state is available through State; a resume event is appended.
+func OpenJournal(workDir, runID string) (*Journal, error) {
+	dir, err := JournalDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, runID+".jsonl")
+	state, err := ReadJournal(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("run %s not found in %s", runID, dir)
+		}
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	j := &Journal{f: f, path: path, state: state}
+	if err := j.Record(JournalEvent{Type: EventResume}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// ReadJournal replays a journal file into a RunState. A truncated last
+// line (e.g. from a crash mid-write) is ignored.
+func ReadJournal(path string) (*RunState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	state := &RunState{Beads: make(map[string]*BeadRecord)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e JournalEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		state.apply(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading journal %s: %w", path, err)
+	}
+	if state.RunID == "" {
+		return nil, fmt.Errorf("journal %s has no run_start event", path)
+	}
+	return state, nil
+}
+
+// LatestRun returns the state of the newest run started in workDir, or
+// nil if there is none. The journal directory is shared by all worktrees
+// of the repository; runs of other worktrees are skipped.
+func LatestRun(workDir string) (*RunState, error) {
+	dir, err := JournalDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+	// Run IDs sort by start time.
+	for i := len(entries) - 1; i >= 0; i-- {
+		name := entries[i].Name()
+		if !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		state, err := ReadJournal(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if filepath.Clean(state.WorkDir) == workDir {
+			return state, nil
+		}
+	}
+	return nil, nil
+}
+
+// RunID returns the run's ID.
+func (j *Journal) RunID() string { return j.state.RunID }
+
+// Path returns the journal file path.
+func (j *Journal) Path() string { return j.path }
+
+// State returns the run state as of the last recorded event. For a
+// resumed run it includes everything recorded before the resume.
+func (j *Journal) State() *RunState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// Record appends an event (stamping its time) and applies it to State.
+// Each event is synced so the journal survives crashes.
+func (j *Journal) Record(e JournalEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	j.state.apply(e)
+	return j.f.Sync()
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,292 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJournal_RecordAndReplay(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	j, err := NewJournal(repo, "epic-1")
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	wantDir := filepath.Join(repo, ".git", JournalDirName)
+	if filepath.Dir(j.Path()) != wantDir {
+		t.Errorf("journal path = %s, want under %s", j.Path(), wantDir)
+	}
+
+	success, failure := OutcomeSuccess, OutcomeFailure
+	events := []JournalEvent{
+		{Type: EventBeadStart, BeadID: "a", WorktreePath: "/tmp/ralph-a", BranchName: "ralph/a"},
+		{Type: EventBeadStart, BeadID: "b", WorktreePath: "/tmp/ralph-b", BranchName: "ralph/b"},
+		{Type: EventBeadStart, BeadID: "c", WorktreePath: "/tmp/ralph-c", BranchName: "ralph/c"},
+		{Type: EventBeadDone, BeadID: "a", WorktreePath: "/tmp/ralph-a", BranchName: "ralph/a", Outcome: &success},
+		{Type: EventBeadDone, BeadID: "b", WorktreePath: "/tmp/ralph-b", BranchName: "ralph/b", Outcome: &success},
+		{Type: EventMergeDone, BeadID: "b"},
+	}
+	for _, e := range events {
+		if err := j.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash mid-write.
+	f, err := os.OpenFile(j.Path(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"type":"bead_do`)
+	_ = f.Close()
+
+	state, err := ReadJournal(j.Path())
+	if err != nil {
+		t.Fatalf("ReadJournal: %v", err)
+	}
+	if state.RunID != j.RunID() || state.RootBead != "epic-1" {
+		t.Errorf("run = %s/%s, want %s/epic-1", state.RunID, state.RootBead, j.RunID())
+	}
+	if strings.Join(state.Order, ",") != "a,b,c" {
+		t.Errorf("order = %v, want [a b c]", state.Order)
+	}
+	checks := []struct {
+		id       string
+		finished bool
+		merge    MergeStatus
+	}{
+		{"a", true, MergePending},
+		{"b", true, MergeDone},
+		{"c", false, MergeNone},
+	}
+	for _, c := range checks {
+		r := state.Beads[c.id]
+		if r.Finished != c.finished || r.Merge != c.merge {
+			t.Errorf("%s: finished=%v merge=%v, want %v/%v", c.id, r.Finished, r.Merge, c.finished, c.merge)
+		}
+	}
+	if state.Ended {
+		t.Error("run without run_end should not be ended")
+	}
+
+	// A bead retried after resume starts over.
+	state.apply(JournalEvent{Type: EventBeadDone, BeadID: "c", Outcome: &failure})
+	state.apply(JournalEvent{Type: EventBeadStart, BeadID: "c", WorktreePath: "/tmp/ralph-c", BranchName: "ralph/c"})
+	if r := state.Beads["c"]; r.Finished {
+		t.Error("restarted bead should not be finished")
+	}
+}
+
+func TestOpenJournal_UnknownRun(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	_, err := OpenJournal(repo, "no-such-run")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not-found error, got %v", err)
+	}
+}
+
+func TestLatestRun(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	if state, err := LatestRun(repo); err != nil || state != nil {
+		t.Fatalf("LatestRun without runs = %v, %v; want nil, nil", state, err)
+	}
+	wt := filepath.Join(t.TempDir(), "wt")
+	if out, err := exec.Command("git", "-C", repo, "worktree", "add", "-q", "-b", "other", wt).CombinedOutput(); err != nil {
+		t.Fatalf("git worktree add: %v\n%s", err, out)
+	}
+
+	runs := make(map[string]string)
+	for _, dir := range []string{repo, wt} {
+		j, err := NewJournal(dir, "")
+		if err != nil {
+			t.Fatalf("NewJournal: %v", err)
+		}
+		_ = j.Close()
+		runs[dir] = j.RunID()
+	}
+	for _, dir := range []string{repo, wt} {
+		state, err := LatestRun(dir)
+		if err != nil {
+			t.Fatalf("LatestRun(%s): %v", dir, err)
+		}
+		if state == nil || state.RunID != runs[dir] {
+			t.Errorf("LatestRun(%s) = %+v, want run %s", dir, state, runs[dir])
+		}
+	}
+}
+
+func TestCore_Run_Resume(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	prefix := fmt.Sprintf("resume%d", time.Now().UnixNano())
+	merged, abandoned := prefix+"-merged", prefix+"-abandoned"
+
+	// First session: "merged" succeeded with a commit but was never merged;
+	// "abandoned" was still running when ralph died.
+	j, err := NewJournal(repo, "epic")
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	wtMgr, err := NewWorktreeManager(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mergedPath, mergedBranch, err := wtMgr.CreateWorktree(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mergedPath, "work.txt"), []byte("done\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "work.txt"}, {"commit", "-m", "work"}} {
+		if out, err := exec.Command("git", append([]string{"-C", mergedPath}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	abandonedPath, abandonedBranch, err := wtMgr.CreateWorktree(abandoned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = wtMgr.RemoveWorktree(abandonedPath) })
+	success := OutcomeSuccess
+	for _, e := range []JournalEvent{
+		{Type: EventBeadStart, BeadID: merged, WorktreePath: mergedPath, BranchName: mergedBranch},
+		{Type: EventBeadStart, BeadID: abandoned, WorktreePath: abandonedPath, BranchName: abandonedBranch},
+		{Type: EventBeadDone, BeadID: merged, WorktreePath: mergedPath, BranchName: mergedBranch, Outcome: &success},
+	} {
+		if err := j.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = j.Close()
+
+	// Second session resumes. bd still offers both beads.
+	resumed, err := OpenJournal(repo, j.RunID())
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	defer resumed.Close()
+
+	bd := newFakeReadyBD([]string{merged, abandoned}, nil)
+	var mu sync.Mutex
+	var executed []string
+	c := newSchedulerTestCore(t, bd)
+	c.WorkDir = repo
+	c.Journal = resumed
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		mu.Lock()
+		executed = append(executed, beadIDFromWorkDir(workDir))
+		mu.Unlock()
+		return &AgentResult{}, nil
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(executed) != 1 || executed[0] != abandoned {
+		t.Errorf("executed = %v, want only %s", executed, abandoned)
+	}
+	if result.Succeeded != 2 {
+		t.Errorf("succeeded = %d, want the recovered merge and %s", result.Succeeded, abandoned)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "work.txt")); err != nil {
+		t.Errorf("pending merge was not finished: %v", err)
+	}
+	if _, err := os.Stat(mergedPath); !os.IsNotExist(err) {
+		t.Errorf("merged worktree %s should be removed", mergedPath)
+	}
+
+	state := resumed.State()
+	if !state.Ended || state.Beads[merged].Merge != MergeDone || state.Beads[abandoned].Merge != MergeDone {
+		t.Errorf("journal state after resume = ended:%v merged:%v abandoned:%v", state.Ended, state.Beads[merged].Merge, state.Beads[abandoned].Merge)
+	}
+}
+
+func TestCore_Run_ResumeRerunsFailedMerges(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	prefix := fmt.Sprintf("remerge%d", time.Now().UnixNano())
+	failed, lost := prefix+"-failed", prefix+"-lost"
+
+	// First session: "failed" succeeded but its merge failed; "lost" was
+	// waiting to merge a branch that is gone by the resume.
+	j, err := NewJournal(repo, "epic")
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	success := OutcomeSuccess
+	lostPath := filepath.Join(t.TempDir(), "gone")
+	for _, e := range []JournalEvent{
+		{Type: EventBeadStart, BeadID: failed},
+		{Type: EventBeadDone, BeadID: failed, Outcome: &success},
+		{Type: EventMergeDone, BeadID: failed, Error: "merge conflict"},
+		{Type: EventBeadStart, BeadID: lost, WorktreePath: lostPath, BranchName: "ralph/" + lost},
+		{Type: EventBeadDone, BeadID: lost, WorktreePath: lostPath, BranchName: "ralph/" + lost, Outcome: &success},
+	} {
+		if err := j.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = j.Close()
+
+	resumed, err := OpenJournal(repo, j.RunID())
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	defer resumed.Close()
+
+	bd := newFakeReadyBD([]string{failed, lost}, nil)
+	var mu sync.Mutex
+	var executed []string
+	c := newSchedulerTestCore(t, bd)
+	c.WorkDir = repo
+	c.Journal = resumed
+	var report bytes.Buffer
+	c.Reporter = NewReporter(&report, ReportJSON)
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		mu.Lock()
+		executed = append(executed, beadIDFromWorkDir(workDir))
+		mu.Unlock()
+		return &AgentResult{}, commitFile(workDir, beadIDFromWorkDir(workDir)+".txt")
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(executed) != 2 {
+		t.Errorf("executed = %v, want both beads run again", executed)
+	}
+	if result.Succeeded != 2 || result.Failed != 1 {
+		t.Errorf("succeeded/failed = %d/%d, want 2/1 with the failed recovered merge", result.Succeeded, result.Failed)
+	}
+	var doc Report
+	if err := json.Unmarshal(report.Bytes(), &doc); err != nil {
+		t.Fatalf("report: %v\n%s", err, report.String())
+	}
+	var merges []string
+	for _, b := range doc.Beads {
+		merges = append(merges, b.ID+" "+b.Merge)
+	}
+	want := []string{lost + " " + MergeResultFailed}
+	if len(merges) != 3 || merges[0] != want[0] {
+		t.Errorf("report merges = %v, want the recovered %v first, then both reruns", merges, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"devdeploy/internal/agent"
 	"devdeploy/internal/beads"
 )
 
@@ -18,6 +19,7 @@ const (
 	StopWallClock                          // Total --timeout wall-clock exceeded.
 	StopContextCancelled                   // Context cancelled (e.g. SIGINT).
 	StopAllBeadsSkipped                    // All available beads were skipped (retry detection).
+	StopBudget                             // Cumulative cost passed --budget.
 )
 
 // String returns a human-readable label for the stop reason.
@@ -35,6 +37,8 @@ func (r StopReason) String() string {
 		return "context-cancelled"
 	case StopAllBeadsSkipped:
 		return "all-beads-skipped"
+	case StopBudget:
+		return "budget-exceeded"
 	default:
 		return "unknown"
 	}
@@ -55,6 +59,8 @@ func (r StopReason) ExitCode() int {
 		return 5
 	case StopAllBeadsSkipped:
 		return 6
+	case StopBudget:
+		return 7
 	default:
 		return 1
 	}
@@ -84,6 +90,8 @@ func (r *StopReason) UnmarshalJSON(data []byte) error {
 		*r = StopContextCancelled
 	case "all-beads-skipped":
 		*r = StopAllBeadsSkipped
+	case "budget-exceeded":
+		*r = StopBudget
 	default:
 		return fmt.Errorf("unknown StopReason: %s", s)
 	}
@@ -111,6 +119,22 @@ type BeadResult struct {
 	ErrorMessage string // Error message from the agent
 	ExitCode     int    // Agent process exit code
 	Stderr       string // Stderr output from the agent
+
+	// Model is the model the agent ran on; empty means the CLI default.
+	// Attempt counts the bead's attempts in the run from 1; retries (see
+	// Core.Retry and Core.EscalationModel) have higher ones.
+	Model   string
+	Attempt int
+
+	// Review is the reviewer pass verdict; nil when no review ran.
+	Review *Review
+
+	// Usage is the tokens and cost of the bead's agent and reviewer runs.
+	Usage agent.Usage
+
+	// Transcript is the path prefix of the agent's transcript files, when
+	// Core.Transcripts is set.
+	Transcript string
 }
 
 // RunSummary holds aggregate results across all iterations.
@@ -123,6 +147,7 @@ type RunSummary struct {
 	Skipped    int
 	StopReason StopReason
 	Duration   time.Duration
+	Usage      agent.Usage // tokens and cost of all beads
 }
 
 // FormatDuration formats a duration in a human-readable way (e.g., "2m34s", "1h12m").
@@ -142,3 +167,23 @@ func FormatDuration(d time.Duration) string {
 	}
 	return fmt.Sprintf("%ds", s)
 }
+
+// FormatUsage formats token usage and cost, e.g.
+// "$1.24 (120.5k in, 8.2k out, 1.2M cache read, 40.0k cache write)".
+func FormatUsage(u agent.Usage) string {
+	return fmt.Sprintf("$%.2f (%s in, %s out, %s cache read, %s cache write)",
+		u.CostUSD, formatTokens(u.InputTokens), formatTokens(u.OutputTokens),
+		formatTokens(u.CacheReadTokens), formatTokens(u.CacheWriteTokens))
+}
+
+// formatTokens abbreviates a token count (e.g. "950", "12.3k", "1.2M").
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
@@ -3,6 +3,7 @@ package ralph
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,6 +14,10 @@ import (
 	"devdeploy/internal/beads"
 )
 
+// ErrMergeConflict is wrapped by the error MergeBranches returns when the
+// merge stopped on conflicts.
+var ErrMergeConflict = errors.New("conflicts detected")
+
 // MergeBranches merges sourceBranch into targetBranch in the given repository.
 // If the repository is a worktree, it merges within that worktree.
 // If hooks should be disabled, set disableHooks to true.
@@ -103,7 +108,7 @@ func MergeBranches(repoPath, targetBranch, sourceBranch string, disableHooks boo
 		if hasMergeConflicts(repoPath) {
 			// If beadID is provided, create a question bead for the conflict
 			if beadID != "" {
-				if createErr := createQuestionBeadForMergeConflict(repoPath, beadID, targetBranch, sourceBranch); createErr != nil {
+				if createErr := createQuestionBeadForMergeConflict(repoPath, beadID, targetBranch, sourceBranch, readMergeConflicts(repoPath), ""); createErr != nil {
 					// Log the error but don't fail the merge error - the conflict is the real issue
 					_ = createErr // Best effort - if bead creation fails, still return merge error
 				}
@@ -113,9 +118,9 @@ func MergeBranches(repoPath, targetBranch, sourceBranch string, disableHooks boo
 			_ = abortCmd.Run() // Best effort - if abort fails, we still return the conflict error
 			
 			if msg != "" {
-				return fmt.Errorf("merge %s into %s: conflicts detected: %s: %w", sourceRef, targetBranch, msg, err)
+				return fmt.Errorf("merge %s into %s: %w: %s: %w", sourceRef, targetBranch, ErrMergeConflict, msg, err)
 			}
-			return fmt.Errorf("merge %s into %s: conflicts detected: %w", sourceRef, targetBranch, err)
+			return fmt.Errorf("merge %s into %s: %w: %w", sourceRef, targetBranch, ErrMergeConflict, err)
 		}
 		
 		if msg != "" {
@@ -127,75 +132,6 @@ func MergeBranches(repoPath, targetBranch, sourceBranch string, disableHooks boo
 	return nil
 }
 
-// MergePromptData holds the variables injected into the merge agent prompt template.
-type MergePromptData struct {
-	ID            string // Bead ID (e.g. "devdeploy-x99.3")
-	Title         string // Bead title
-	Description   string // Full bead description (markdown)
-	TargetBranch  string // Target branch to merge into
-	SourceBranch  string // Source branch to merge from
-	RepoPath      string // Repository path
-}
-
-// mergePromptTemplate is the Go text/template used to craft the merge agent prompt.
-// It is parsed once at init time so rendering is cheap.
-var mergePromptTemplate = template.Must(template.New("mergePrompt").Parse(mergePromptTemplateText))
-
-const mergePromptTemplateText = `You are working on bead {{.ID}}.
-
-# {{.Title}}
-
-{{.Description}}
-
----
-
-## Merge Task
-
-Merge branch **{{.SourceBranch}}** into **{{.TargetBranch}}** in repository: ` + "`{{.RepoPath}}`" + `
-
-## Workflow
-
-1. **Claim this bead** before starting work:
-   ` + "`" + `bd update {{.ID}} --status in_progress` + "`" + `
-
-2. **Follow project conventions**: read and obey ` + "`.cursor/rules/`" + ` and ` + "`AGENTS.md`" + `.
-
-3. **Perform the merge**:
-   - Ensure you're on the target branch (` + "`{{.TargetBranch}}`" + `)
-   - Merge ` + "`{{.SourceBranch}}`" + ` into ` + "`{{.TargetBranch}}`" + `
-   - Resolve any conflicts if they occur
-   - Test that the merge was successful
-
-4. **Close the bead** when the work is complete:
-   ` + "`" + `bd close {{.ID}}` + "`" + `
-
-5. **Push your work** — work is not done until pushed:
-   ` + "```" + `
-   git add -A && git commit -m "<concise message>"
-   git pull --rebase && bd sync && git push
-   ` + "```" + `
-
-## If you need human input
-
-If the bead is ambiguous or you need information you cannot find in the codebase:
-
-1. Create a question bead:
-   ` + "`" + `bd create "Question: <your question>" --type task --label needs-human --parent {{.ID}}` + "`" + `
-2. Add a blocking dependency so the original bead drops off bd ready:
-   ` + "`" + `bd dep add {{.ID}} <question-id>` + "`" + `
-3. **Stop working on this bead** — do not guess. Move on.
-
-Do NOT close this bead if you created a blocking question.`
-
-// RenderMergePrompt renders the merge agent prompt template with the given merge data.
-func RenderMergePrompt(data *MergePromptData) (string, error) {
-	var buf bytes.Buffer
-	if err := mergePromptTemplate.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("rendering merge prompt template: %w", err)
-	}
-	return buf.String(), nil
-}
-
 // conflictResolutionPromptTemplate is a simplified prompt for resolving merge conflicts.
 var conflictResolutionPromptTemplate = template.Must(template.New("conflictResolution").Parse(conflictResolutionPromptText))
 
@@ -212,14 +148,34 @@ Working directory: ` + "`{{.RepoPath}}`" + `
 ## Conflicting Files
 
 {{.ConflictDetails}}
+{{- if .PreviousFailure}}
+
+## Previous Attempt
+
+This is attempt {{.Attempt}}. The previous resolution was rejected:
+
+` + "```" + `
+{{.PreviousFailure}}
+` + "```" + `
+
+Its changes are still in the working directory; fix them rather than starting over.
+{{- end}}
 
 ## Your Task
 
 1. **Examine the conflicts** - Look at each conflicting file to understand what changed on both branches
 2. **Resolve conflicts** - Edit the files to combine changes appropriately, removing conflict markers (<<<<<<, ======, >>>>>>)
 3. **Stage resolved files** - ` + "`git add <resolved-file>`" + `
+{{- if eq .Strategy "squash"}}
+4. **Do not commit** - this is a squash merge; the caller commits the staged result with a message of its own
+5. **Verify** - Run ` + "`git status`" + ` to confirm no conflicts are left
+{{- else if eq .Strategy "rebase"}}
+4. **Do not continue the cherry-pick** - the branch's commits are being replayed onto the target branch one at a time; the caller continues once the resolution is staged
+5. **Verify** - Run ` + "`git status`" + ` to confirm no conflicts are left
+{{- else}}
 4. **Complete the merge** - ` + "`git commit --no-edit`" + ` (uses the default merge commit message)
 5. **Verify** - Run ` + "`git status`" + ` to confirm the merge is complete
+{{- end}}
 
 ## Important
 
@@ -242,6 +198,9 @@ type ConflictResolutionData struct {
 	ConflictDetails string
 	BeadID          string
 	BeadTitle       string
+	Strategy        MergeStrategy // how the branch is landing; "" is a merge
+	Attempt         int           // 1 for the first agent
+	PreviousFailure string        // why the previous attempt's resolution was rejected
 }
 
 // RenderConflictResolutionPrompt renders the conflict resolution prompt.
@@ -263,123 +222,29 @@ func getConflictDetails(repoPath string) string {
 	return strings.TrimSpace(string(statusOut))
 }
 
-// MergeWithAgentResolution attempts to merge sourceBranch into targetBranch.
-// If conflicts occur, it spawns an agent to resolve them automatically.
-// If the agent fails to resolve conflicts, it creates a question bead and aborts.
-// Returns nil on success, error on failure.
-func MergeWithAgentResolution(ctx context.Context, repoPath, targetBranch, sourceBranch string, beadID, beadTitle string, agentTimeout time.Duration) error {
-	// First, try the merge
-	err := MergeBranches(repoPath, targetBranch, sourceBranch, true, "")
-	if err == nil {
-		return nil // Merge succeeded without conflicts
-	}
-
-	// Check if error was due to conflicts (MergeBranches aborts on conflict)
-	if !strings.Contains(err.Error(), "conflicts detected") {
-		return err // Not a conflict error, return as-is
+// MergeWithAgentResolution attempts to land sourceBranch on targetBranch
+// with the given strategy ("" is MergeStrategyMerge).
+// If conflicts occur, it spawns agents to resolve them, as bounded and
+// checked by resolution (see ConflictResolution).
+// If the agents fail to resolve conflicts, it creates a question bead and aborts.
+// Returns nil on success, error on failure. The agents' prompt comes from
+// tmpl (nil means the built-in one). Extra opts are passed to the
+// conflict resolution agents. MergeStrategyFFOnly never needs an agent: a
+// branch that cannot be fast-forwarded is an error.
+func MergeWithAgentResolution(ctx context.Context, repoPath, targetBranch, sourceBranch string, beadID, beadTitle string, strategy MergeStrategy, resolution ConflictResolution, agentTimeout time.Duration, tmpl *Templates, agentOpts ...Option) error {
+	m := &mergeRequest{
+		repoPath:     repoPath,
+		targetBranch: targetBranch,
+		sourceBranch: sourceBranch,
+		beadID:       beadID,
+		beadTitle:    beadTitle,
+		strategy:     strategy,
+		resolution:   resolution,
+		agentTimeout: agentTimeout,
+		tmpl:         tmpl,
+		agentOpts:    agentOpts,
 	}
-
-	// Conflicts detected but were aborted. Re-attempt the merge to get into conflicted state
-	// for agent resolution.
-	// Check if we're already on the target branch before checking out
-	currentBranchCmd := exec.Command("git", "-C", repoPath, "rev-parse", "--abbrev-ref", "HEAD")
-	currentBranchOut, err := currentBranchCmd.Output()
-	currentBranch := ""
-	if err == nil {
-		currentBranch = strings.TrimSpace(string(currentBranchOut))
-	}
-	if currentBranch != targetBranch {
-		checkoutCmd := exec.Command("git", "-C", repoPath, "checkout", targetBranch)
-		var checkoutStderr bytes.Buffer
-		checkoutCmd.Stderr = &checkoutStderr
-		if checkoutErr := checkoutCmd.Run(); checkoutErr != nil {
-			msg := strings.TrimSpace(checkoutStderr.String())
-			// If branch is already checked out in another worktree, that's okay - we're probably
-			// already in the right worktree (MergeBranches would have failed earlier if not)
-			if strings.Contains(msg, "is already used by worktree") {
-				// Verify we're actually on the target branch (shouldn't happen, but be safe)
-				if currentBranch != targetBranch {
-					return fmt.Errorf("checkout %s for conflict resolution: branch is checked out in another worktree, and we're not on it: %s", targetBranch, msg)
-				}
-			} else {
-				return fmt.Errorf("checkout %s for conflict resolution: %s: %w", targetBranch, msg, checkoutErr)
-			}
-		}
-	}
-
-	mergeCmd := exec.Command("git", "-C", repoPath, "merge", sourceBranch, "--no-edit")
-	mergeCmd.Run() // This will fail with conflicts, which is expected
-
-	if !hasMergeConflicts(repoPath) {
-		// Somehow no conflicts now - merge must have succeeded
-		return nil
-	}
-
-	// Get conflict details for the prompt
-	conflictDetails := getConflictDetails(repoPath)
-
-	// Render conflict resolution prompt
-	promptData := &ConflictResolutionData{
-		TargetBranch:    targetBranch,
-		SourceBranch:    sourceBranch,
-		RepoPath:        repoPath,
-		ConflictDetails: conflictDetails,
-		BeadID:          beadID,
-		BeadTitle:       beadTitle,
-	}
-	prompt, renderErr := RenderConflictResolutionPrompt(promptData)
-	if renderErr != nil {
-		// Abort merge and return error
-		abortCmd := exec.Command("git", "-C", repoPath, "merge", "--abort")
-		_ = abortCmd.Run()
-		return fmt.Errorf("rendering conflict resolution prompt: %w", renderErr)
-	}
-
-	// Run agent to resolve conflicts
-	var opts []Option
-	if agentTimeout > 0 {
-		opts = append(opts, WithTimeout(agentTimeout))
-	}
-	result, agentErr := RunAgent(ctx, repoPath, prompt, opts...)
-	if agentErr != nil {
-		// Agent failed to run - abort merge and create question bead
-		abortCmd := exec.Command("git", "-C", repoPath, "merge", "--abort")
-		_ = abortCmd.Run()
-		if beadID != "" {
-			_ = createQuestionBeadForMergeConflict(repoPath, beadID, targetBranch, sourceBranch)
-		}
-		return fmt.Errorf("merge conflict resolution agent failed: %w", agentErr)
-	}
-
-	// Check if agent resolved the conflicts
-	if hasMergeConflicts(repoPath) {
-		// Agent didn't resolve all conflicts - abort and create question bead
-		abortCmd := exec.Command("git", "-C", repoPath, "merge", "--abort")
-		_ = abortCmd.Run()
-		if beadID != "" {
-			_ = createQuestionBeadForMergeConflict(repoPath, beadID, targetBranch, sourceBranch)
-		}
-		return fmt.Errorf("agent could not resolve merge conflicts (exit code: %d)", result.ExitCode)
-	}
-
-	// Conflicts resolved! Verify the merge commit exists
-	statusCmd := exec.Command("git", "-C", repoPath, "status", "--porcelain")
-	statusOut, _ := statusCmd.Output()
-	if len(strings.TrimSpace(string(statusOut))) > 0 {
-		// There are uncommitted changes - agent may have resolved but not committed
-		// Try to complete the merge commit
-		commitCmd := exec.Command("git", "-C", repoPath, "commit", "--no-edit")
-		if commitErr := commitCmd.Run(); commitErr != nil {
-			abortCmd := exec.Command("git", "-C", repoPath, "merge", "--abort")
-			_ = abortCmd.Run()
-			if beadID != "" {
-				_ = createQuestionBeadForMergeConflict(repoPath, beadID, targetBranch, sourceBranch)
-			}
-			return fmt.Errorf("agent resolved conflicts but failed to commit: %w", commitErr)
-		}
-	}
-
-	return nil
+	return m.land(ctx)
 }
 
 // hasMergeConflicts checks if there are merge conflicts in the repository.
@@ -411,26 +276,10 @@ func hasMergeConflicts(repoPath string) bool {
 }
 
 // createQuestionBeadForMergeConflict creates a question bead when merge conflicts cannot be resolved.
-func createQuestionBeadForMergeConflict(repoPath, beadID, targetBranch, sourceBranch string) error {
-	// Get conflict details for the description
-	statusCmd := exec.Command("git", "-C", repoPath, "status", "--short")
-	statusOut, err := statusCmd.Output()
-	conflictDetails := ""
-	if err == nil {
-		conflictDetails = strings.TrimSpace(string(statusOut))
-	}
-	
-	// Build description with context
-	description := fmt.Sprintf(`Merge conflicts detected when attempting to merge **%s** into **%s**.
-
-## Context
-The merge agent attempted to merge these branches but encountered conflicts that require human intervention.
-
-## Conflict Details
-%s
-
-## Next Steps
-Please resolve the conflicts manually and complete the merge.`, sourceBranch, targetBranch, conflictDetails)
+// Its description lists the conflicting files and hunks, and failure, if
+// set, says why the agents' resolutions were rejected.
+func createQuestionBeadForMergeConflict(repoPath, beadID, targetBranch, sourceBranch string, conflicts *mergeConflicts, failure string) error {
+	description := mergeConflictQuestion(targetBranch, sourceBranch, conflicts, failure)
 	
 	// Create the question bead
 	title := fmt.Sprintf("Question: Merge conflicts in %s → %s", sourceBranch, targetBranch)
@@ -1,6 +1,7 @@
 package ralph
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -276,8 +277,8 @@ func TestMergeBranches(t *testing.T) {
 					t.Error("MergeBranches() expected conflict error, got nil")
 					return
 				}
-				if !strings.Contains(err.Error(), "conflicts detected") {
-					t.Errorf("MergeBranches() error = %v, expected 'conflicts detected'", err)
+				if !errors.Is(err, ErrMergeConflict) {
+					t.Errorf("MergeBranches() error = %v, expected ErrMergeConflict", err)
 				}
 
 				// Verify merge was aborted and repo is clean (no lingering conflicts)
@@ -309,71 +310,6 @@ func TestMergeBranches(t *testing.T) {
 	}
 }
 
-// TestRenderMergePrompt tests the RenderMergePrompt function.
-func TestRenderMergePrompt(t *testing.T) {
-	tests := []struct {
-		name    string
-		data    *MergePromptData
-		wantErr bool
-		want    []string // substrings that should be in the rendered prompt
-	}{
-		{
-			name: "valid prompt data",
-			data: &MergePromptData{
-				ID:            "test-1",
-				Title:         "Test Merge",
-				Description:   "Test description",
-				TargetBranch:  "main",
-				SourceBranch:  "feature",
-				RepoPath:      "/path/to/repo",
-			},
-			wantErr: false,
-			want: []string{
-				"test-1",
-				"Test Merge",
-				"Test description",
-				"main",
-				"feature",
-				"/path/to/repo",
-				"bd update test-1",
-				"bd close test-1",
-			},
-		},
-		{
-			name: "empty data",
-			data: &MergePromptData{
-				ID:            "",
-				Title:         "",
-				Description:   "",
-				TargetBranch:  "",
-				SourceBranch:  "",
-				RepoPath:      "",
-			},
-			wantErr: false,
-			want:    []string{},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := RenderMergePrompt(tt.data)
-
-			if (err != nil) != tt.wantErr {
-				t.Errorf("RenderMergePrompt() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-
-			if !tt.wantErr {
-				for _, wantSubstr := range tt.want {
-					if !strings.Contains(got, wantSubstr) {
-						t.Errorf("RenderMergePrompt() output missing substring %q. Got: %s", wantSubstr, got)
-					}
-				}
-			}
-		})
-	}
-}
-
 // TestHasMergeConflicts tests the hasMergeConflicts function.
 func TestHasMergeConflicts(t *testing.T) {
 	tests := []struct {
@@ -490,8 +426,8 @@ func TestCreateQuestionBeadForMergeConflict(t *testing.T) {
 		if err == nil {
 			t.Error("MergeBranches() expected error for conflicts, got nil")
 		}
-		if err != nil && !strings.Contains(err.Error(), "conflicts detected") {
-			t.Errorf("MergeBranches() error = %v, expected 'conflicts detected'", err)
+		if err != nil && !errors.Is(err, ErrMergeConflict) {
+			t.Errorf("MergeBranches() error = %v, expected ErrMergeConflict", err)
 		}
 	})
 }
@@ -583,7 +519,7 @@ func TestMergeBranches_conflictWithBeadID(t *testing.T) {
 		t.Error("MergeBranches() expected error for conflicts, got nil")
 		return
 	}
-	if !strings.Contains(err.Error(), "conflicts detected") {
-		t.Errorf("MergeBranches() error = %v, expected 'conflicts detected'", err)
+	if !errors.Is(err, ErrMergeConflict) {
+		t.Errorf("MergeBranches() error = %v, expected ErrMergeConflict", err)
 	}
 }
@@ -0,0 +1,337 @@
+package ralph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MergeStrategy is how a bead's branch lands on the target branch.
+// Conflicts in a merge, squash or rebase go to resolution agents (see
+// ConflictResolution); the bead's ralph/<id> branch is never rewritten.
+type MergeStrategy string
+
+const (
+	// MergeStrategyMerge runs `git merge`: a merge commit when the branches
+	// diverged, a fast-forward otherwise. It is the default.
+	MergeStrategyMerge MergeStrategy = "merge"
+	// MergeStrategySquash lands the branch as a single commit whose
+	// message is generated from the bead.
+	MergeStrategySquash MergeStrategy = "squash"
+	// MergeStrategyRebase replays the branch's commits onto the target,
+	// which is rebasing it and fast-forwarding, without moving the branch.
+	MergeStrategyRebase MergeStrategy = "rebase"
+	// MergeStrategyFFOnly only fast-forwards; a branch that diverged from
+	// the target fails to merge.
+	MergeStrategyFFOnly MergeStrategy = "ff-only"
+)
+
+// MergeStrategies lists the valid merge strategies.
+var MergeStrategies = []MergeStrategy{MergeStrategyMerge, MergeStrategySquash, MergeStrategyRebase, MergeStrategyFFOnly}
+
+// ParseMergeStrategy parses a merge strategy name; "" is MergeStrategyMerge.
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	if s == "" {
+		return MergeStrategyMerge, nil
+	}
+	for _, m := range MergeStrategies {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown merge strategy %q (want merge, squash, rebase or ff-only)", s)
+}
+
+// mergeRequest is a branch to land, with what MergeWithAgentResolution
+// needs to resolve its conflicts.
+type mergeRequest struct {
+	repoPath, targetBranch, sourceBranch string
+	beadID, beadTitle                    string
+	strategy                             MergeStrategy
+	resolution                           ConflictResolution
+	agentTimeout                         time.Duration
+	tmpl                                 *Templates
+	agentOpts                            []Option
+
+	// Set while landing, for landingFailure.
+	head           string // the target's HEAD before landing
+	picks, skipped int    // rebase: commits to replay, and ones skipped as empty
+}
+
+// git runs a git command in the request's repository.
+func (m *mergeRequest) git(args ...string) (string, error) {
+	return runGit(m.repoPath, args...)
+}
+
+// runGit runs a git command in dir and returns its combined output,
+// trimmed.
+func runGit(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// land lands the source branch on the target branch, which must be
+// checked out in repoPath or free to check out there.
+func (m *mergeRequest) land(ctx context.Context) error {
+	if m.strategy == "" || m.strategy == MergeStrategyMerge {
+		return m.merge(ctx)
+	}
+	if err := checkoutForMerge(m.repoPath, m.targetBranch); err != nil {
+		return err
+	}
+	if out, err := m.git("rev-parse", "--verify", m.sourceBranch); err != nil {
+		return fmt.Errorf("source branch %s: %s: %w", m.sourceBranch, out, err)
+	}
+	switch m.strategy {
+	case MergeStrategyFFOnly:
+		if out, err := m.git("merge", "--ff-only", m.sourceBranch); err != nil {
+			return fmt.Errorf("fast-forward %s to %s: %s: %w", m.targetBranch, m.sourceBranch, out, err)
+		}
+		return nil
+	case MergeStrategySquash:
+		return m.squash(ctx)
+	case MergeStrategyRebase:
+		return m.rebase(ctx)
+	}
+	return fmt.Errorf("unknown merge strategy %q", m.strategy)
+}
+
+// merge runs `git merge`, through MergeBranches so a clean merge needs no
+// more than that. On conflicts it merges again, has agents resolve them
+// and completes the merge commit.
+func (m *mergeRequest) merge(ctx context.Context) error {
+	err := MergeBranches(m.repoPath, m.targetBranch, m.sourceBranch, true, "")
+	if !errors.Is(err, ErrMergeConflict) {
+		return err
+	}
+
+	// MergeBranches aborted; merge again to get the conflicts back.
+	if err := checkoutForMerge(m.repoPath, m.targetBranch); err != nil {
+		return fmt.Errorf("conflict resolution: %w", err)
+	}
+	head, err := m.git("rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("resolving %s: %s: %w", m.targetBranch, head, err)
+	}
+	m.head = head
+	abort := func() {
+		_, _ = m.git("merge", "--abort")
+		_, _ = m.git("reset", "--hard", head)
+	}
+	// Without hooks, like MergeBranches.
+	remerge := func() { _, _ = m.git("-c", "core.hooksPath="+os.DevNull, "merge", m.sourceBranch, "--no-edit") }
+	restart := func() {
+		abort()
+		remerge()
+	}
+	remerge() // fails with the conflicts
+	if !hasMergeConflicts(m.repoPath) {
+		return nil // merged after all
+	}
+	if err := m.resolve(ctx, abort, restart); err != nil {
+		return err
+	}
+
+	if m.inProgress("MERGE_HEAD") {
+		if out, err := m.git("commit", "--no-verify", "--no-edit"); err != nil {
+			abort()
+			return fmt.Errorf("agent resolved conflicts but failed to commit: %s: %w", out, err)
+		}
+	} else if _, err := m.git("diff", "--cached", "--quiet"); err != nil {
+		// The agent committed the merge, then fixed what verification
+		// found; fold the fixes into its merge commit.
+		if out, err := m.git("commit", "--no-verify", "--amend", "--no-edit"); err != nil {
+			abort()
+			return fmt.Errorf("amending the merge commit: %s: %w", out, err)
+		}
+	}
+	return nil
+}
+
+// squash stages the branch's changes with `git merge --squash`, has an
+// agent resolve any conflicts and commits the result with squashMessage.
+func (m *mergeRequest) squash(ctx context.Context) error {
+	message, err := m.squashMessage()
+	if err != nil {
+		return err
+	}
+	head, err := m.git("rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("resolving %s: %s: %w", m.targetBranch, head, err)
+	}
+	m.head = head
+	abort := func() { _, _ = m.git("reset", "--hard", head) }
+	restart := func() {
+		abort()
+		_, _ = m.git("merge", "--squash", m.sourceBranch)
+	}
+
+	if out, err := m.git("merge", "--squash", m.sourceBranch); err != nil {
+		if !hasMergeConflicts(m.repoPath) {
+			abort()
+			return fmt.Errorf("squash %s into %s: %s: %w", m.sourceBranch, m.targetBranch, out, err)
+		}
+		if err := m.resolve(ctx, abort, restart); err != nil {
+			return err
+		}
+	}
+
+	if now, _ := m.git("rev-parse", "HEAD"); now != head {
+		// The agent committed despite the prompt; keep its result but
+		// give it the bead's message.
+		if out, err := m.git("reset", "--soft", head); err != nil {
+			abort()
+			return fmt.Errorf("squash %s into %s: %s: %w", m.sourceBranch, m.targetBranch, out, err)
+		}
+	}
+	if _, err := m.git("diff", "--cached", "--quiet"); err == nil {
+		return nil // the branch adds nothing to the target
+	}
+	if out, err := m.git("commit", "--no-verify", "-m", message); err != nil {
+		abort()
+		return fmt.Errorf("committing squash of %s: %s: %w", m.sourceBranch, out, err)
+	}
+	return nil
+}
+
+// squashMessage is the commit message for a squashed bead branch: the bead
+// title, then the subjects of the commits it replaces.
+func (m *mergeRequest) squashMessage() (string, error) {
+	subjects, err := m.git("log", "--reverse", "--format=* %s", "HEAD.."+m.sourceBranch)
+	if err != nil {
+		return "", fmt.Errorf("listing commits of %s: %s: %w", m.sourceBranch, subjects, err)
+	}
+	title := m.beadTitle
+	if title == "" {
+		title = "Squash " + m.sourceBranch
+	}
+	message := title + "\n\n"
+	if m.beadID != "" {
+		message += "Bead " + m.beadID + ", squashed from " + m.sourceBranch + ".\n"
+	} else {
+		message += "Squashed from " + m.sourceBranch + ".\n"
+	}
+	if subjects != "" {
+		message += "\n" + subjects + "\n"
+	}
+	return message, nil
+}
+
+// rebase cherry-picks the branch's commits since it forked from the target
+// onto the target, one at a time, with an agent resolving each commit that
+// conflicts.
+func (m *mergeRequest) rebase(ctx context.Context) error {
+	count, err := m.git("rev-list", "--count", "--no-merges", "HEAD.."+m.sourceBranch)
+	if err != nil {
+		return fmt.Errorf("listing commits of %s: %s: %w", m.sourceBranch, count, err)
+	}
+	if count == "0" {
+		return nil
+	}
+	head, err := m.git("rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("resolving %s: %s: %w", m.targetBranch, head, err)
+	}
+	m.head, m.skipped = head, 0
+	m.picks, _ = strconv.Atoi(count)
+	abort := func() {
+		_, _ = m.git("cherry-pick", "--abort")
+		_, _ = m.git("reset", "--hard", head)
+	}
+	restart := func() {
+		abort()
+		m.skipped = 0
+		_, _ = m.git("cherry-pick", "--no-merges", head+".."+m.sourceBranch)
+	}
+
+	out, err := m.git("cherry-pick", "--no-merges", "HEAD.."+m.sourceBranch)
+	for err != nil {
+		if !m.inProgress("CHERRY_PICK_HEAD") {
+			return fmt.Errorf("rebase %s onto %s: %s: %w", m.sourceBranch, m.targetBranch, out, err)
+		}
+		if hasMergeConflicts(m.repoPath) {
+			if rerr := m.resolve(ctx, abort, restart); rerr != nil {
+				return rerr
+			}
+			if !m.inProgress("CHERRY_PICK_HEAD") && !m.inProgress("sequencer") {
+				return nil // the agent finished the cherry-pick itself
+			}
+			out, err = m.git("-c", "core.editor=true", "cherry-pick", "--continue")
+			continue
+		}
+		// Stopped without conflicts: the commit is empty on the target,
+		// which already has its change.
+		m.skipped++
+		out, err = m.git("cherry-pick", "--skip")
+	}
+	return nil
+}
+
+// landingFailure checks, after an agent's resolution, that the strategy's
+// operation is still under way or has landed the source branch, rather
+// than been aborted or cut short. It returns what is wrong, or "".
+func (m *mergeRequest) landingFailure() string {
+	switch m.strategy {
+	case MergeStrategySquash:
+		if _, err := m.git("diff", "--cached", "--quiet", m.head); err != nil {
+			return "" // the squashed changes are staged (or committed)
+		}
+		if _, err := m.git("diff", "--quiet", m.head+"..."+m.sourceBranch); err == nil {
+			return "" // the branch adds nothing
+		}
+		return fmt.Sprintf("Nothing from %s is staged any more: the squash merge was undone. Resolve the conflicts instead of resetting.", m.sourceBranch)
+	case MergeStrategyRebase:
+		if m.inProgress("CHERRY_PICK_HEAD") || m.inProgress("sequencer") {
+			return ""
+		}
+		picked, _ := m.git("rev-list", "--count", m.head+"..HEAD")
+		if n, err := strconv.Atoi(picked); err == nil && n+m.skipped >= m.picks {
+			return ""
+		}
+		return fmt.Sprintf("The cherry-pick of %s's %d commit(s) stopped before all of them were applied (%s are). Resolve the conflicts instead of aborting or skipping commits.", m.sourceBranch, m.picks, picked)
+	default:
+		if m.inProgress("MERGE_HEAD") {
+			return ""
+		}
+		if _, err := m.git("merge-base", "--is-ancestor", m.sourceBranch, "HEAD"); err == nil {
+			return ""
+		}
+		return fmt.Sprintf("The merge is no longer in progress and %s is not merged into HEAD: it was aborted. Resolve the conflicts instead of aborting.", m.sourceBranch)
+	}
+}
+
+// inProgress reports whether the request's repository has the given
+// pseudo-ref, e.g. MERGE_HEAD while a merge is in progress.
+func (m *mergeRequest) inProgress(ref string) bool {
+	path, err := m.git("rev-parse", "--git-path", ref)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.repoPath, path)
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// checkoutForMerge makes sure targetBranch is checked out in repoPath.
+func checkoutForMerge(repoPath, targetBranch string) error {
+	current, err := getCurrentBranch(repoPath)
+	if err != nil {
+		return fmt.Errorf("getting current branch: %w", err)
+	}
+	if current == targetBranch {
+		return nil
+	}
+	out, err := exec.Command("git", "-C", repoPath, "checkout", targetBranch).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("checkout %s: %s: %w", targetBranch, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
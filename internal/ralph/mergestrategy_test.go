@@ -0,0 +1,175 @@
+package ralph
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitOutput runs git in dir and returns its trimmed stdout.
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// shellAgent makes the conflict resolution agent a shell script run in
+// the repository, with the agent CLI's arguments (the prompt last) as its
+// positional parameters.
+func shellAgent(script string) []Option {
+	return []Option{
+		WithStdoutWriter(io.Discard),
+		WithCommandFactory(func(ctx context.Context, workDir string, args ...string) *exec.Cmd {
+			cmd := exec.CommandContext(ctx, "sh", append([]string{"-c", script, "sh"}, args...)...)
+			cmd.Dir = workDir
+			return cmd
+		}),
+	}
+}
+
+// setupStrategyRepo returns a repository whose current branch and
+// ralph/b-1 both changed file since they forked: the same file when
+// conflict is set, different ones otherwise.
+func setupStrategyRepo(t *testing.T, conflict bool) (repo, target string) {
+	t.Helper()
+	repo = setupTestGitRepo(t)
+	target, err := getCurrentBranch(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetFile, branchFile := "a.txt", "b.txt"
+	if conflict {
+		createBranch(t, repo, target, "shared.txt", "base\n")
+		targetFile, branchFile = "shared.txt", "shared.txt"
+	}
+	createBranch(t, repo, "ralph/b-1", branchFile, "branch\n")
+	createBranch(t, repo, target, targetFile, "target\n")
+	return repo, target
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	for in, want := range map[string]MergeStrategy{"": MergeStrategyMerge, "squash": MergeStrategySquash, "ff-only": MergeStrategyFFOnly} {
+		if got, err := ParseMergeStrategy(in); err != nil || got != want {
+			t.Errorf("ParseMergeStrategy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMergeStrategy("octopus"); err == nil {
+		t.Error("ParseMergeStrategy(octopus): want error")
+	}
+}
+
+func TestMergeWithAgentResolution_Strategies(t *testing.T) {
+	tests := []struct {
+		strategy    MergeStrategy
+		wantParents int    // of the target's new HEAD
+		wantSubject string // of the target's new HEAD
+	}{
+		{MergeStrategyMerge, 2, "Merge branch 'ralph/b-1'"},
+		{MergeStrategySquash, 1, "Add the b file"},
+		{MergeStrategyRebase, 1, "add b.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			repo, target := setupStrategyRepo(t, false)
+			oldHead := gitOutput(t, repo, "rev-parse", "HEAD")
+			branchHead := gitOutput(t, repo, "rev-parse", "ralph/b-1")
+
+			err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "b-1", "Add the b file", tt.strategy, ConflictResolution{}, 0, nil, shellAgent("exit 1")...)
+			if err != nil {
+				t.Fatalf("MergeWithAgentResolution: %v", err)
+			}
+			for _, f := range []string{"a.txt", "b.txt"} {
+				if _, err := os.Stat(filepath.Join(repo, f)); err != nil {
+					t.Errorf("%s missing after the merge", f)
+				}
+			}
+			parents := strings.Fields(gitOutput(t, repo, "rev-list", "--parents", "-n", "1", "HEAD"))[1:]
+			if len(parents) != tt.wantParents || parents[0] != oldHead {
+				t.Errorf("HEAD parents = %v, want %d starting with the old target %s", parents, tt.wantParents, oldHead)
+			}
+			if got := gitOutput(t, repo, "log", "-1", "--format=%s"); !strings.HasPrefix(got, tt.wantSubject) {
+				t.Errorf("HEAD subject = %q, want %q", got, tt.wantSubject)
+			}
+			if got := gitOutput(t, repo, "rev-parse", "ralph/b-1"); got != branchHead {
+				t.Error("the bead branch was moved")
+			}
+		})
+	}
+}
+
+func TestMergeWithAgentResolution_SquashMessage(t *testing.T) {
+	repo, target := setupStrategyRepo(t, false)
+	if err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "b-1", "Add the b file", MergeStrategySquash, ConflictResolution{}, 0, nil); err != nil {
+		t.Fatalf("MergeWithAgentResolution: %v", err)
+	}
+	want := "Add the b file\n\nBead b-1, squashed from ralph/b-1.\n\n* add b.txt"
+	if got := gitOutput(t, repo, "log", "-1", "--format=%B"); got != want {
+		t.Errorf("squash message = %q, want %q", got, want)
+	}
+}
+
+func TestMergeWithAgentResolution_FFOnly(t *testing.T) {
+	repo, target := setupStrategyRepo(t, false)
+	oldHead := gitOutput(t, repo, "rev-parse", "HEAD")
+	err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "", MergeStrategyFFOnly, ConflictResolution{}, 0, nil)
+	if err == nil {
+		t.Fatal("ff-only merge of a diverged branch: want error")
+	}
+	if got := gitOutput(t, repo, "rev-parse", "HEAD"); got != oldHead {
+		t.Errorf("HEAD moved to %s after a failed fast-forward", got)
+	}
+
+	createBranch(t, repo, "ralph/b-2", "c.txt", "c\n")
+	if err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-2", "", "", MergeStrategyFFOnly, ConflictResolution{}, 0, nil); err != nil {
+		t.Fatalf("ff-only merge of a branch ahead of the target: %v", err)
+	}
+	if got, want := gitOutput(t, repo, "rev-parse", "HEAD"), gitOutput(t, repo, "rev-parse", "ralph/b-2"); got != want {
+		t.Errorf("HEAD = %s, want the fast-forwarded %s", got, want)
+	}
+}
+
+func TestMergeWithAgentResolution_Conflicts(t *testing.T) {
+	const resolve = "printf 'resolved\\n' > shared.txt && git add shared.txt"
+	for _, strategy := range []MergeStrategy{MergeStrategyMerge, MergeStrategySquash, MergeStrategyRebase} {
+		t.Run(string(strategy)+"/resolved", func(t *testing.T) {
+			repo, target := setupStrategyRepo(t, true)
+			err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "Share", strategy, ConflictResolution{}, 0, nil, shellAgent(resolve)...)
+			if err != nil {
+				t.Fatalf("MergeWithAgentResolution: %v", err)
+			}
+			if got, _ := os.ReadFile(filepath.Join(repo, "shared.txt")); string(got) != "resolved\n" {
+				t.Errorf("shared.txt = %q, want the agent's resolution", got)
+			}
+			if status := gitOutput(t, repo, "status", "--porcelain"); status != "" {
+				t.Errorf("repository not clean after the merge:\n%s", status)
+			}
+			if strategy != MergeStrategyMerge {
+				if n := len(strings.Fields(gitOutput(t, repo, "rev-list", "--parents", "-n", "1", "HEAD"))); n != 2 {
+					t.Errorf("%s left a merge commit", strategy)
+				}
+			}
+		})
+
+		t.Run(string(strategy)+"/unresolved", func(t *testing.T) {
+			repo, target := setupStrategyRepo(t, true)
+			oldHead := gitOutput(t, repo, "rev-parse", "HEAD")
+			err := MergeWithAgentResolution(context.Background(), repo, target, "ralph/b-1", "", "", strategy, ConflictResolution{}, 0, nil, shellAgent("true")...)
+			if err == nil {
+				t.Fatal("conflict the agent left alone: want error")
+			}
+			if got := gitOutput(t, repo, "rev-parse", "HEAD"); got != oldHead {
+				t.Errorf("HEAD moved to %s after a failed merge", got)
+			}
+			if status := gitOutput(t, repo, "status", "--porcelain"); status != "" {
+				t.Errorf("failed merge not aborted:\n%s", status)
+			}
+		})
+	}
+}
@@ -4,8 +4,22 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 )
 
+// syncWriter serializes writes so concurrent agents don't interleave
+// partial lines or race on the underlying writer.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
 // writef writes formatted output, ignoring errors.
 // Use for non-critical output where write failures are acceptable.
 func writef(w io.Writer, format string, args ...interface{}) {
@@ -0,0 +1,401 @@
+package ralph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"devdeploy/internal/bd"
+	"devdeploy/internal/beads"
+)
+
+// PlanBead is one bead of an execution plan.
+type PlanBead struct {
+	ID        string
+	Title     string
+	Status    string
+	Priority  int
+	CreatedAt time.Time
+	IssueType string
+	// BlockedBy lists the open beads that must close before this one is
+	// ready: its "blocks" dependencies and, for a nested epic, its children.
+	BlockedBy []string
+}
+
+// NeverReadyBead is a bead the run will never reach.
+type NeverReadyBead struct {
+	ID     string
+	Reason string
+}
+
+// Plan is the expected execution of an epic, computed from bd without
+// launching any agents. Waves assume every bead takes the same time; the
+// real scheduler refills a slot as soon as any agent finishes.
+type Plan struct {
+	RootBead    string
+	MaxParallel int
+	Beads       map[string]*PlanBead
+	Done        []string   // already closed
+	Waves       [][]string // bead IDs started together, in pick order
+	Cycles      [][]string // dependency cycles among open beads
+	NeverReady  []NeverReadyBead
+}
+
+// bdPlanEntry mirrors the fields of `bd list --json` the planner needs.
+type bdPlanEntry struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Status       string    `json:"status"`
+	Priority     int       `json:"priority"`
+	CreatedAt    time.Time `json:"created_at"`
+	IssueType    string    `json:"issue_type"`
+	Dependencies []struct {
+		IssueID     string `json:"issue_id"`
+		DependsOnID string `json:"depends_on_id"`
+		Type        string `json:"type"`
+	} `json:"dependencies"`
+}
+
+// BuildPlan reads rootBead's children (recursing into nested epics) and
+// their dependencies through bd and computes the execution plan for
+// maxParallel agents. runBD is the command runner (pass nil for real bd).
+func BuildPlan(runBD BDRunner, workDir, rootBead string, maxParallel int) (*Plan, error) {
+	if runBD == nil {
+		runBD = bd.Run
+	}
+
+	var entries []bdPlanEntry
+	seen := map[string]bool{rootBead: true}
+	queue := []string{rootBead}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		out, err := runBD(workDir, "list", "--parent", parent, "--json", "--limit", "0")
+		if err != nil {
+			return nil, fmt.Errorf("bd list --parent %s: %w", parent, err)
+		}
+		var children []bdPlanEntry
+		if err := json.Unmarshal(out, &children); err != nil {
+			return nil, fmt.Errorf("parsing bd list output: %w", err)
+		}
+		for _, e := range children {
+			if seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			entries = append(entries, e)
+			if e.IssueType == "epic" {
+				queue = append(queue, e.ID)
+			}
+		}
+	}
+
+	planBeads := make(map[string]*PlanBead, len(entries))
+	for _, e := range entries {
+		planBeads[e.ID] = &PlanBead{
+			ID:        e.ID,
+			Title:     e.Title,
+			Status:    e.Status,
+			Priority:  e.Priority,
+			CreatedAt: e.CreatedAt,
+			IssueType: e.IssueType,
+		}
+	}
+
+	// Blockers outside the epic only matter while they are open.
+	external := make(map[string]string)
+	addEdge := func(bead, blocker string) {
+		if blocker == rootBead {
+			return
+		}
+		if _, ok := planBeads[blocker]; !ok {
+			if _, looked := external[blocker]; !looked {
+				external[blocker] = fetchBeadStatus(runBD, workDir, blocker)
+			}
+			if external[blocker] == beads.StatusClosed {
+				return
+			}
+		}
+		planBeads[bead].BlockedBy = append(planBeads[bead].BlockedBy, blocker)
+	}
+	for _, e := range entries {
+		for _, d := range e.Dependencies {
+			if d.IssueID != "" && d.IssueID != e.ID {
+				continue
+			}
+			switch d.Type {
+			case beads.DepTypeBlocks:
+				addEdge(e.ID, d.DependsOnID)
+			case beads.DepTypeParentChild:
+				// A nested epic is finished by its children.
+				if _, ok := planBeads[d.DependsOnID]; ok {
+					addEdge(d.DependsOnID, e.ID)
+				}
+			}
+		}
+	}
+
+	return computePlan(rootBead, planBeads, external, maxParallel), nil
+}
+
+// fetchBeadStatus returns a bead's status from `bd show`, or "" if unknown.
+func fetchBeadStatus(runBD BDRunner, workDir, id string) string {
+	out, err := runBD(workDir, "show", id, "--json")
+	if err != nil {
+		return ""
+	}
+	var entries []struct {
+		Status string `json:"status"`
+	}
+	if json.Unmarshal(out, &entries) != nil || len(entries) == 0 {
+		return ""
+	}
+	return entries[0].Status
+}
+
+// computePlan simulates the scheduler over the dependency graph: each wave
+// starts up to maxParallel ready beads (in picker order) and completes them
+// before the next. external maps blockers outside the epic to their status.
+func computePlan(rootBead string, planBeads map[string]*PlanBead, external map[string]string, maxParallel int) *Plan {
+	p := &Plan{RootBead: rootBead, MaxParallel: maxParallel, Beads: planBeads}
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+
+	done := make(map[string]bool)
+	var open []*PlanBead
+	for _, b := range planBeads {
+		if b.Status == beads.StatusClosed {
+			done[b.ID] = true
+			p.Done = append(p.Done, b.ID)
+		} else {
+			open = append(open, b)
+		}
+	}
+	sort.Strings(p.Done)
+	sort.Slice(open, func(i, j int) bool {
+		a, b := open[i], open[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	for {
+		var wave []string
+		for _, b := range open {
+			if len(wave) == maxParallel {
+				break
+			}
+			if done[b.ID] {
+				continue
+			}
+			ready := true
+			for _, dep := range b.BlockedBy {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, b.ID)
+			}
+		}
+		if len(wave) == 0 {
+			break
+		}
+		for _, id := range wave {
+			done[id] = true
+		}
+		p.Waves = append(p.Waves, wave)
+	}
+
+	// Whatever is left is stuck: in a cycle, or waiting on something that is.
+	var stuck []string
+	for _, b := range open {
+		if !done[b.ID] {
+			stuck = append(stuck, b.ID)
+		}
+	}
+	sort.Strings(stuck)
+	p.Cycles = findCycles(stuck, planBeads)
+	inCycle := make(map[string]bool)
+	for _, cycle := range p.Cycles {
+		for _, id := range cycle {
+			inCycle[id] = true
+		}
+	}
+	for _, id := range stuck {
+		p.NeverReady = append(p.NeverReady, NeverReadyBead{ID: id, Reason: neverReadyReason(id, planBeads, external, done, inCycle)})
+	}
+	return p
+}
+
+// neverReadyReason explains why a stuck bead never becomes ready.
+func neverReadyReason(id string, planBeads map[string]*PlanBead, external map[string]string, done, inCycle map[string]bool) string {
+	if inCycle[id] {
+		return "dependency cycle"
+	}
+	for _, dep := range planBeads[id].BlockedBy {
+		if done[dep] {
+			continue
+		}
+		if status, ok := external[dep]; ok {
+			if status == "" {
+				status = "unknown"
+			}
+			return fmt.Sprintf("blocked by %s outside the epic (%s)", dep, status)
+		}
+		if inCycle[dep] {
+			return fmt.Sprintf("blocked by %s (in a cycle)", dep)
+		}
+		return fmt.Sprintf("blocked by %s (never ready)", dep)
+	}
+	return "unknown"
+}
+
+// findCycles returns the dependency cycles (strongly connected components
+// with more than one bead, or a bead blocking itself) among ids.
+func findCycles(ids []string, planBeads map[string]*PlanBead) [][]string {
+	member := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		member[id] = true
+	}
+
+	// Tarjan's algorithm.
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		low[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+		selfLoop := false
+		for _, dep := range planBeads[id].BlockedBy {
+			if !member[dep] {
+				continue
+			}
+			if dep == id {
+				selfLoop = true
+			}
+			if _, ok := index[dep]; !ok {
+				visit(dep)
+				low[id] = min(low[id], low[dep])
+			} else if onStack[dep] {
+				low[id] = min(low[id], index[dep])
+			}
+		}
+		if low[id] != index[id] {
+			return
+		}
+		var scc []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == id {
+				break
+			}
+		}
+		if len(scc) > 1 || selfLoop {
+			sort.Strings(scc)
+			cycles = append(cycles, scc)
+		}
+	}
+	for _, id := range ids {
+		if _, ok := index[id]; !ok {
+			visit(id)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// WriteText renders the plan for a terminal.
+func (p *Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+	open := len(p.Beads) - len(p.Done)
+	fmt.Fprintf(&b, "Plan for %s: %d open beads, %d closed, max parallel %d\n", p.RootBead, open, len(p.Done), max(p.MaxParallel, 1))
+	for i, wave := range p.Waves {
+		fmt.Fprintf(&b, "\nWave %d:\n", i+1)
+		for _, id := range wave {
+			bead := p.Beads[id]
+			fmt.Fprintf(&b, "  %s  %s", id, bead.Title)
+			if len(bead.BlockedBy) > 0 {
+				fmt.Fprintf(&b, "  (after %s)", strings.Join(bead.BlockedBy, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(p.Cycles) > 0 {
+		b.WriteString("\nCycles:\n")
+		for _, cycle := range p.Cycles {
+			fmt.Fprintf(&b, "  %s\n", strings.Join(cycle, " <-> "))
+		}
+	}
+	if len(p.NeverReady) > 0 {
+		b.WriteString("\nNever ready:\n")
+		for _, n := range p.NeverReady {
+			fmt.Fprintf(&b, "  %s  %s  (%s)\n", n.ID, p.Beads[n.ID].Title, n.Reason)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteDOT renders the plan as a Graphviz digraph: one rank per wave,
+// edges from blocker to blocked bead, stuck beads highlighted.
+func (p *Plan) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", p.RootBead)
+	b.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+
+	inCycle := make(map[string]bool)
+	for _, cycle := range p.Cycles {
+		for _, id := range cycle {
+			inCycle[id] = true
+		}
+	}
+	ids := make([]string, 0, len(p.Beads))
+	for id := range p.Beads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for i, wave := range p.Waves {
+		fmt.Fprintf(&b, "  subgraph \"wave%d\" {\n    rank=same;\n", i+1)
+		for _, id := range wave {
+			fmt.Fprintf(&b, "    %q [label=%q];\n", id, fmt.Sprintf("%s\n%s\nwave %d", id, p.Beads[id].Title, i+1))
+		}
+		b.WriteString("  }\n")
+	}
+	for _, id := range p.Done {
+		fmt.Fprintf(&b, "  %q [label=%q, style=dashed];\n", id, id+"\n"+p.Beads[id].Title)
+	}
+	for _, n := range p.NeverReady {
+		color := "gray"
+		if inCycle[n.ID] {
+			color = "red"
+		}
+		fmt.Fprintf(&b, "  %q [label=%q, color=%s];\n", n.ID, n.ID+"\n"+p.Beads[n.ID].Title, color)
+	}
+	for _, id := range ids {
+		for _, dep := range p.Beads[id].BlockedBy {
+			fmt.Fprintf(&b, "  %q -> %q;\n", dep, id)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
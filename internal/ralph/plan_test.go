@@ -0,0 +1,201 @@
+package ralph
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planBD serves `bd list --parent` and `bd show` from canned JSON.
+func planBD(lists map[string]string, shows map[string]string) BDRunner {
+	return func(dir string, args ...string) ([]byte, error) {
+		switch {
+		case len(args) >= 3 && args[0] == "list" && args[1] == "--parent":
+			if out, ok := lists[args[2]]; ok {
+				return []byte(out), nil
+			}
+			return []byte("[]"), nil
+		case len(args) >= 2 && args[0] == "show":
+			if out, ok := shows[args[1]]; ok {
+				return []byte(out), nil
+			}
+		}
+		return nil, fmt.Errorf("unexpected bd %v", args)
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	lists := map[string]string{
+		"epic": `[
+			{"id":"a","title":"A","status":"open","priority":1},
+			{"id":"b","title":"B","status":"open","priority":1},
+			{"id":"c","title":"C","status":"open","priority":2,
+			 "dependencies":[{"issue_id":"c","depends_on_id":"epic","type":"parent-child"},{"issue_id":"c","depends_on_id":"a","type":"blocks"}]},
+			{"id":"d","title":"D","status":"open","priority":2,
+			 "dependencies":[{"issue_id":"d","depends_on_id":"done","type":"blocks"},{"issue_id":"d","depends_on_id":"ext-closed","type":"blocks"}]},
+			{"id":"done","title":"Done","status":"closed"},
+			{"id":"x","title":"X","status":"open","dependencies":[{"issue_id":"x","depends_on_id":"y","type":"blocks"}]},
+			{"id":"y","title":"Y","status":"open","dependencies":[{"issue_id":"y","depends_on_id":"x","type":"blocks"}]},
+			{"id":"z","title":"Z","status":"open","dependencies":[{"issue_id":"z","depends_on_id":"x","type":"blocks"}]},
+			{"id":"w","title":"W","status":"open","dependencies":[{"issue_id":"w","depends_on_id":"ext-open","type":"blocks"}]},
+			{"id":"sub","title":"Sub","status":"open","priority":3,"issue_type":"epic"}
+		]`,
+		"sub": `[{"id":"s1","title":"S1","status":"open","priority":0,
+			"dependencies":[{"issue_id":"s1","depends_on_id":"sub","type":"parent-child"}]}]`,
+	}
+	shows := map[string]string{
+		"ext-closed": `[{"id":"ext-closed","status":"closed"}]`,
+		"ext-open":   `[{"id":"ext-open","status":"open"}]`,
+	}
+
+	plan, err := BuildPlan(planBD(lists, shows), "/repo", "epic", 2)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+
+	wantWaves := [][]string{{"s1", "a"}, {"b", "c"}, {"d", "sub"}}
+	if !reflect.DeepEqual(plan.Waves, wantWaves) {
+		t.Errorf("waves = %v, want %v", plan.Waves, wantWaves)
+	}
+	if !reflect.DeepEqual(plan.Done, []string{"done"}) {
+		t.Errorf("done = %v", plan.Done)
+	}
+	if !reflect.DeepEqual(plan.Cycles, [][]string{{"x", "y"}}) {
+		t.Errorf("cycles = %v, want [[x y]]", plan.Cycles)
+	}
+	wantStuck := []NeverReadyBead{
+		{"w", "blocked by ext-open outside the epic (open)"},
+		{"x", "dependency cycle"},
+		{"y", "dependency cycle"},
+		{"z", "blocked by x (in a cycle)"},
+	}
+	if !reflect.DeepEqual(plan.NeverReady, wantStuck) {
+		t.Errorf("never ready = %v, want %v", plan.NeverReady, wantStuck)
+	}
+
+	var text bytes.Buffer
+	if err := plan.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Wave 3:", "c  C  (after a)", "x <-> y", "w  W  (blocked by ext-open"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text plan missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var dot bytes.Buffer
+	if err := plan.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`digraph "epic" {`, `"a" -> "c";`, `"s1" -> "sub";`, `"x" [label="x\nX", color=red];`} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("dot plan missing %q:\n%s", want, dot.String())
+		}
+	}
+}
+
+func TestBuildPlan_Sequential(t *testing.T) {
+	lists := map[string]string{"epic": `[
+		{"id":"a","status":"open","created_at":"2026-01-01T00:00:00Z"},
+		{"id":"b","status":"open","created_at":"2026-01-02T00:00:00Z"}
+	]`}
+	plan, err := BuildPlan(planBD(lists, nil), "/repo", "epic", 0)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if want := [][]string{{"a"}, {"b"}}; !reflect.DeepEqual(plan.Waves, want) {
+		t.Errorf("waves = %v, want %v", plan.Waves, want)
+	}
+}
+
+func TestBuildPlan_BlockedAndEpicOrdering(t *testing.T) {
+	lists := map[string]string{
+		"epic": `[
+			{"id":"hi","status":"open","priority":0,"dependencies":[{"issue_id":"hi","depends_on_id":"lo","type":"blocks"}]},
+			{"id":"lo","status":"open","priority":3},
+			{"id":"mid","status":"open","priority":1},
+			{"id":"sub","status":"open","priority":0,"issue_type":"epic"},
+			{"id":"late","status":"open","priority":0,"dependencies":[{"issue_id":"late","depends_on_id":"sub","type":"blocks"}]}
+		]`,
+		"sub": `[
+			{"id":"s1","status":"open","priority":2,"dependencies":[
+				{"issue_id":"s1","depends_on_id":"sub","type":"parent-child"},
+				{"issue_id":"s1","depends_on_id":"mid","type":"blocks"}]},
+			{"id":"inner","status":"open","priority":0,"issue_type":"epic",
+			 "dependencies":[{"issue_id":"inner","depends_on_id":"sub","type":"parent-child"}]}
+		]`,
+		"inner": `[{"id":"i1","status":"open","priority":4,
+			"dependencies":[{"issue_id":"i1","depends_on_id":"inner","type":"parent-child"}]}]`,
+	}
+
+	plan, err := BuildPlan(planBD(lists, nil), "/repo", "epic", 2)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	// A blocked bead waits for its blocker whatever its priority, and an
+	// epic, nested or not, for all its children.
+	want := [][]string{{"mid", "lo"}, {"hi", "s1"}, {"i1"}, {"inner"}, {"sub"}, {"late"}}
+	if !reflect.DeepEqual(plan.Waves, want) {
+		t.Errorf("waves = %v, want %v", plan.Waves, want)
+	}
+	if len(plan.Cycles) != 0 || len(plan.NeverReady) != 0 {
+		t.Errorf("cycles = %v, never ready = %v; want none", plan.Cycles, plan.NeverReady)
+	}
+}
+
+func TestBuildPlan_NeverReady(t *testing.T) {
+	lists := map[string]string{
+		"epic": `[
+			{"id":"self","status":"open","dependencies":[{"issue_id":"self","depends_on_id":"self","type":"blocks"}]},
+			{"id":"own","status":"open","issue_type":"epic"},
+			{"id":"gone","status":"open","dependencies":[{"issue_id":"gone","depends_on_id":"ext-missing","type":"blocks"}]},
+			{"id":"after","status":"open","dependencies":[{"issue_id":"after","depends_on_id":"gone","type":"blocks"}]},
+			{"id":"tail","status":"open","dependencies":[{"issue_id":"tail","depends_on_id":"self","type":"blocks"}]}
+		]`,
+		// A child blocked by its own epic, which waits for the child.
+		"own": `[{"id":"o1","status":"open","dependencies":[
+			{"issue_id":"o1","depends_on_id":"own","type":"parent-child"},
+			{"issue_id":"o1","depends_on_id":"own","type":"blocks"}]}]`,
+	}
+
+	plan, err := BuildPlan(planBD(lists, nil), "/repo", "epic", 4)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Waves) != 0 {
+		t.Errorf("waves = %v, want none", plan.Waves)
+	}
+	if want := [][]string{{"o1", "own"}, {"self"}}; !reflect.DeepEqual(plan.Cycles, want) {
+		t.Errorf("cycles = %v, want %v", plan.Cycles, want)
+	}
+	wantStuck := []NeverReadyBead{
+		{"after", "blocked by gone (never ready)"},
+		{"gone", "blocked by ext-missing outside the epic (unknown)"},
+		{"o1", "dependency cycle"},
+		{"own", "dependency cycle"},
+		{"self", "dependency cycle"},
+		{"tail", "blocked by self (in a cycle)"},
+	}
+	if !reflect.DeepEqual(plan.NeverReady, wantStuck) {
+		t.Errorf("never ready = %v, want %v", plan.NeverReady, wantStuck)
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	blocked := map[string][]string{
+		"a": {"b"}, "b": {"c"}, "c": {"a"}, // a 3-cycle
+		"d": {"a"},             // waits on the cycle but is not in it
+		"e": {"f"}, "f": {"e"}, // a cycle through f, which is not considered
+		"g": nil,
+	}
+	planBeads := make(map[string]*PlanBead)
+	for id, deps := range blocked {
+		planBeads[id] = &PlanBead{ID: id, BlockedBy: deps}
+	}
+	got := findCycles([]string{"d", "c", "b", "a", "e", "g"}, planBeads)
+	if want := [][]string{{"a", "b", "c"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findCycles = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"devdeploy/internal/bd"
+	"devdeploy/internal/beads"
+)
+
+// DefaultPushRemote is the remote bead branches are pushed to in pull
+// request mode.
+const DefaultPushRemote = "origin"
+
+// GHRunner executes gh commands in dir and returns their stdout.
+type GHRunner func(dir string, args ...string) ([]byte, error)
+
+// runGH is the real GHRunner. Errors carry gh's stderr.
+func runGH(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("gh", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return out, err
+}
+
+// PullRequest is the pull request a bead's branch was opened as.
+type PullRequest struct {
+	Number int
+	URL    string
+}
+
+// prURLPattern finds the number in the URL `gh pr create` prints.
+var prURLPattern = regexp.MustCompile(`/pull/(\d+)\s*$`)
+
+// parsePullRequest extracts the pull request from `gh pr create` output,
+// whose last line is its URL.
+func parsePullRequest(out []byte) (*PullRequest, error) {
+	url := strings.TrimSpace(string(out))
+	if i := strings.LastIndexByte(url, '\n'); i >= 0 {
+		url = strings.TrimSpace(url[i+1:])
+	}
+	m := prURLPattern.FindStringSubmatch(url)
+	if m == nil {
+		return nil, fmt.Errorf("no pull request URL in gh output %q", url)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("pull request number in %q: %w", url, err)
+	}
+	return &PullRequest{Number: n, URL: url}, nil
+}
+
+// prBodyTemplate renders a bead's pull request description.
+var prBodyTemplate = template.Must(template.New("pr").Parse(prBodyTemplateText))
+
+const prBodyTemplateText = `{{.Description}}
+{{- if .Notes}}
+
+## Notes
+
+{{.Notes}}
+{{- end}}
+
+---
+
+Bead ` + "`{{.ID}}`" + `, worked on by ralph.
+`
+
+// RenderPRBody renders the pull request description for a bead.
+func RenderPRBody(data *PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := prBodyTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering pull request template: %w", err)
+	}
+	return strings.TrimLeft(buf.String(), "\n"), nil
+}
+
+// openPullRequest pushes a successful bead's branch and opens it as a pull
+// request against the run's branch, then labels the bead pr:<n> and
+// removes its worktree. It runs on the merge queue in place of
+// mergeAndCleanup.
+func (c *Core) openPullRequest(ctx context.Context, wtMgr *WorktreeManager, r beadExecResult, out io.Writer) (*PullRequest, error) {
+	defer cleanupWorktree(wtMgr, r, out)
+	remote := c.PushRemote
+	if remote == "" {
+		remote = DefaultPushRemote
+	}
+	if err := checkPullRequestBase(ctx, wtMgr.SrcRepo(), remote, wtMgr.Branch()); err != nil {
+		writef(out, "[%s] ERROR: %v\n", r.BeadID, err)
+		return nil, err
+	}
+
+	// A re-run bead's branch starts over, so it replaces what was pushed.
+	writef(out, "[%s] pushing %s to %s\n", r.BeadID, r.BranchName, remote)
+	push := exec.CommandContext(ctx, "git", "-C", wtMgr.SrcRepo(), "push", "--force-with-lease", "--set-upstream", remote, r.BranchName)
+	if msg, err := push.CombinedOutput(); err != nil {
+		err = fmt.Errorf("pushing %s: %w: %s", r.BranchName, err, strings.TrimSpace(string(msg)))
+		writef(out, "[%s] ERROR: %v\n", r.BeadID, err)
+		return nil, err
+	}
+
+	fetchPrompt := c.FetchPrompt
+	if fetchPrompt == nil {
+		fetchPrompt = FetchPromptData
+	}
+	data, err := fetchPrompt(c.RunBD, c.WorkDir, r.BeadID)
+	if err == nil {
+		var body string
+		if body, err = RenderPRBody(data); err == nil {
+			return c.createPullRequest(wtMgr, r, data.Title, body, out)
+		}
+	}
+	writef(out, "[%s] ERROR: %v\n", r.BeadID, err)
+	return nil, err
+}
+
+// checkPullRequestBase makes sure the run's branch, which pull requests are
+// opened against, is on remote as it is locally. Only bead branches are
+// pushed: a base missing from the remote makes gh fail, and local commits
+// the remote lacks would show up in every pull request's diff.
+func checkPullRequestBase(ctx context.Context, repo, remote, base string) error {
+	_ = exec.CommandContext(ctx, "git", "-C", repo, "fetch", "--quiet", remote, base).Run() // best effort; checked below
+	remoteRef := "refs/remotes/" + remote + "/" + base
+	if exec.CommandContext(ctx, "git", "-C", repo, "rev-parse", "--verify", "--quiet", remoteRef).Run() != nil {
+		return fmt.Errorf("pull request base %s is not on %s: push it first", base, remote)
+	}
+	out, err := exec.CommandContext(ctx, "git", "-C", repo, "rev-list", "--count", remoteRef+".."+base).Output()
+	if err != nil {
+		return fmt.Errorf("comparing %s with %s/%s: %w", base, remote, base, err)
+	}
+	if n := strings.TrimSpace(string(out)); n != "0" {
+		return fmt.Errorf("pull request base %s is %s commit(s) ahead of %s/%s: push it first", base, n, remote, base)
+	}
+	return nil
+}
+
+// createPullRequest runs `gh pr create` for a pushed bead branch and labels
+// the bead with the new pull request.
+func (c *Core) createPullRequest(wtMgr *WorktreeManager, r beadExecResult, title, body string, out io.Writer) (*PullRequest, error) {
+	if title == "" {
+		title = r.BeadID
+	}
+
+	gh := c.RunGH
+	if gh == nil {
+		gh = runGH
+	}
+	ghOut, err := gh(wtMgr.SrcRepo(), "pr", "create",
+		"--base", wtMgr.Branch(),
+		"--head", r.BranchName,
+		"--title", title,
+		"--body", body,
+	)
+	if err != nil {
+		err = fmt.Errorf("gh pr create: %w", err)
+		writef(out, "[%s] ERROR: %v\n", r.BeadID, err)
+		return nil, err
+	}
+	pr, err := parsePullRequest(ghOut)
+	if err != nil {
+		writef(out, "[%s] ERROR: %v\n", r.BeadID, err)
+		return nil, err
+	}
+	writef(out, "[%s] ✓ opened pull request #%d: %s\n", r.BeadID, pr.Number, pr.URL)
+
+	runner := c.RunBD
+	if runner == nil {
+		runner = bd.Run
+	}
+	label := fmt.Sprintf("%s%d", beads.LabelPRPrefix, pr.Number)
+	if _, err := runner(c.WorkDir, "update", r.BeadID, "--add-label", label); err != nil {
+		writef(out, "[%s] warning: labeling bead %s: %v\n", r.BeadID, label, err)
+	}
+	return pr, nil
+}
@@ -0,0 +1,171 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePullRequest(t *testing.T) {
+	pr, err := parsePullRequest([]byte("Creating pull request for ralph/x into main\n\nhttps://github.com/acme/app/pull/42\n"))
+	if err != nil || pr.Number != 42 || pr.URL != "https://github.com/acme/app/pull/42" {
+		t.Errorf("parsePullRequest = %+v, %v", pr, err)
+	}
+	if _, err := parsePullRequest([]byte("a pull request for branch ralph/x already exists")); err == nil {
+		t.Error("output without a URL: want error")
+	}
+}
+
+// addTestRemote adds an empty bare repository as repo's origin and returns
+// its path.
+func addTestRemote(t *testing.T, repo string) string {
+	t.Helper()
+	remote := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--bare", remote},
+		{"-C", repo, "remote", "add", "origin", remote},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	return remote
+}
+
+func TestCore_Run_PullRequests(t *testing.T) {
+	id := fmt.Sprintf("pr%d-1", time.Now().UnixNano())
+	bd := &recordingBD{fakeReadyBD: newFakeReadyBD([]string{id}, nil)}
+	c := newSchedulerTestCore(t, bd.fakeReadyBD)
+	c.RunBD = bd.run
+	c.MaxParallel = 1
+	c.PullRequests = true
+	c.FetchPrompt = func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+		return &PromptData{ID: beadID, Title: "Add widget", Description: "Widgets, please."}, nil
+	}
+	remote := addTestRemote(t, c.WorkDir)
+	branch, err := getCurrentBranch(c.WorkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, c.WorkDir, "push", "--quiet", "origin", branch)
+
+	var mu sync.Mutex
+	var ghCalls [][]string
+	c.RunGH = func(dir string, args ...string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ghCalls = append(ghCalls, args)
+		return []byte("https://github.com/acme/app/pull/42\n"), nil
+	}
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		return &AgentResult{}, commitFile(workDir, "widget.txt")
+	}
+	c.AssessFn = func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+	var report bytes.Buffer
+	c.Reporter = NewReporter(&report, ReportJSONL)
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Errorf("succeeded/failed = %d/%d, want 1/0\n%s", result.Succeeded, result.Failed, c.Output)
+	}
+
+	if err := exec.Command("git", "--git-dir", remote, "rev-parse", "--verify", "ralph/"+id).Run(); err != nil {
+		t.Errorf("bead branch was not pushed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(c.WorkDir, "widget.txt")); !os.IsNotExist(err) {
+		t.Error("bead was merged locally")
+	}
+
+	if len(ghCalls) != 1 {
+		t.Fatalf("gh calls = %v, want one pr create", ghCalls)
+	}
+	got := strings.Join(ghCalls[0], " ")
+	for _, want := range []string{
+		"pr create", "--base " + branch, "--head ralph/" + id, "--title Add widget",
+		"Widgets, please.", "Bead `" + id + "`",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("gh args %q missing %q", got, want)
+		}
+	}
+	if want := "update " + id + " --add-label pr:42"; len(bd.updates) != 1 || bd.updates[0] != want {
+		t.Errorf("bd updates = %v, want [%s]", bd.updates, want)
+	}
+
+	var line BeadReport
+	if err := json.Unmarshal(bytes.SplitN(report.Bytes(), []byte("\n"), 2)[0], &line); err != nil {
+		t.Fatalf("report: %v\n%s", err, report.String())
+	}
+	if line.Merge != MergeResultPullRequest || line.PR != "https://github.com/acme/app/pull/42" {
+		t.Errorf("report merge/pr = %q/%q, want %q and the PR URL", line.Merge, line.PR, MergeResultPullRequest)
+	}
+}
+
+func TestCore_Run_PullRequestsBaseNotOnRemote(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		setup   func(t *testing.T, repo, branch string)
+		wantErr string
+	}{
+		{"missing", func(t *testing.T, repo, branch string) {}, "is not on origin"},
+		{"behind", func(t *testing.T, repo, branch string) {
+			gitOutput(t, repo, "push", "--quiet", "origin", branch)
+			if err := commitFile(repo, "local.txt"); err != nil {
+				t.Fatal(err)
+			}
+		}, "1 commit(s) ahead of origin/"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			id := fmt.Sprintf("prbase%d-1", time.Now().UnixNano())
+			bd := newFakeReadyBD([]string{id}, nil)
+			c := newSchedulerTestCore(t, bd)
+			c.MaxParallel = 1
+			c.PullRequests = true
+			remote := addTestRemote(t, c.WorkDir)
+			branch, err := getCurrentBranch(c.WorkDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(t, c.WorkDir, branch)
+			c.RunGH = func(dir string, args ...string) ([]byte, error) {
+				t.Errorf("gh %v called without a base on the remote", args)
+				return nil, fmt.Errorf("unexpected gh call")
+			}
+			c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+				return &AgentResult{}, commitFile(workDir, "widget.txt")
+			}
+			c.AssessFn = func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+				bd.close(beadID)
+				return OutcomeSuccess, ""
+			}
+
+			result, err := c.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if result.Succeeded != 0 || result.Failed != 1 {
+				t.Errorf("succeeded/failed = %d/%d, want 0/1", result.Succeeded, result.Failed)
+			}
+			if out := fmt.Sprint(c.Output); !strings.Contains(out, tt.wantErr) {
+				t.Errorf("output does not say %q:\n%s", tt.wantErr, out)
+			}
+			if err := exec.Command("git", "--git-dir", remote, "rev-parse", "--verify", "ralph/"+id).Run(); err == nil {
+				t.Error("bead branch was pushed")
+			}
+		})
+	}
+}
@@ -4,28 +4,89 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os/exec"
+	"strings"
 	"text/template"
 
 	"devdeploy/internal/bd"
+	"devdeploy/internal/beads"
+)
+
+// Size limits for the context FetchPromptData gathers around a bead, so a
+// large epic does not crowd out the bead itself.
+const (
+	maxEpicDescriptionBytes = 4 * 1024
+	maxSiblings             = 30
+	maxDependencies         = 15
+	maxChangesBytes         = 2 * 1024
+	maxChangesCommits       = 20
 )
 
 // PromptData holds the variables injected into the agent prompt template.
 type PromptData struct {
-	ID          string // Bead ID (e.g. "devdeploy-bkp.3")
-	Title       string // Bead title
-	Description string // Full bead description (markdown)
+	ID                 string // Bead ID (e.g. "devdeploy-bkp.3")
+	Title              string // Bead title
+	Description        string // Full bead description (markdown)
+	AcceptanceCriteria string // Bead acceptance criteria
+	Design             string // Bead design notes
+	Notes              string // Bead notes, e.g. review comments from an earlier attempt
+
+	// Epic is the bead's parent epic, with its description truncated to
+	// maxEpicDescriptionBytes; nil for a bead without one.
+	Epic *RelatedBead
+	// Siblings are the epic's other children, at most maxSiblings of them;
+	// MoreSiblings counts the rest.
+	Siblings     []RelatedBead
+	MoreSiblings int
+	// Dependencies are the closed beads this one depends on, at most
+	// maxDependencies of them, with what each one's ralph branch changed.
+	Dependencies     []RelatedBead
+	MoreDependencies int
+
+	// PreviousAttempt is the failed attempt a retry follows; nil on a
+	// bead's first attempt in a run.
+	PreviousAttempt *FailedAttempt
+}
+
+// RelatedBead is a bead near the one being worked on.
+type RelatedBead struct {
+	ID          string
+	Title       string
+	Status      string
+	Description string // epic only
+	// Changes lists a dependency's commits and diffstat from its
+	// ralph/<id> branch, truncated to maxChangesBytes; empty when the
+	// branch is unknown, e.g. for beads closed outside ralph.
+	Changes string
 }
 
 // bdShowFull mirrors the JSON shape emitted by `bd show <id> --json`,
 // including the description field needed for prompt rendering.
 type bdShowFull struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	ID                 string          `json:"id"`
+	Title              string          `json:"title"`
+	Description        string          `json:"description"`
+	AcceptanceCriteria string          `json:"acceptance_criteria"`
+	Design             string          `json:"design"`
+	Notes              string          `json:"notes"`
+	Dependencies       []bdShowRelated `json:"dependencies"`
+}
+
+// bdShowRelated is a dependency in bd show --json output.
+type bdShowRelated struct {
+	ID             string `json:"id"`
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	Status         string `json:"status"`
+	DependencyType string `json:"dependency_type"`
 }
 
 // FetchPromptData runs `bd show <id> --json` and extracts the fields needed
-// for prompt rendering. runBD is the command runner (pass nil for real bd).
+// for prompt rendering, then gathers the bead's context: its parent epic
+// and siblings (`bd list --parent`) and the closed beads it depends on,
+// with their branches' changes from the git repository at workDir. The
+// context is best effort; only failing to show the bead is an error.
+// runBD is the command runner (pass nil for real bd).
 func FetchPromptData(runBD BDRunner, workDir string, beadID string) (*PromptData, error) {
 	if runBD == nil {
 		runBD = bd.Run
@@ -45,11 +106,111 @@ func FetchPromptData(runBD BDRunner, workDir string, beadID string) (*PromptData
 	}
 
 	e := entries[0]
-	return &PromptData{
-		ID:          e.ID,
-		Title:       e.Title,
-		Description: e.Description,
-	}, nil
+	data := &PromptData{
+		ID:                 e.ID,
+		Title:              e.Title,
+		Description:        e.Description,
+		AcceptanceCriteria: e.AcceptanceCriteria,
+		Design:             e.Design,
+		Notes:              e.Notes,
+	}
+	for _, dep := range e.Dependencies {
+		switch dep.DependencyType {
+		case beads.DepTypeParentChild:
+			if data.Epic == nil {
+				data.Epic = &RelatedBead{
+					ID:          dep.ID,
+					Title:       dep.Title,
+					Status:      dep.Status,
+					Description: truncateText(dep.Description, maxEpicDescriptionBytes),
+				}
+			}
+		case beads.DepTypeBlocks:
+			if dep.Status != beads.StatusClosed {
+				continue
+			}
+			if len(data.Dependencies) == maxDependencies {
+				data.MoreDependencies++
+				continue
+			}
+			data.Dependencies = append(data.Dependencies, RelatedBead{
+				ID:      dep.ID,
+				Title:   dep.Title,
+				Status:  dep.Status,
+				Changes: branchChanges(workDir, dep.ID),
+			})
+		}
+	}
+	if data.Epic != nil {
+		data.Siblings, data.MoreSiblings = fetchSiblings(runBD, workDir, data.Epic.ID, data.ID)
+	}
+	return data, nil
+}
+
+// fetchSiblings returns the epic's children other than beadID, up to
+// maxSiblings, and how many more there are. Errors yield no siblings.
+func fetchSiblings(runBD BDRunner, workDir, epicID, beadID string) ([]RelatedBead, int) {
+	out, err := runBD(workDir, "list", "--parent", epicID, "--json", "--limit", "0")
+	if err != nil {
+		return nil, 0
+	}
+	var children []bdShowRelated
+	if json.Unmarshal(out, &children) != nil {
+		return nil, 0
+	}
+	var siblings []RelatedBead
+	more := 0
+	for _, c := range children {
+		if c.ID == beadID {
+			continue
+		}
+		if len(siblings) == maxSiblings {
+			more++
+			continue
+		}
+		siblings = append(siblings, RelatedBead{ID: c.ID, Title: c.Title, Status: c.Status})
+	}
+	return siblings, more
+}
+
+// branchChanges summarizes what the ralph/<id> branch of a bead changed:
+// the subjects of its commits and a diffstat, measured from where the
+// branch was created (the oldest entry of its reflog). It returns "" when
+// the branch or its reflog is gone.
+func branchChanges(workDir, beadID string) string {
+	branch := "refs/heads/ralph/" + beadID
+	reflog, err := exec.Command("git", "-C", workDir, "reflog", "show", "--format=%H", branch, "--").Output()
+	if err != nil {
+		return ""
+	}
+	revs := strings.Fields(string(reflog))
+	if len(revs) == 0 {
+		return ""
+	}
+	base := revs[len(revs)-1]
+	commits, err := exec.Command("git", "-C", workDir, "log", "--no-merges",
+		"--format=%h %s", fmt.Sprintf("--max-count=%d", maxChangesCommits), base+".."+branch, "--").Output()
+	if err != nil || len(bytes.TrimSpace(commits)) == 0 {
+		return ""
+	}
+	changes := strings.TrimSpace(string(commits))
+	if stat, err := exec.Command("git", "-C", workDir, "diff", "--stat", base, branch, "--").Output(); err == nil {
+		changes += "\n\n" + strings.TrimRight(string(stat), "\n")
+	}
+	return truncateText(changes, maxChangesBytes)
+}
+
+// truncateText cuts s to at most max bytes, on a line boundary when there
+// is one, and says so.
+func truncateText(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := s[:max]
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "\n... (truncated)"
 }
 
 // promptTemplate is the Go text/template used to craft the agent prompt.
@@ -61,6 +222,109 @@ const promptTemplateText = `You are working on bead {{.ID}}.
 # {{.Title}}
 
 {{.Description}}
+{{- if .AcceptanceCriteria}}
+
+## Acceptance criteria
+
+{{.AcceptanceCriteria}}
+{{- end}}
+{{- if .Design}}
+
+## Design notes
+
+{{.Design}}
+{{- end}}
+{{- with .Epic}}
+
+## Epic {{.ID}}: {{.Title}}
+
+This bead is one part of the epic below. Stay within its scope; other
+beads cover the rest.
+{{- if .Description}}
+
+{{.Description}}
+{{- end}}
+{{- end}}
+{{- if .Siblings}}
+
+Other beads in the epic:
+{{range .Siblings}}
+- {{.ID}} [{{.Status}}] {{.Title}}
+{{- end}}
+{{- if .MoreSiblings}}
+- ... and {{.MoreSiblings}} more
+{{- end}}
+{{- end}}
+{{- if .Dependencies}}
+
+## Completed dependencies
+
+These beads had to close before this one. Build on their work rather than
+redoing it.
+{{- range .Dependencies}}
+
+### {{.ID}}: {{.Title}}
+{{- if .Changes}}
+
+` + "```" + `
+{{.Changes}}
+` + "```" + `
+{{- end}}
+{{- end}}
+{{- if .MoreDependencies}}
+
+... and {{.MoreDependencies}} more.
+{{- end}}
+{{- end}}
+{{- if .Notes}}
+
+## Notes from earlier attempts
+
+Address these before closing the bead:
+
+{{.Notes}}
+{{- end}}
+{{- with .PreviousAttempt}}
+
+## Previous attempt
+
+Attempt {{.Attempt}} at this bead ended in {{.Outcome}}. Find out what went
+wrong before trying again, and do not repeat the same approach blindly.
+{{- if .Error}}
+
+Error:
+
+` + "```" + `
+{{.Error}}
+` + "```" + `
+{{- end}}
+{{- if .Stderr}}
+
+End of the agent's stderr:
+
+` + "```" + `
+{{.Stderr}}
+` + "```" + `
+{{- end}}
+{{- if and .Diff .Discarded}}
+
+Changes it left behind, for reference only: they were discarded and your
+branch starts over from the target branch. Re-apply what was right and
+avoid what was not:
+
+` + "```diff" + `
+{{.Diff}}
+` + "```" + `
+{{- else if .Diff}}
+
+Changes it left behind (committed work is already on your branch; build on
+it or revert it):
+
+` + "```diff" + `
+{{.Diff}}
+` + "```" + `
+{{- end}}
+{{- end}}
 
 ---
 
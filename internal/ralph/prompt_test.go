@@ -2,6 +2,8 @@ package ralph
 
 import (
 	"encoding/json"
+	"fmt"
+	"os/exec"
 	"strings"
 	"testing"
 )
@@ -156,6 +158,83 @@ func TestFetchPromptData_Success(t *testing.T) {
 	}
 }
 
+func TestFetchPromptData_Context(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	createBranch(t, repo, "ralph/ctx-0", "groundwork.go", "package groundwork\n")
+	if out, err := exec.Command("git", "-C", repo, "merge", "ralph/ctx-0").CombinedOutput(); err != nil {
+		t.Fatalf("git merge: %v: %s", err, out)
+	}
+
+	var siblings []bdShowRelated
+	for i := 1; i <= maxSiblings+2; i++ {
+		siblings = append(siblings, bdShowRelated{ID: fmt.Sprintf("ctx-%d", i), Title: "Sibling", Status: "open"})
+	}
+	runner := func(dir string, args ...string) ([]byte, error) {
+		if args[0] == "list" {
+			if strings.Join(args, " ") != "list --parent ctx --json --limit 0" {
+				return nil, fmt.Errorf("unexpected bd %v", args)
+			}
+			return json.Marshal(siblings)
+		}
+		return json.Marshal([]bdShowFull{{
+			ID:                 "ctx-1",
+			Title:              "Build on it",
+			AcceptanceCriteria: "Tests pass.",
+			Design:             "Use the groundwork.",
+			Dependencies: []bdShowRelated{
+				{ID: "ctx", Title: "The epic", Description: strings.Repeat("epic line\n", maxEpicDescriptionBytes), Status: "open", DependencyType: "parent-child"},
+				{ID: "ctx-0", Title: "Lay groundwork", Status: "closed", DependencyType: "blocks"},
+				{ID: "ctx-9", Title: "Still open", Status: "open", DependencyType: "blocks"},
+			},
+		}})
+	}
+
+	got, err := FetchPromptData(runner, repo, "ctx-1")
+	if err != nil {
+		t.Fatalf("FetchPromptData: %v", err)
+	}
+	if got.AcceptanceCriteria != "Tests pass." || got.Design != "Use the groundwork." {
+		t.Errorf("acceptance criteria/design = %q/%q", got.AcceptanceCriteria, got.Design)
+	}
+	if got.Epic == nil || got.Epic.ID != "ctx" || got.Epic.Title != "The epic" {
+		t.Fatalf("Epic = %+v, want ctx", got.Epic)
+	}
+	if len(got.Epic.Description) > maxEpicDescriptionBytes+len("\n... (truncated)") || !strings.HasSuffix(got.Epic.Description, "(truncated)") {
+		t.Errorf("epic description not truncated: %d bytes", len(got.Epic.Description))
+	}
+	if len(got.Siblings) != maxSiblings || got.MoreSiblings != 1 || got.Siblings[0].ID != "ctx-2" {
+		t.Errorf("siblings = %d (first %q), more %d; want %d without ctx-1, 1 more",
+			len(got.Siblings), got.Siblings[0].ID, got.MoreSiblings, maxSiblings)
+	}
+	if len(got.Dependencies) != 1 || got.Dependencies[0].ID != "ctx-0" {
+		t.Fatalf("Dependencies = %+v, want only the closed ctx-0", got.Dependencies)
+	}
+	changes := got.Dependencies[0].Changes
+	for _, want := range []string{"add groundwork.go", "groundwork.go | 1 +"} {
+		if !strings.Contains(changes, want) {
+			t.Errorf("ctx-0 changes missing %q:\n%s", want, changes)
+		}
+	}
+
+	prompt, err := RenderPrompt(got)
+	if err != nil {
+		t.Fatalf("RenderPrompt: %v", err)
+	}
+	for _, want := range []string{
+		"## Acceptance criteria\n\nTests pass.",
+		"## Design notes\n\nUse the groundwork.",
+		"## Epic ctx: The epic",
+		"- ctx-2 [open] Sibling",
+		"- ... and 1 more",
+		"### ctx-0: Lay groundwork",
+		"add groundwork.go",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q", want)
+		}
+	}
+}
+
 func TestFetchPromptData_BDError(t *testing.T) {
 	runner := mockBDShowFull(nil) // returns error
 
@@ -187,6 +266,27 @@ func TestFetchPromptData_EmptyArray(t *testing.T) {
 	}
 }
 
+func TestRenderPrompt_PreviousAttemptDiff(t *testing.T) {
+	for _, tt := range []struct {
+		discarded     bool
+		want, notWant string
+	}{
+		{true, "they were discarded", "already on your branch"},
+		{false, "already on your branch", "discarded"},
+	} {
+		data := &PromptData{ID: "r-1", Title: "Retry", PreviousAttempt: &FailedAttempt{
+			Attempt: 1, Outcome: OutcomeFailure, Diff: "+half", Discarded: tt.discarded,
+		}}
+		got, err := RenderPrompt(data)
+		if err != nil {
+			t.Fatalf("RenderPrompt: %v", err)
+		}
+		if !strings.Contains(got, tt.want) || strings.Contains(got, tt.notWant) || !strings.Contains(got, "+half") {
+			t.Errorf("discarded=%v: want %q and the diff, not %q:\n%s", tt.discarded, tt.want, tt.notWant, got)
+		}
+	}
+}
+
 func TestRenderPrompt_IDAppearsMultipleTimes(t *testing.T) {
 	// The bead ID should appear in: header, claim, close, parent, and dep add instructions.
 	data := &PromptData{
@@ -0,0 +1,216 @@
+package ralph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"devdeploy/internal/agent"
+)
+
+// ReportFormat selects the machine-readable report encoding.
+type ReportFormat string
+
+const (
+	// ReportJSON writes one JSON document when the run ends.
+	ReportJSON ReportFormat = "json"
+	// ReportJSONL writes one line per finished bead as it happens, then a
+	// summary line.
+	ReportJSONL ReportFormat = "jsonl"
+)
+
+// ParseReportFormat validates a --report value.
+func ParseReportFormat(s string) (ReportFormat, error) {
+	switch f := ReportFormat(s); f {
+	case ReportJSON, ReportJSONL:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown report format %q (want json or jsonl)", s)
+	}
+}
+
+// Merge results in BeadReport.Merge.
+const (
+	MergeResultMerged      = "merged"
+	MergeResultPullRequest = "pull_request" // opened as BeadReport.PR
+	MergeResultFailed      = "failed"
+	MergeResultReverted    = "reverted" // merged, then reverted when verification failed
+)
+
+// BeadReport is one bead's entry in a run report.
+type BeadReport struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title,omitempty"`
+	Outcome    Outcome `json:"outcome"`
+	DurationMS int64   `json:"duration_ms"`
+	ChatID     string  `json:"chat_id,omitempty"`
+	ExitCode   int     `json:"exit_code"`
+	Error      string  `json:"error,omitempty"`
+	Branch     string  `json:"branch,omitempty"`
+	// Model is the model the agent ran on; Attempt is the bead's attempt
+	// in the run that settled it.
+	Model   string `json:"model,omitempty"`
+	Attempt int    `json:"attempt,omitempty"`
+	// Review is the reviewer's verdict, when a review ran.
+	Review ReviewVerdict `json:"review,omitempty"`
+	// Usage is the tokens and cost of the bead's agents, when reported.
+	Usage *agent.Usage `json:"usage,omitempty"`
+	// Transcript is the path prefix of the agent's transcript files.
+	Transcript string `json:"transcript,omitempty"`
+
+	// Merge is MergeResultMerged, MergeResultPullRequest,
+	// MergeResultFailed or MergeResultReverted, or empty when the bead had
+	// nothing to merge.
+	Merge      string `json:"merge,omitempty"`
+	MergeError string `json:"merge_error,omitempty"`
+	// Commit is the target branch HEAD after the bead was merged.
+	Commit string `json:"commit,omitempty"`
+	// PR is the URL of the bead's pull request, with Core.PullRequests.
+	PR string `json:"pr,omitempty"`
+}
+
+// SummaryReport is the final summary of a run report.
+type SummaryReport struct {
+	RunID      string      `json:"run_id,omitempty"`
+	RootBead   string      `json:"root_bead,omitempty"`
+	StopReason StopReason  `json:"stop_reason"`
+	ExitCode   int         `json:"exit_code"`
+	Iterations int         `json:"iterations"`
+	Succeeded  int         `json:"succeeded"`
+	Questions  int         `json:"questions"`
+	Failed     int         `json:"failed"`
+	TimedOut   int         `json:"timed_out"`
+	Skipped    int         `json:"skipped"`
+	DurationMS int64       `json:"duration_ms"`
+	Usage      agent.Usage `json:"usage"`
+}
+
+// Report is the document written in ReportJSON format.
+type Report struct {
+	Beads   []BeadReport  `json:"beads"`
+	Summary SummaryReport `json:"summary"`
+}
+
+// Reporter writes a machine-readable report of a Core run. Set it as
+// Core.Reporter; Core calls it from the scheduler goroutine only.
+type Reporter struct {
+	w      io.Writer
+	format ReportFormat
+	beads  []BeadReport
+	err    error
+}
+
+// NewReporter returns a reporter writing format to w.
+func NewReporter(w io.Writer, format ReportFormat) *Reporter {
+	return &Reporter{w: w, format: format}
+}
+
+// addBead records a finished bead, once its merge (if any) is settled.
+func (r *Reporter) addBead(b BeadReport) {
+	r.beads = append(r.beads, b)
+	if r.format == ReportJSONL {
+		r.writeLine(struct {
+			Type string `json:"type"`
+			BeadReport
+		}{"bead", b})
+	}
+}
+
+// finish writes the summary (and, for ReportJSON, the whole document). It
+// returns the first write error of the report.
+func (r *Reporter) finish(runID, rootBead string, s *RunSummary) error {
+	summary := SummaryReport{
+		RunID:      runID,
+		RootBead:   rootBead,
+		StopReason: s.StopReason,
+		ExitCode:   s.StopReason.ExitCode(),
+		Iterations: s.Iterations,
+		Succeeded:  s.Succeeded,
+		Questions:  s.Questions,
+		Failed:     s.Failed,
+		TimedOut:   s.TimedOut,
+		Skipped:    s.Skipped,
+		DurationMS: s.Duration.Milliseconds(),
+		Usage:      s.Usage,
+	}
+	switch r.format {
+	case ReportJSONL:
+		r.writeLine(struct {
+			Type string `json:"type"`
+			SummaryReport
+		}{"summary", summary})
+	default:
+		beads := r.beads
+		if beads == nil {
+			beads = []BeadReport{}
+		}
+		data, err := json.MarshalIndent(Report{Beads: beads, Summary: summary}, "", "  ")
+		if err != nil {
+			return err
+		}
+		r.write(append(data, '\n'))
+	}
+	return r.err
+}
+
+// writeLine writes v as a single JSON line.
+func (r *Reporter) writeLine(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.write(append(data, '\n'))
+}
+
+func (r *Reporter) write(data []byte) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = r.w.Write(data)
+}
+
+// newBeadReport builds a report entry from a bead's execution result.
+func newBeadReport(r beadExecResult) BeadReport {
+	d := r.Detail
+	duration := d.Duration
+	if duration == 0 {
+		duration = r.Duration
+	}
+	b := BeadReport{
+		ID:         r.BeadID,
+		Title:      d.Bead.Title,
+		Outcome:    r.Outcome,
+		DurationMS: duration.Round(time.Millisecond).Milliseconds(),
+		ChatID:     d.ChatID,
+		ExitCode:   d.ExitCode,
+		Error:      d.ErrorMessage,
+		Branch:     r.BranchName,
+		Model:      d.Model,
+		Attempt:    d.Attempt,
+		Transcript: d.Transcript,
+	}
+	if d.Review != nil {
+		b.Review = d.Review.Verdict
+	}
+	if d.Usage.Tokens() > 0 {
+		usage := d.Usage
+		b.Usage = &usage
+	}
+	return b
+}
+
+// setMerge records the result of the bead's merge or pull request.
+func (b *BeadReport) setMerge(m mergeDone) {
+	b.Merge, b.Commit = MergeResultMerged, m.Commit
+	if m.PR != nil {
+		b.Merge, b.PR = MergeResultPullRequest, m.PR.URL
+	}
+	if m.Err != nil {
+		b.Merge, b.MergeError = MergeResultFailed, m.Err.Error()
+	}
+	if m.Reverted {
+		b.Merge = MergeResultReverted
+	}
+}
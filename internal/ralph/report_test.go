@@ -0,0 +1,124 @@
+package ralph
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"devdeploy/internal/agent"
+)
+
+func TestParseReportFormat(t *testing.T) {
+	for _, s := range []string{"json", "jsonl"} {
+		if f, err := ParseReportFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseReportFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseReportFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestReporter_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewReporter(&buf, ReportJSON)
+	r.addBead(BeadReport{ID: "a", Outcome: OutcomeSuccess, Merge: MergeResultMerged, Commit: "abc", Usage: &agent.Usage{Model: "m", InputTokens: 10, CostUSD: 0.5}})
+	r.addBead(BeadReport{ID: "b", Outcome: OutcomeFailure, ExitCode: 1, Error: "boom"})
+	if buf.Len() != 0 {
+		t.Fatalf("json report written before the run ended: %s", buf.String())
+	}
+	summary := &RunSummary{Iterations: 2, Succeeded: 1, Failed: 1, StopReason: StopMaxIterations, Duration: 1500 * time.Millisecond, Usage: agent.Usage{InputTokens: 10, CostUSD: 0.5}}
+	if err := r.finish("run-1", "epic", summary); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid report: %v\n%s", err, buf.String())
+	}
+	if len(got.Beads) != 2 || got.Beads[0].Commit != "abc" || got.Beads[1].Error != "boom" || got.Beads[0].Usage.CostUSD != 0.5 || got.Beads[1].Usage != nil {
+		t.Errorf("beads = %+v", got.Beads)
+	}
+	s := got.Summary
+	if s.RunID != "run-1" || s.StopReason != StopMaxIterations || s.ExitCode != StopMaxIterations.ExitCode() || s.DurationMS != 1500 || s.Usage.CostUSD != 0.5 {
+		t.Errorf("summary = %+v", s)
+	}
+}
+
+func TestCore_Run_JSONLReport(t *testing.T) {
+	prefix := fmt.Sprintf("report%d", time.Now().UnixNano())
+	good, bad := prefix+"-good", prefix+"-bad"
+	bd := newFakeReadyBD([]string{good, bad}, nil)
+	c := newSchedulerTestCore(t, bd)
+	var buf bytes.Buffer
+	c.Reporter = NewReporter(&buf, ReportJSONL)
+
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		if beadIDFromWorkDir(workDir) == bad {
+			return &AgentResult{ExitCode: 2, ChatID: "chat-bad"}, nil
+		}
+		if err := os.WriteFile(filepath.Join(workDir, "good.txt"), []byte("done\n"), 0644); err != nil {
+			return nil, err
+		}
+		for _, args := range [][]string{{"add", "good.txt"}, {"commit", "-m", "good work"}} {
+			if out, err := exec.Command("git", append([]string{"-C", workDir}, args...)...).CombinedOutput(); err != nil {
+				return nil, fmt.Errorf("git %v: %v: %s", args, err, out)
+			}
+		}
+		return &AgentResult{ChatID: "chat-good"}, nil
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		if result.ExitCode != 0 {
+			return OutcomeFailure, "agent failed"
+		}
+		return OutcomeSuccess, ""
+	}
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	beads := make(map[string]map[string]any)
+	var summary map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid jsonl line %q: %v", scanner.Text(), err)
+		}
+		switch line["type"] {
+		case "bead":
+			beads[line["id"].(string)] = line
+		case "summary":
+			summary = line
+		default:
+			t.Errorf("unexpected line type: %s", scanner.Text())
+		}
+	}
+	if summary == nil || summary["succeeded"] != 1.0 || summary["failed"] != 1.0 || summary["stop_reason"] != StopNormal.String() {
+		t.Errorf("summary = %v", summary)
+	}
+
+	head, err := exec.Command("git", "-C", c.WorkDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := beads[good]
+	if g == nil || g["outcome"] != OutcomeSuccess.String() || g["chat_id"] != "chat-good" ||
+		g["merge"] != MergeResultMerged || g["commit"] != strings.TrimSpace(string(head)) {
+		t.Errorf("good bead = %v, want merged at %s", g, head)
+	}
+	b := beads[bad]
+	if b == nil || b["outcome"] != OutcomeFailure.String() || b["exit_code"] != 2.0 || b["merge"] != nil {
+		t.Errorf("bad bead = %v", b)
+	}
+}
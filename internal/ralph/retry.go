@@ -0,0 +1,147 @@
+package ralph
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"devdeploy/internal/bd"
+	"devdeploy/internal/beads"
+)
+
+// maxRetryBackoff caps the doubling wait between attempts at a bead.
+const maxRetryBackoff = time.Hour
+
+// retryStderrLines is how much of a failed attempt's stderr the retry's
+// prompt shows.
+const retryStderrLines = 40
+
+// maxRetryDiffBytes caps the diff of a failed attempt in the retry's prompt.
+const maxRetryDiffBytes = 50 * 1024
+
+// RetryRule is the retry policy after one outcome.
+type RetryRule struct {
+	// MaxAttempts caps a bead's attempts in a run, counting the first.
+	// 0 or 1 means the outcome is final.
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles for each
+	// further one, up to an hour.
+	Backoff time.Duration
+}
+
+// RetryPolicy says whether a bead that failed or timed out gets another
+// attempt in the same run, and when. Questions and successes are final.
+type RetryPolicy struct {
+	Failure RetryRule // after OutcomeFailure
+	Timeout RetryRule // after OutcomeTimeout
+}
+
+// rule returns the rule for outcome o; ok is false for final outcomes.
+func (p RetryPolicy) rule(o Outcome) (r RetryRule, ok bool) {
+	switch o {
+	case OutcomeFailure:
+		return p.Failure, true
+	case OutcomeTimeout:
+		return p.Timeout, true
+	}
+	return RetryRule{}, false
+}
+
+// backoff returns the wait before the attempt after attempt n.
+func (r RetryRule) backoff(n int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < n && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
+}
+
+// FailedAttempt is what a retry's prompt says about the attempt before it.
+type FailedAttempt struct {
+	Attempt int     // the failed attempt, 1 for the first
+	Outcome Outcome // OutcomeFailure or OutcomeTimeout
+	Error   string  // the assessment or error message
+	Stderr  string  // tail of the agent's stderr
+	Diff    string  // changes the attempt left in its tree, capped
+	// Discarded says the attempt ran in a worktree whose branch the retry
+	// starts over from the target branch, so Diff is gone from its tree.
+	// Otherwise the attempt's commits are still on the branch.
+	Discarded bool
+}
+
+// retries reports whether a failed bead can get another attempt at all:
+// the Core has a RetryPolicy or an EscalationModel.
+func (c *Core) retries() bool {
+	return c.Retry.Failure.MaxAttempts > 1 || c.Retry.Timeout.MaxAttempts > 1 || c.EscalationModel != ""
+}
+
+// retry reports whether attempt r at a bead is followed by another, and
+// after how long. Retry decides for its outcome; without a retry left
+// there, EscalationModel still gives a first attempt that did not run on
+// it one more. Cancelled attempts are not retried.
+func (c *Core) retry(r beadExecResult) (time.Duration, bool) {
+	rule, ok := c.Retry.rule(r.Outcome)
+	if !ok || r.Cancelled {
+		return 0, false
+	}
+	if r.Attempt < rule.MaxAttempts {
+		return rule.backoff(r.Attempt), true
+	}
+	if c.EscalationModel != "" && r.Attempt == 1 && r.Model != c.EscalationModel {
+		return 0, true
+	}
+	return 0, false
+}
+
+// failedAttempt collects what the retry of r needs to know: the error, the
+// tail of stderr and the diff the agent left behind. Call it before the
+// attempt's worktree is removed.
+func (c *Core) failedAttempt(r beadExecResult) *FailedAttempt {
+	dir := r.WorktreePath
+	if dir == "" {
+		dir = c.WorkDir
+	}
+	return &FailedAttempt{
+		Attempt: r.Attempt,
+		Outcome: r.Outcome,
+		Error:   strings.TrimSpace(r.Detail.ErrorMessage),
+		Stderr:  strings.TrimSpace(tailLines(r.Detail.Stderr, retryStderrLines)),
+		Diff:    attemptDiff(dir, r.BaseRev),
+		// CreateWorktree recreates the bead's branch for the retry.
+		Discarded: r.WorktreePath != "",
+	}
+}
+
+// attemptDiff returns the changes in dir since baseRev, committed or not,
+// followed by the names of new untracked files. It returns "" when there
+// is no base or git fails.
+func attemptDiff(dir, baseRev string) string {
+	if baseRev == "" {
+		return ""
+	}
+	diff, err := exec.Command("git", "-C", dir, "diff", baseRev).Output()
+	if err != nil {
+		return ""
+	}
+	if untracked, err := exec.Command("git", "-C", dir, "ls-files", "--others", "--exclude-standard").Output(); err == nil && len(untracked) > 0 {
+		diff = append(diff, "\nUntracked files:\n"...)
+		diff = append(diff, untracked...)
+	}
+	if len(diff) > maxRetryDiffBytes {
+		diff = append(diff[:maxRetryDiffBytes], "\n... (diff truncated)"...)
+	}
+	return strings.TrimSpace(string(diff))
+}
+
+// markFailed labels a bead ralph gave up on, so later runs leave it alone.
+func (c *Core) markFailed(beadID string) error {
+	runner := c.RunBD
+	if runner == nil {
+		runner = bd.Run
+	}
+	if _, err := runner(c.WorkDir, "update", beadID, "--add-label", beads.LabelRalphFailed); err != nil {
+		return fmt.Errorf("bd update %s: %w", beadID, err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package ralph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"devdeploy/internal/beads"
+)
+
+func TestRetryRule_backoff(t *testing.T) {
+	r := RetryRule{MaxAttempts: 10, Backoff: time.Minute}
+	for n, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 9: maxRetryBackoff} {
+		if got := r.backoff(n); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestCore_retry(t *testing.T) {
+	policy := RetryPolicy{
+		Failure: RetryRule{MaxAttempts: 3, Backoff: time.Second},
+		Timeout: RetryRule{MaxAttempts: 2, Backoff: time.Minute},
+	}
+	tests := []struct {
+		name      string
+		core      Core
+		r         beadExecResult
+		wantDelay time.Duration
+		wantOK    bool
+	}{
+		{"no policy", Core{}, beadExecResult{Outcome: OutcomeFailure, Attempt: 1}, 0, false},
+		{"failure", Core{Retry: policy}, beadExecResult{Outcome: OutcomeFailure, Attempt: 2}, 2 * time.Second, true},
+		{"failures exhausted", Core{Retry: policy}, beadExecResult{Outcome: OutcomeFailure, Attempt: 3}, 0, false},
+		{"timeout", Core{Retry: policy}, beadExecResult{Outcome: OutcomeTimeout, Attempt: 1}, time.Minute, true},
+		{"timeouts exhausted", Core{Retry: policy}, beadExecResult{Outcome: OutcomeTimeout, Attempt: 2}, 0, false},
+		{"question", Core{Retry: policy}, beadExecResult{Outcome: OutcomeQuestion, Attempt: 1}, 0, false},
+		{"cancelled", Core{Retry: policy}, beadExecResult{Outcome: OutcomeFailure, Attempt: 1, Cancelled: true}, 0, false},
+		{"escalation", Core{EscalationModel: "opus"}, beadExecResult{Outcome: OutcomeTimeout, Attempt: 1}, 0, true},
+		{"already on the escalation model", Core{EscalationModel: "opus"}, beadExecResult{Outcome: OutcomeFailure, Attempt: 1, Model: "opus"}, 0, false},
+	}
+	for _, tt := range tests {
+		delay, ok := tt.core.retry(tt.r)
+		if delay != tt.wantDelay || ok != tt.wantOK {
+			t.Errorf("%s: retry = %v, %v; want %v, %v", tt.name, delay, ok, tt.wantDelay, tt.wantOK)
+		}
+	}
+}
+
+func TestCore_readyBeads_SkipsRalphFailed(t *testing.T) {
+	c := &Core{RunBD: func(dir string, args ...string) ([]byte, error) {
+		return json.Marshal([]bdReadyEntry{
+			{ID: "a", Status: "open"},
+			{ID: "b", Status: "open", Labels: []string{"backend", beads.LabelRalphFailed}},
+		})
+	}}
+	ready, err := c.readyBeads()
+	if err != nil {
+		t.Fatalf("readyBeads: %v", err)
+	}
+	if len(ready) != 1 || ready[0].ID != "a" {
+		t.Errorf("ready = %v, want only a", ready)
+	}
+}
+
+func TestCore_Run_RetryWithFailureContext(t *testing.T) {
+	prefix := fmt.Sprintf("retry%d", time.Now().UnixNano())
+	flaky, broken := prefix+"-flaky", prefix+"-broken"
+	bd := &recordingBD{fakeReadyBD: newFakeReadyBD([]string{flaky, broken}, nil)}
+	c := newSchedulerTestCore(t, bd.fakeReadyBD)
+	c.RunBD = bd.run
+	c.Render = RenderPrompt
+	c.Retry = RetryPolicy{Failure: RetryRule{MaxAttempts: 2, Backoff: 20 * time.Millisecond}}
+
+	var mu sync.Mutex
+	prompts := make(map[string][]string)
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		id := beadIDFromWorkDir(workDir)
+		mu.Lock()
+		prompts[id] = append(prompts[id], prompt)
+		attempt := len(prompts[id])
+		mu.Unlock()
+		if id == flaky && attempt == 1 {
+			// Committed and uncommitted work, then a crash.
+			if err := commitFile(workDir, "half.txt"); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(filepath.Join(workDir, "scratch.txt"), []byte("wip\n"), 0644); err != nil {
+				return nil, err
+			}
+			return &AgentResult{ExitCode: 1, Stderr: "panic: nil map"}, nil
+		}
+		if id == broken {
+			return &AgentResult{ExitCode: 1}, nil
+		}
+		return &AgentResult{}, nil
+	}
+	c.AssessFn = func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+		if r.ExitCode != 0 {
+			return OutcomeFailure, fmt.Sprintf("agent exited with code %d", r.ExitCode)
+		}
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Iterations != 4 || result.Succeeded != 1 || result.Failed != 1 {
+		t.Errorf("iterations/succeeded/failed = %d/%d/%d, want 4/1/1\n%s",
+			result.Iterations, result.Succeeded, result.Failed, c.Output)
+	}
+
+	if len(prompts[flaky]) != 2 {
+		t.Fatalf("%s ran %d times, want 2", flaky, len(prompts[flaky]))
+	}
+	if strings.Contains(prompts[flaky][0], "Previous attempt") {
+		t.Error("first attempt's prompt mentions a previous attempt")
+	}
+	retry := prompts[flaky][1]
+	for _, want := range []string{
+		"Attempt 1 at this bead ended in failure",
+		"agent exited with code 1",
+		"panic: nil map",
+		"+half.txt",
+		"scratch.txt",
+		"they were discarded",
+	} {
+		if !strings.Contains(retry, want) {
+			t.Errorf("retry prompt missing %q:\n%s", want, retry)
+		}
+	}
+	// The retry starts over from the target; the failed attempt only
+	// reaches it through the prompt.
+	if _, err := os.Stat(filepath.Join(c.WorkDir, "half.txt")); !os.IsNotExist(err) {
+		t.Error("the failed attempt's commit was merged through the retry")
+	}
+
+	want := "update " + broken + " --add-label " + beads.LabelRalphFailed
+	if len(bd.updates) != 1 || bd.updates[0] != want {
+		t.Errorf("bd updates = %v, want [%s]", bd.updates, want)
+	}
+}
+
+func TestCore_Run_RetryPendingWhenStopped(t *testing.T) {
+	observer := &attemptObserver{}
+	c := &Core{
+		WorkDir:       t.TempDir(),
+		MaxIterations: 1,
+		Retry:         RetryPolicy{Failure: RetryRule{MaxAttempts: 3, Backoff: time.Hour}},
+		Output:        &strings.Builder{},
+		Observer:      observer,
+		RunBD:         newFakeReadyBD([]string{"b"}, nil).run,
+		FetchPrompt: func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+			return &PromptData{ID: beadID}, nil
+		},
+		Render: func(data *PromptData) (string, error) { return data.ID, nil },
+		Execute: func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+			return &AgentResult{}, nil
+		},
+		AssessFn: func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+			return OutcomeFailure, "still open"
+		},
+	}
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Failed != 1 || result.StopReason != StopMaxIterations {
+		t.Errorf("failed = %d, stop reason %s; want the queued retry counted as a failure and %s",
+			result.Failed, result.StopReason, StopMaxIterations)
+	}
+}
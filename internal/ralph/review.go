@@ -0,0 +1,193 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"devdeploy/internal/agent"
+)
+
+// ReviewVerdict is the outcome of a reviewer pass.
+type ReviewVerdict string
+
+const (
+	// ReviewApproved lets the bead's work be merged.
+	ReviewApproved ReviewVerdict = "approved"
+	// ReviewChangesRequested reopens the bead with the review comments.
+	ReviewChangesRequested ReviewVerdict = "changes_requested"
+	// ReviewError means the reviewer failed or gave no verdict. The bead is
+	// not held back by a broken reviewer.
+	ReviewError ReviewVerdict = "error"
+)
+
+// maxReviewDiffBytes caps the diff embedded in the review prompt.
+const maxReviewDiffBytes = 200 * 1024
+
+// Review is the result of the reviewer pass on a bead.
+type Review struct {
+	Verdict  ReviewVerdict
+	Comments string // requested changes, or why the review failed
+	Model    string
+	ChatID   string
+	Usage    agent.Usage
+}
+
+// ReviewPromptData holds the variables injected into the review prompt.
+type ReviewPromptData struct {
+	ID          string
+	Title       string
+	Description string
+	Diff        string
+}
+
+var reviewPromptTemplate = template.Must(template.New("review").Parse(reviewPromptTemplateText))
+
+const reviewPromptTemplateText = `You are reviewing the work another agent did on bead {{.ID}}.
+
+# {{.Title}}
+
+{{.Description}}
+
+---
+
+## Changes
+
+` + "```diff" + `
+{{.Diff}}
+` + "```" + `
+
+## Your task
+
+Check that the changes fully and correctly implement the bead, follow the
+project conventions in ` + "`.cursor/rules/`" + ` and ` + "`AGENTS.md`" + `, and come with
+adequate tests. You may read the code in this directory, but do NOT modify
+files, commit, or run bd commands.
+
+Finish your reply with exactly one of these lines:
+
+VERDICT: APPROVE
+VERDICT: REQUEST_CHANGES
+
+When requesting changes, list each required change above the verdict line.
+They are passed verbatim to the next attempt.`
+
+// RenderReviewPrompt renders the reviewer prompt.
+func RenderReviewPrompt(data *ReviewPromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := reviewPromptTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering review prompt template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// parseReview finds the reviewer's final verdict line. Comments are the
+// text before it. ok is false when there is no verdict.
+func parseReview(text string) (verdict ReviewVerdict, comments string, ok bool) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.Trim(strings.TrimSpace(lines[i]), "*`")
+		switch line {
+		case "VERDICT: APPROVE":
+			verdict = ReviewApproved
+		case "VERDICT: REQUEST_CHANGES":
+			verdict = ReviewChangesRequested
+		default:
+			continue
+		}
+		return verdict, strings.TrimSpace(strings.Join(lines[:i], "\n")), true
+	}
+	return "", "", false
+}
+
+// reviewBead runs the reviewer agent over the changes workDir would land on
+// targetBranch: everything since the branch forked from it, including
+// commits of earlier attempts, so none merge unreviewed. Without a target
+// branch (the agent worked on it directly) that is the changes since
+// baseRev.
+func (c *Core) reviewBead(ctx context.Context, workDir, baseRev, targetBranch string, data *PromptData, out io.Writer) *Review {
+	review := &Review{Model: c.ReviewModel}
+	fail := func(format string, args ...any) *Review {
+		review.Verdict = ReviewError
+		review.Comments = fmt.Sprintf(format, args...)
+		return review
+	}
+
+	if targetBranch != "" {
+		forkPoint, err := exec.Command("git", "-C", workDir, "merge-base", targetBranch, "HEAD").Output()
+		if err != nil {
+			return fail("git merge-base %s HEAD: %v", targetBranch, err)
+		}
+		baseRev = strings.TrimSpace(string(forkPoint))
+	}
+
+	diff, err := exec.Command("git", "-C", workDir, "diff", baseRev).Output()
+	if err != nil {
+		return fail("git diff: %v", err)
+	}
+	if len(diff) > maxReviewDiffBytes {
+		diff = append(diff[:maxReviewDiffBytes], "\n... (diff truncated)"...)
+	}
+	prompt, err := RenderReviewPrompt(&ReviewPromptData{
+		ID:          data.ID,
+		Title:       data.Title,
+		Description: data.Description,
+		Diff:        string(diff),
+	})
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	writef(out, "[%s] reviewing\n", data.ID)
+	var result *AgentResult
+	if c.ReviewFn != nil {
+		result, err = c.ReviewFn(ctx, workDir, prompt)
+	} else {
+		// Replace the loop model; empty falls back to the opus default.
+		opts := append(c.agentOptions(), WithModel(c.ReviewModel))
+		opts = append(opts, c.transcriptOptions(data.ID, TranscriptReview)...)
+		result, err = RunAgentOpus(ctx, workDir, prompt, opts...)
+	}
+	if err != nil {
+		return fail("reviewer: %v", err)
+	}
+	review.ChatID = result.ChatID
+	review.Usage = result.Usage.Priced(c.Prices)
+	if result.TimedOut || result.ExitCode != 0 {
+		return fail("reviewer exited with code %d (timed out: %v)", result.ExitCode, result.TimedOut)
+	}
+	text := result.ResultText
+	if text == "" {
+		text = result.Stdout // backends without a result event print plain text
+	}
+	verdict, comments, ok := parseReview(text)
+	if !ok {
+		return fail("reviewer gave no verdict")
+	}
+	review.Verdict, review.Comments = verdict, comments
+	return review
+}
+
+// headRev returns the HEAD commit of the repository at dir, or "" if it
+// cannot be resolved.
+func headRev(dir string) string {
+	rev, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(rev))
+}
+
+// reviewNotes appends review comments to a bead's existing notes, so the
+// next attempt's prompt includes them.
+func reviewNotes(existing string, review *Review) string {
+	notes := "Review requested changes:\n\n" + review.Comments
+	if existing = strings.TrimSpace(existing); existing != "" {
+		notes = existing + "\n\n" + notes
+	}
+	return notes
+}
@@ -0,0 +1,160 @@
+package ralph
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseReview(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantVerdict  ReviewVerdict
+		wantComments string
+		wantOK       bool
+	}{
+		{"approve", "Looks good.\n\nVERDICT: APPROVE\n", ReviewApproved, "Looks good.", true},
+		{"changes", "- add a test\n- fix the typo\nVERDICT: REQUEST_CHANGES", ReviewChangesRequested, "- add a test\n- fix the typo", true},
+		{"markdown emphasis", "**VERDICT: APPROVE**", ReviewApproved, "", true},
+		{"last verdict wins", "VERDICT: APPROVE\non second thought\nVERDICT: REQUEST_CHANGES", ReviewChangesRequested, "VERDICT: APPROVE\non second thought", true},
+		{"no verdict", "I think it is fine", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, comments, ok := parseReview(tt.text)
+			if verdict != tt.wantVerdict || comments != tt.wantComments || ok != tt.wantOK {
+				t.Errorf("parseReview = %q, %q, %v; want %q, %q, %v", verdict, comments, ok, tt.wantVerdict, tt.wantComments, tt.wantOK)
+			}
+		})
+	}
+}
+
+// reviewObserver collects completed bead results.
+type reviewObserver struct {
+	NoopObserver
+	mu      sync.Mutex
+	results map[string]BeadResult
+}
+
+func (o *reviewObserver) OnBeadComplete(r BeadResult) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.results[r.Bead.ID] = r
+}
+
+func TestCore_Run_ReviewRequestsChanges(t *testing.T) {
+	prefix := fmt.Sprintf("review%d", time.Now().UnixNano())
+	good, sloppy := prefix+"-good", prefix+"-sloppy"
+	bd := &recordingBD{fakeReadyBD: newFakeReadyBD([]string{good, sloppy}, nil)}
+	c := newSchedulerTestCore(t, bd.fakeReadyBD)
+	c.RunBD = bd.run
+	c.Review = true
+	c.ReviewModel = "reviewer-model"
+	c.FetchPrompt = func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+		return &PromptData{ID: beadID, Title: beadID, Notes: "earlier note"}, nil
+	}
+	obs := &reviewObserver{results: make(map[string]BeadResult)}
+	c.Observer = obs
+
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		return &AgentResult{}, commitFile(workDir, beadIDFromWorkDir(workDir)+".txt")
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+	c.ReviewFn = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		id := beadIDFromWorkDir(workDir)
+		if !strings.Contains(prompt, "+++ b/"+id+".txt") {
+			return &AgentResult{ResultText: "diff missing\nVERDICT: REQUEST_CHANGES"}, nil
+		}
+		if id == sloppy {
+			return &AgentResult{ChatID: "rev-1", ResultText: "- add tests\nVERDICT: REQUEST_CHANGES"}, nil
+		}
+		return &AgentResult{ChatID: "rev-2", ResultText: "VERDICT: APPROVE"}, nil
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 1 {
+		t.Errorf("succeeded/failed = %d/%d, want 1/1\n%s", result.Succeeded, result.Failed, c.Output)
+	}
+	if _, err := os.Stat(filepath.Join(c.WorkDir, good+".txt")); err != nil {
+		t.Errorf("approved bead was not merged: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(c.WorkDir, sloppy+".txt")); !os.IsNotExist(err) {
+		t.Error("rejected bead was merged")
+	}
+
+	want := "update " + sloppy + " --status open --notes earlier note\n\nReview requested changes:\n\n- add tests"
+	if len(bd.updates) != 1 || bd.updates[0] != want {
+		t.Errorf("bd updates = %q, want [%q]", bd.updates, want)
+	}
+
+	if r := obs.results[good].Review; r == nil || r.Verdict != ReviewApproved || r.Model != "reviewer-model" || r.ChatID != "rev-2" {
+		t.Errorf("good review = %+v", r)
+	}
+	sr := obs.results[sloppy]
+	if sr.Outcome != OutcomeFailure || sr.Review == nil || sr.Review.Verdict != ReviewChangesRequested || sr.Review.Comments != "- add tests" {
+		t.Errorf("sloppy result = %+v, review %+v", sr, sr.Review)
+	}
+}
+
+func TestCore_reviewBead_WholeBranch(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	target, err := getCurrentBranch(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The branch holds a commit from an earlier attempt; this attempt adds
+	// another on top.
+	gitOutput(t, repo, "checkout", "-b", "ralph/x-1")
+	if err := commitFile(repo, "earlier.txt"); err != nil {
+		t.Fatal(err)
+	}
+	baseRev := gitOutput(t, repo, "rev-parse", "HEAD")
+	if err := commitFile(repo, "later.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	var prompt string
+	c := &Core{ReviewFn: func(ctx context.Context, workDir, p string) (*AgentResult, error) {
+		prompt = p
+		return &AgentResult{ResultText: "VERDICT: APPROVE"}, nil
+	}}
+	review := c.reviewBead(context.Background(), repo, baseRev, target, &PromptData{ID: "x-1"}, io.Discard)
+	if review.Verdict != ReviewApproved {
+		t.Fatalf("review = %+v", review)
+	}
+	for _, f := range []string{"earlier.txt", "later.txt"} {
+		if !strings.Contains(prompt, "+++ b/"+f) {
+			t.Errorf("review diff is missing %s:\n%s", f, prompt)
+		}
+	}
+}
+
+func TestRenderPrompt_Notes(t *testing.T) {
+	prompt, err := RenderPrompt(&PromptData{ID: "x-1", Title: "T", Description: "D", Notes: "Review requested changes:\n\n- add tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "## Notes from earlier attempts") || !strings.Contains(prompt, "- add tests") {
+		t.Errorf("prompt missing notes:\n%s", prompt)
+	}
+	plain, err := RenderPrompt(&PromptData{ID: "x-1", Title: "T", Description: "D"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(plain, "Notes from earlier attempts") {
+		t.Error("prompt without notes should not have a notes section")
+	}
+}
@@ -0,0 +1,513 @@
+package ralph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"devdeploy/internal/beads"
+)
+
+// pendingRetry is a failed bead waiting for its next attempt.
+type pendingRetry struct {
+	bead beads.Bead
+	at   time.Time // end of the backoff
+	prev *FailedAttempt
+	last beadExecResult // counted if the run stops before the retry
+}
+
+// mergeDone reports a finished merge-queue job.
+type mergeDone struct {
+	BeadID string
+	Commit string       // target branch HEAD after a successful merge
+	PR     *PullRequest // opened instead of merging, with Core.PullRequests
+	Err    error
+	// Reverted is set when the merge landed but was reverted because the
+	// target branch failed verification.
+	Reverted bool
+}
+
+// revertedMerge is the error of a merge that was reverted after it landed.
+type revertedMerge struct{ error }
+
+// schedule keeps up to MaxParallel agents busy until no work is left or a
+// limit stops the run, recording outcomes in result. All scheduler state is
+// owned by this goroutine; agents and the merge queue report back over
+// channels. parentCtx is the caller's context, used to tell cancellation
+// apart from the wall-clock limit on ctx. Beads in done already succeeded in
+// an earlier session of a resumed run and are never started.
+func (c *Core) schedule(ctx, parentCtx context.Context, wtMgr *WorktreeManager, done map[string]bool, result *RunSummary, out io.Writer) error {
+	slots := c.MaxParallel
+	if slots < 1 {
+		slots = 1
+	}
+	failLimit := c.consecutiveFailureLimit()
+
+	// Buffered so agents and merges never block if we return early. The
+	// control API may raise MaxParallel up to MaxControlParallel.
+	maxSlots := slots
+	var controlChanged <-chan struct{}
+	if c.Control != nil {
+		maxSlots = max(slots, MaxControlParallel)
+		controlChanged = c.Control.changed
+	}
+	beadDone := make(chan beadExecResult, maxSlots)
+	mergeDoneCh := make(chan mergeDone, 1)
+	paused := false
+
+	inFlight := make(map[string]bool)
+	// Report entries of beads waiting in the merge queue.
+	pendingReports := make(map[string]BeadReport)
+	failedBeads := make(map[string]bool)
+	skipped := make(map[string]bool)
+	attempts := make(map[string]int)
+	// Failed beads waiting for their next attempt. Once their backoff is
+	// over they start ahead of the ready beads, whether or not bd still
+	// lists them.
+	var retries []pendingRetry
+	var mergeQueue []beadExecResult
+	merging := false
+	// knownReady holds the beads that were ready while no merge was
+	// pending. A bead that only becomes ready while a merge is pending was
+	// most likely unblocked by it, so it waits until the queue drains
+	// rather than starting from a tree without its dependency's work.
+	knownReady := make(map[string]bool)
+	consecutiveFails := 0
+	needQuery := true
+	stopping := false
+	lastReady := 0
+
+	defer func() { result.Skipped = len(skipped) }()
+	// Retries the run stops before count as their last attempt's outcome.
+	defer func() {
+		for _, p := range retries {
+			c.settle(p.last, result)
+		}
+	}()
+
+	recordFailure := func(beadID string, failed bool) {
+		if !failed {
+			consecutiveFails = 0
+			return
+		}
+		failedBeads[beadID] = true
+		consecutiveFails++
+		if !stopping && ctx.Err() == nil && failLimit > 0 && consecutiveFails >= failLimit {
+			writef(out, "Stopping after %d consecutive failures\n", consecutiveFails)
+			result.StopReason = StopConsecutiveFails
+			stopping = true
+		}
+	}
+
+	for {
+		if !stopping && ctx.Err() != nil {
+			result.StopReason = contextStopReason(parentCtx)
+			stopping = true
+		}
+		if !stopping && c.MaxIterations > 0 && result.Iterations >= c.MaxIterations {
+			result.StopReason = StopMaxIterations
+			stopping = true
+		}
+		if c.Control != nil {
+			c.Control.update(result)
+			slots, paused = c.Control.settings()
+			if c.WorktreePool && wtMgr != nil {
+				wtMgr.SetPoolSize(slots)
+			}
+		}
+
+		// Refill free slots from a fresh `bd ready`.
+		queried := false
+		if !stopping && !paused && needQuery && len(inFlight) < slots {
+			needQuery = false
+			queried = true
+			ready, err := c.readyBeads()
+			if err != nil {
+				return fmt.Errorf("fetching ready beads: %w", err)
+			}
+			lastReady = len(ready)
+
+			pending := merging || len(mergeQueue) > 0
+			if !pending {
+				clear(knownReady)
+				for _, b := range ready {
+					knownReady[b.ID] = true
+				}
+			}
+			var candidates []beads.Bead
+			retrying := make(map[string]bool)
+			now := time.Now()
+			for _, p := range retries {
+				retrying[p.bead.ID] = true
+				if !p.at.After(now) {
+					candidates = append(candidates, p.bead)
+				}
+			}
+			for _, b := range ready {
+				switch {
+				case inFlight[b.ID], done[b.ID], c.Exclude[b.ID], retrying[b.ID]:
+				case failedBeads[b.ID]:
+					if !skipped[b.ID] {
+						skipped[b.ID] = true
+						writef(out, "[%s] skipping: already failed in this run\n", b.ID)
+					}
+				case pending && !knownReady[b.ID]:
+					// Wait for the merge queue to drain.
+				default:
+					candidates = append(candidates, b)
+				}
+			}
+
+			free := slots - len(inFlight)
+			if c.MaxIterations > 0 && free > c.MaxIterations-result.Iterations {
+				free = c.MaxIterations - result.Iterations
+			}
+			if len(candidates) > free {
+				candidates = candidates[:free]
+			}
+			if c.Slots != nil && len(candidates) > 0 {
+				n := c.Slots.tryAcquire(len(candidates))
+				if n == 0 && len(inFlight) == 0 && !merging {
+					// Other Cores hold every shared slot. Wait for one
+					// rather than ending the run with work left.
+					if err := c.Slots.acquire(ctx); err != nil {
+						continue // the loop then stops
+					}
+					n = 1
+				}
+				// Otherwise the rest waits until one of ours finishes.
+				candidates = candidates[:n]
+			}
+			if len(candidates) > 0 {
+				writef(out, "Found %d ready bead(s), starting %d (%d running)\n", len(ready), len(candidates), len(inFlight))
+			}
+			for _, b := range candidates {
+				var prev *FailedAttempt
+				if i := slices.IndexFunc(retries, func(p pendingRetry) bool { return p.bead.ID == b.ID }); i >= 0 {
+					prev = retries[i].prev
+					retries = slices.Delete(retries, i, i+1)
+				}
+				inFlight[b.ID] = true
+				attempts[b.ID]++
+				result.Iterations++
+				beadCtx, cancel := ctx, context.CancelFunc(func() {})
+				if c.Control != nil {
+					beadCtx, cancel = c.Control.beadStarted(ctx, b)
+				}
+				go func(b beads.Bead, iterNum, attempt int) {
+					defer cancel()
+					r := c.executeBead(beadCtx, wtMgr, &b, iterNum, attempt, prev, out)
+					if beadCtx.Err() != nil && ctx.Err() == nil {
+						writef(out, "[%s] cancelled through the control API\n", b.ID)
+						r.Cancelled = true
+					}
+					beadDone <- r
+				}(b, result.Iterations, attempts[b.ID])
+			}
+		}
+
+		// Start the next merge if the queue is idle.
+		if !merging && len(mergeQueue) > 0 {
+			r := mergeQueue[0]
+			mergeQueue = mergeQueue[1:]
+			merging = true
+			go func() {
+				mergeDoneCh <- c.land(ctx, wtMgr, r, out)
+			}()
+		}
+
+		if len(inFlight) == 0 && !merging {
+			if stopping {
+				return nil
+			}
+			if queried && len(retries) == 0 {
+				// Nothing running and nothing startable: the run is over.
+				if lastReady > 0 {
+					result.StopReason = StopAllBeadsSkipped
+				}
+				return nil
+			}
+			if !queried && !paused {
+				continue
+			}
+		}
+
+		// Paused or backing off with nothing running, only cancellation,
+		// a control change or the next retry can wake us.
+		var cancelled <-chan struct{}
+		if (paused || len(retries) > 0) && len(inFlight) == 0 && !merging {
+			cancelled = ctx.Done()
+		}
+		// Retries already due start when a slot frees up.
+		var retryDue <-chan time.Time
+		if !stopping && !paused {
+			var next time.Time
+			now := time.Now()
+			for _, p := range retries {
+				if p.at.After(now) && (next.IsZero() || p.at.Before(next)) {
+					next = p.at
+				}
+			}
+			if !next.IsZero() {
+				retryDue = time.After(time.Until(next))
+			}
+		}
+
+		select {
+		case <-controlChanged:
+			needQuery = true
+		case <-retryDue:
+			needQuery = true
+		case <-cancelled:
+		case r := <-beadDone:
+			delete(inFlight, r.BeadID)
+			needQuery = true
+			if c.Slots != nil {
+				c.Slots.release()
+			}
+			if c.Control != nil {
+				c.Control.beadDone(r.BeadID, r.Outcome)
+			}
+			outcome := r.Outcome
+			c.record(out, JournalEvent{Type: EventBeadDone, BeadID: r.BeadID, WorktreePath: r.WorktreePath, BranchName: r.BranchName, Outcome: &outcome})
+			result.Usage = result.Usage.Add(r.Detail.Usage)
+			if !stopping && c.Budget > 0 && result.Usage.CostUSD >= c.Budget {
+				writef(out, "Stopping: spent $%.2f of the $%.2f budget\n", result.Usage.CostUSD, c.Budget)
+				result.StopReason = StopBudget
+				stopping = true
+			}
+			if delay, ok := c.retry(r); ok && !stopping && ctx.Err() == nil {
+				// Neither counted nor reported: the retry's outcome is
+				// the bead's.
+				msg := "retrying"
+				if r.Attempt == 1 && c.EscalationModel != "" && r.Model != c.EscalationModel {
+					msg = "escalating to " + c.EscalationModel
+				}
+				if delay > 0 {
+					msg += " in " + FormatDuration(delay)
+				}
+				writef(out, "[%s] %s\n", r.BeadID, msg)
+				retries = append(retries, pendingRetry{
+					bead: r.Detail.Bead,
+					at:   time.Now().Add(delay),
+					prev: c.failedAttempt(r),
+					last: r,
+				})
+				cleanupWorktree(wtMgr, r, out)
+				continue
+			}
+			if r.Outcome == OutcomeSuccess && r.WorktreePath != "" && r.BranchName != "" {
+				// Counted for the failure limit and reported once the merge lands.
+				tallyOutcome(r, result)
+				mergeQueue = append(mergeQueue, r)
+				pendingReports[r.BeadID] = newBeadReport(r)
+				continue
+			}
+			if r.BranchName != "" && r.Outcome == OutcomeSuccess {
+				// Branch was created but worktree wasn't (shouldn't happen, but handle it)
+				writef(out, "[%s] WARNING: branch %s exists but no worktree was created - merge skipped\n", r.BeadID, r.BranchName)
+			}
+			failed := c.settle(r, result)
+			if failed && !r.Cancelled && c.retries() {
+				writef(out, "[%s] giving up after %d attempt(s), labeling it %s\n", r.BeadID, r.Attempt, beads.LabelRalphFailed)
+				if err := c.markFailed(r.BeadID); err != nil {
+					writef(out, "[%s] warning: %v\n", r.BeadID, err)
+				}
+			}
+			cleanupWorktree(wtMgr, r, out)
+			recordFailure(r.BeadID, failed)
+		case m := <-mergeDoneCh:
+			merging = false
+			needQuery = true
+			c.recordMerge(out, m)
+			if m.Err != nil {
+				// Don't fail the entire run, but make the error visible.
+				// The bead was counted as a success when it was queued;
+				// its work did not land after all.
+				result.Succeeded--
+				result.Failed++
+			}
+			if c.Reporter != nil {
+				report := pendingReports[m.BeadID]
+				report.setMerge(m)
+				c.Reporter.addBead(report)
+			}
+			delete(pendingReports, m.BeadID)
+			recordFailure(m.BeadID, m.Err != nil)
+		}
+	}
+}
+
+// settle counts and reports the final outcome of a bead that is not merged,
+// and reports whether it failed.
+func (c *Core) settle(r beadExecResult, result *RunSummary) bool {
+	if c.Reporter != nil {
+		c.Reporter.addBead(newBeadReport(r))
+	}
+	return tallyOutcome(r, result)
+}
+
+// tallyOutcome counts one bead's outcome in result and reports whether it
+// counts as a failure (failure or timeout).
+func tallyOutcome(r beadExecResult, result *RunSummary) bool {
+	switch r.Outcome {
+	case OutcomeSuccess:
+		result.Succeeded++
+	case OutcomeQuestion:
+		result.Questions++
+	case OutcomeFailure:
+		result.Failed++
+		return true
+	case OutcomeTimeout:
+		result.TimedOut++
+		return true
+	}
+	return false
+}
+
+// land hands a successful bead's branch over: it opens a pull request with
+// Core.PullRequests and merges it back otherwise.
+func (c *Core) land(ctx context.Context, wtMgr *WorktreeManager, r beadExecResult, out io.Writer) mergeDone {
+	if c.PullRequests {
+		pr, err := c.openPullRequest(ctx, wtMgr, r, out)
+		return mergeDone{BeadID: r.BeadID, PR: pr, Err: err}
+	}
+	commit, err := c.mergeAndCleanup(ctx, wtMgr, r, out)
+	return mergeDone{BeadID: r.BeadID, Commit: commit, Err: err, Reverted: errors.As(err, new(revertedMerge))}
+}
+
+// mergeAndCleanup merges a successful bead's branch back into the main
+// branch and removes its worktree. It runs on the merge queue, one at a
+// time, and returns the main branch's HEAD after the merge.
+func (c *Core) mergeAndCleanup(ctx context.Context, wtMgr *WorktreeManager, r beadExecResult, out io.Writer) (string, error) {
+	defer cleanupWorktree(wtMgr, r, out)
+	mergeRepo := wtMgr.MergeRepo(wtMgr.Branch())
+	base, err := exec.Command("git", "-C", mergeRepo, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("resolving %s before merge: %w", wtMgr.Branch(), err)
+	}
+	writef(out, "[%s] merging %s into %s\n", r.BeadID, r.BranchName, wtMgr.Branch())
+	if err := c.mergeBack(ctx, wtMgr, r); err != nil {
+		writef(out, "[%s] ERROR: merge failed: %v\n", r.BeadID, err)
+		return "", err
+	}
+	if c.VerifyCommand != "" {
+		writef(out, "[%s] verifying %s: %s\n", r.BeadID, wtMgr.Branch(), c.VerifyCommand)
+		if verr := c.verify(ctx, mergeRepo); verr != nil {
+			err := fmt.Errorf("post-merge verification failed: %w", verr)
+			if rerr := revertMerge(mergeRepo, strings.TrimSpace(string(base))); rerr != nil {
+				err = fmt.Errorf("%w; reverting merge: %v", err, rerr)
+			} else {
+				writef(out, "[%s] merge reverted\n", r.BeadID)
+				err = revertedMerge{err}
+			}
+			if rerr := c.reopenBead(r.BeadID, ""); rerr != nil {
+				writef(out, "[%s] warning: reopening bead: %v\n", r.BeadID, rerr)
+			}
+			writef(out, "[%s] ERROR: %v\n", r.BeadID, err)
+			return "", err
+		}
+	}
+	writef(out, "[%s] ✓ merged successfully\n", r.BeadID)
+	commit, err := exec.Command("git", "-C", mergeRepo, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", nil // merged; the SHA is informational only
+	}
+	return strings.TrimSpace(string(commit)), nil
+}
+
+// cleanupWorktree removes a bead's worktree, if it has one.
+func cleanupWorktree(wtMgr *WorktreeManager, r beadExecResult, out io.Writer) {
+	if r.WorktreePath == "" || wtMgr == nil {
+		return
+	}
+	if _, err := os.Stat(r.WorktreePath); os.IsNotExist(err) {
+		// Already gone (e.g. removed before a resume); just prune git's record.
+		_ = exec.Command("git", "-C", wtMgr.SrcRepo(), "worktree", "prune").Run()
+		return
+	}
+	if err := wtMgr.RemoveWorktree(r.WorktreePath); err != nil {
+		writef(out, "  warning: failed to remove worktree: %v\n", err)
+	}
+}
+
+// recoverRun brings the repo back in line with a resumed run's journal:
+// pending merges are finished and counted in result, worktrees left behind
+// by unfinished or already-handled beads are removed, and the beads that
+// succeeded and landed are returned so the scheduler skips them. A bead
+// whose merge failed is not skipped: its work is not on the target branch.
+func (c *Core) recoverRun(ctx context.Context, wtMgr *WorktreeManager, result *RunSummary, out io.Writer) map[string]bool {
+	state := c.Journal.State()
+	writef(out, "Resuming run %s\n", state.RunID)
+
+	done := make(map[string]bool)
+	for _, id := range state.Order {
+		r := state.Beads[id]
+		if r.Finished && r.Outcome == OutcomeSuccess && r.Merge != MergeFailed {
+			done[id] = true
+		}
+		if r.WorktreePath == "" {
+			continue
+		}
+		if wtMgr == nil {
+			var err error
+			if wtMgr, err = NewWorktreeManager(c.WorkDir); err != nil {
+				writef(out, "  warning: cannot recover worktrees: %v\n", err)
+				return done
+			}
+		}
+		br := beadExecResult{BeadID: id, Outcome: r.Outcome, WorktreePath: r.WorktreePath, BranchName: r.BranchName}
+		switch {
+		case r.Merge == MergePending:
+			writef(out, "[%s] finishing pending merge\n", id)
+			m := c.land(ctx, wtMgr, br, out)
+			c.recordMerge(out, m)
+			if m.Err != nil {
+				delete(done, id)
+				result.Failed++
+			} else {
+				result.Succeeded++
+			}
+			if c.Reporter != nil {
+				report := BeadReport{ID: id, Outcome: OutcomeSuccess, Branch: r.BranchName}
+				report.setMerge(m)
+				c.Reporter.addBead(report)
+			}
+		case !r.Finished:
+			writef(out, "[%s] removing abandoned worktree %s\n", id, r.WorktreePath)
+			cleanupWorktree(wtMgr, br, out)
+		default:
+			cleanupWorktree(wtMgr, br, out)
+		}
+	}
+	return done
+}
+
+// record appends an event to the journal, if any. Journal write failures
+// are reported but don't stop the run.
+func (c *Core) record(out io.Writer, e JournalEvent) {
+	if c.Journal == nil {
+		return
+	}
+	if err := c.Journal.Record(e); err != nil {
+		writef(out, "  warning: %v\n", err)
+	}
+}
+
+// recordMerge journals the result of a merge or pull request.
+func (c *Core) recordMerge(out io.Writer, m mergeDone) {
+	e := JournalEvent{Type: EventMergeDone, BeadID: m.BeadID, Commit: m.Commit}
+	if m.PR != nil {
+		e.PR = m.PR.URL
+	}
+	if m.Err != nil {
+		e.Error = m.Err.Error()
+	}
+	c.record(out, e)
+}
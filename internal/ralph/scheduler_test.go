@@ -0,0 +1,159 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeReadyBD serves "bd ready" from a fixed bead list: a bead is ready once
+// it is not closed and all its deps are closed.
+type fakeReadyBD struct {
+	mu     sync.Mutex
+	order  []string
+	deps   map[string][]string
+	closed map[string]bool
+}
+
+func newFakeReadyBD(order []string, deps map[string][]string) *fakeReadyBD {
+	return &fakeReadyBD{order: order, deps: deps, closed: make(map[string]bool)}
+}
+
+func (f *fakeReadyBD) run(dir string, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var entries []bdReadyEntry
+	for _, id := range f.order {
+		if f.closed[id] {
+			continue
+		}
+		blocked := false
+		for _, dep := range f.deps[id] {
+			if !f.closed[dep] {
+				blocked = true
+			}
+		}
+		if !blocked {
+			entries = append(entries, bdReadyEntry{ID: id, Title: id, Status: "open"})
+		}
+	}
+	return json.Marshal(entries)
+}
+
+func (f *fakeReadyBD) close(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed[id] = true
+}
+
+// beadIDFromWorkDir recovers the bead ID from its /tmp/ralph-<id> worktree.
+func beadIDFromWorkDir(workDir string) string {
+	return strings.TrimPrefix(filepath.Base(workDir), "ralph-")
+}
+
+func newSchedulerTestCore(t *testing.T, bd *fakeReadyBD) *Core {
+	t.Helper()
+	return &Core{
+		WorkDir:     setupTestGitRepo(t),
+		MaxParallel: 2,
+		Output:      &bytes.Buffer{},
+		RunBD:       bd.run,
+		FetchPrompt: func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+			return &PromptData{ID: beadID, Title: beadID}, nil
+		},
+		Render: func(data *PromptData) (string, error) { return data.ID, nil },
+	}
+}
+
+func TestCore_Run_RefillsFreeSlots(t *testing.T) {
+	prefix := fmt.Sprintf("sched%d", time.Now().UnixNano())
+	slow := prefix + "-slow"
+	fast := []string{prefix + "-fast1", prefix + "-fast2", prefix + "-fast3"}
+	bd := newFakeReadyBD(append([]string{slow}, fast...), nil)
+	c := newSchedulerTestCore(t, bd)
+
+	// The slow bead only finishes once every fast bead has, which is only
+	// possible if the second slot is refilled while the slow bead runs.
+	fastDone := make(chan struct{}, len(fast))
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		if beadIDFromWorkDir(workDir) != slow {
+			fastDone <- struct{}{}
+			return &AgentResult{}, nil
+		}
+		for range fast {
+			select {
+			case <-fastDone:
+			case <-time.After(5 * time.Second):
+				return &AgentResult{ExitCode: 1}, nil
+			}
+		}
+		return &AgentResult{}, nil
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		if result.ExitCode != 0 {
+			return OutcomeFailure, "slot was not refilled"
+		}
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Succeeded != 4 || result.Failed != 0 {
+		t.Errorf("succeeded/failed = %d/%d, want 4/0", result.Succeeded, result.Failed)
+	}
+	if result.StopReason != StopNormal {
+		t.Errorf("StopReason = %s, want %s", result.StopReason, StopNormal)
+	}
+}
+
+func TestCore_Run_DependentStartsAfterMerge(t *testing.T) {
+	prefix := fmt.Sprintf("sched%d", time.Now().UnixNano())
+	parent, child := prefix+"-parent", prefix+"-child"
+	bd := newFakeReadyBD([]string{parent, child}, map[string][]string{child: {parent}})
+	c := newSchedulerTestCore(t, bd)
+
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		if beadIDFromWorkDir(workDir) == parent {
+			if err := os.WriteFile(filepath.Join(workDir, "parent.txt"), []byte("done\n"), 0644); err != nil {
+				return nil, err
+			}
+			for _, args := range [][]string{{"add", "parent.txt"}, {"commit", "-m", "parent work"}} {
+				if out, err := exec.Command("git", append([]string{"-C", workDir}, args...)...).CombinedOutput(); err != nil {
+					return nil, fmt.Errorf("git %v: %v: %s", args, err, out)
+				}
+			}
+			return &AgentResult{}, nil
+		}
+		// The child's worktree must already contain the parent's merged work.
+		if _, err := os.Stat(filepath.Join(workDir, "parent.txt")); err != nil {
+			return &AgentResult{ExitCode: 1, Stderr: "parent work missing"}, nil
+		}
+		return &AgentResult{}, nil
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		if result.ExitCode != 0 {
+			return OutcomeFailure, result.Stderr
+		}
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Succeeded != 2 || result.Failed != 0 {
+		t.Errorf("succeeded/failed = %d/%d, want 2/0\n%s", result.Succeeded, result.Failed, c.Output)
+	}
+}
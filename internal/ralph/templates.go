@@ -0,0 +1,148 @@
+package ralph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// Override templates ralph looks for in a repository.
+const (
+	PromptTemplateFile = ".ralph/prompt.tmpl" // the bead prompt, rendered with PromptData
+	MergeTemplateFile  = ".ralph/merge.tmpl"  // the merge conflict prompt, rendered with ConflictResolutionData
+)
+
+// Templates holds prompt template overrides. A nil *Templates, or a nil
+// field, means the built-in template. Merge replaces the prompt of the
+// agents that resolve a bead's merge conflicts (see
+// RenderConflictResolutionPrompt), the only prompt ralph renders for a
+// merge; merges without conflicts run no agent.
+type Templates struct {
+	Prompt *template.Template // bead prompt, executed with *PromptData
+	Merge  *template.Template // merge conflict prompt, executed with *ConflictResolutionData
+
+	// PromptSource and MergeSource are the files the overrides came from.
+	PromptSource string
+	MergeSource  string
+}
+
+// LoadTemplates loads the template overrides for the repository at
+// workDir. promptPath and mergePath, from the project config, win when
+// set; otherwise PromptTemplateFile and MergeTemplateFile in workDir are
+// used if present. Each override is test-rendered with sample data, so a
+// template referring to fields its data lacks fails here rather than on
+// the first bead.
+func LoadTemplates(workDir, promptPath, mergePath string) (*Templates, error) {
+	t := &Templates{}
+	var err error
+	t.Prompt, t.PromptSource, err = loadTemplate("prompt template", workDir, promptPath, PromptTemplateFile, samplePromptData())
+	if err != nil {
+		return nil, err
+	}
+	t.Merge, t.MergeSource, err = loadTemplate("merge template", workDir, mergePath, MergeTemplateFile, sampleConflictData())
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// loadTemplate parses the template at path, or at repoFile in workDir
+// when path is empty, and checks that it renders every sample. It returns
+// a nil template when neither file exists.
+func loadTemplate(what, workDir, path, repoFile string, samples []any) (*template.Template, string, error) {
+	if path == "" {
+		path = filepath.Join(workDir, repoFile)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, "", nil
+		}
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", what, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, "", fmt.Errorf("%s %s: %w", what, path, err)
+	}
+	for _, data := range samples {
+		if err := tmpl.Execute(io.Discard, data); err != nil {
+			return nil, "", fmt.Errorf("%s %s: %w", what, path, err)
+		}
+	}
+	return tmpl, path, nil
+}
+
+// samplePromptData is what a prompt template is validated with: a bead
+// with every field set, and one with only the required ones.
+func samplePromptData() []any {
+	return []any{
+		&PromptData{
+			ID:                 "sample-1",
+			Title:              "Sample bead",
+			Description:        "What to do.",
+			AcceptanceCriteria: "It works.",
+			Design:             "How to do it.",
+			Notes:              "Review comments.",
+			Epic:               &RelatedBead{ID: "sample", Title: "Sample epic", Status: "open", Description: "The bigger picture."},
+			Siblings:           []RelatedBead{{ID: "sample-2", Title: "Sibling", Status: "open"}},
+			MoreSiblings:       1,
+			Dependencies:       []RelatedBead{{ID: "sample-0", Title: "Dependency", Status: "closed", Changes: "abc1234 Add groundwork"}},
+			MoreDependencies:   1,
+			PreviousAttempt: &FailedAttempt{
+				Attempt:   1,
+				Outcome:   OutcomeFailure,
+				Error:     "bead still open",
+				Stderr:    "panic",
+				Diff:      "+change",
+				Discarded: true,
+			},
+		},
+		&PromptData{ID: "sample-1", Title: "Sample bead"},
+	}
+}
+
+// sampleConflictData is what a merge template is validated with.
+func sampleConflictData() []any {
+	return []any{
+		&ConflictResolutionData{
+			TargetBranch:    "main",
+			SourceBranch:    "ralph/sample-1",
+			RepoPath:        "/repo",
+			ConflictDetails: "UU file.go",
+			BeadID:          "sample-1",
+			BeadTitle:       "Sample bead",
+			Strategy:        MergeStrategyRebase,
+			Attempt:         2,
+			PreviousFailure: "Conflict markers remain in: file.go",
+		},
+		&ConflictResolutionData{TargetBranch: "main", SourceBranch: "ralph/sample-1", RepoPath: "/repo"},
+	}
+}
+
+// RenderPrompt renders the bead prompt with the override, if any.
+func (t *Templates) RenderPrompt(data *PromptData) (string, error) {
+	if t == nil || t.Prompt == nil {
+		return RenderPrompt(data)
+	}
+	var buf bytes.Buffer
+	if err := t.Prompt.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering prompt template %s: %w", t.PromptSource, err)
+	}
+	return buf.String(), nil
+}
+
+// RenderConflictResolution renders the merge conflict prompt with the
+// override, if any.
+func (t *Templates) RenderConflictResolution(data *ConflictResolutionData) (string, error) {
+	if t == nil || t.Merge == nil {
+		return RenderConflictResolutionPrompt(data)
+	}
+	var buf bytes.Buffer
+	if err := t.Merge.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering merge template %s: %w", t.MergeSource, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,92 @@
+package ralph
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	repo := t.TempDir()
+	data := &PromptData{ID: "b-1", Title: "Fix it"}
+
+	tmpl, err := LoadTemplates(repo, "", "")
+	if err != nil {
+		t.Fatalf("LoadTemplates without overrides: %v", err)
+	}
+	got, err := tmpl.RenderPrompt(data)
+	if want, _ := RenderPrompt(data); err != nil || got != want {
+		t.Errorf("without overrides RenderPrompt = %q, %v; want the built-in prompt", got, err)
+	}
+
+	writeTemplate(t, filepath.Join(repo, PromptTemplateFile), "repo: {{.ID}} {{.Title}}")
+	writeTemplate(t, filepath.Join(repo, MergeTemplateFile), "merge {{.SourceBranch}} into {{.TargetBranch}}")
+	tmpl, err = LoadTemplates(repo, "", "")
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	if got, err := tmpl.RenderPrompt(data); err != nil || got != "repo: b-1 Fix it" {
+		t.Errorf("RenderPrompt = %q, %v; want the repo template", got, err)
+	}
+	got, err = tmpl.RenderConflictResolution(&ConflictResolutionData{TargetBranch: "main", SourceBranch: "ralph/b-1"})
+	if err != nil || got != "merge ralph/b-1 into main" {
+		t.Errorf("RenderConflictResolution = %q, %v; want the repo template", got, err)
+	}
+
+	configured := filepath.Join(t.TempDir(), "prompt.tmpl")
+	writeTemplate(t, configured, "config: {{.ID}}")
+	tmpl, err = LoadTemplates(repo, configured, "")
+	if err != nil {
+		t.Fatalf("LoadTemplates with a configured template: %v", err)
+	}
+	if got, err := tmpl.RenderPrompt(data); err != nil || got != "config: b-1" {
+		t.Errorf("RenderPrompt = %q, %v; want the configured template", got, err)
+	}
+	if tmpl.PromptSource != configured {
+		t.Errorf("PromptSource = %q, want %q", tmpl.PromptSource, configured)
+	}
+}
+
+func TestLoadTemplates_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		text string
+	}{
+		{"syntax", PromptTemplateFile, "{{.ID"},
+		{"unknown field", PromptTemplateFile, "{{.Nope}}"},
+		{"unguarded previous attempt", PromptTemplateFile, "{{.PreviousAttempt.Error}}"},
+		{"unknown merge field", MergeTemplateFile, "{{.Description}}"},
+	}
+	for _, tt := range tests {
+		repo := t.TempDir()
+		writeTemplate(t, filepath.Join(repo, tt.file), tt.text)
+		if _, err := LoadTemplates(repo, "", ""); err == nil || !strings.Contains(err.Error(), tt.file) {
+			t.Errorf("%s: LoadTemplates error = %v, want one naming %s", tt.name, err, tt.file)
+		}
+	}
+
+	if _, err := LoadTemplates(t.TempDir(), "/nonexistent/prompt.tmpl", ""); err == nil {
+		t.Error("missing configured template: want error")
+	}
+}
+
+func TestTemplates_NilFallsBack(t *testing.T) {
+	var tmpl *Templates
+	data := &ConflictResolutionData{TargetBranch: "main", SourceBranch: "ralph/x", RepoPath: "/repo"}
+	got, err := tmpl.RenderConflictResolution(data)
+	if want, _ := RenderConflictResolutionPrompt(data); err != nil || got != want {
+		t.Errorf("nil Templates RenderConflictResolution = %q, %v; want the built-in prompt", got, err)
+	}
+}
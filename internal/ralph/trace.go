@@ -0,0 +1,105 @@
+package ralph
+
+import (
+	"fmt"
+
+	"devdeploy/internal/agent"
+)
+
+// TraceEmitter receives a run as a span tree: one loop span, an iteration
+// span per bead under it, and a span per agent tool call under each
+// iteration. tui.LocalTraceEmitter implements it. Methods are called
+// concurrently for parallel beads.
+type TraceEmitter interface {
+	// StartLoop begins the run's trace.
+	StartLoop(model, epic, workdir string, maxIterations int) string
+	// EndLoopWithAttrs completes the run's trace.
+	EndLoopWithAttrs(stopReason string, iterations, succeeded, failed int, extraAttrs map[string]string)
+	// StartIteration begins a bead's span and returns its span ID.
+	StartIteration(beadID, beadTitle string, iterNum int) string
+	// EndIterationWithAttrs completes a bead's span.
+	EndIterationWithAttrs(spanID string, outcome string, durationMs int64, extraAttrs map[string]string)
+	// StartToolWithParent begins a tool call span under parentSpanID and
+	// returns its span ID.
+	StartToolWithParent(toolName string, attrs map[string]string, parentSpanID string) string
+	// EndTool completes a tool call span.
+	EndTool(spanID string, attrs map[string]string)
+}
+
+// iterationAttrs returns the trace attributes recorded when a bead's
+// iteration ends.
+func iterationAttrs(r BeadResult) map[string]string {
+	attrs := usageAttrs(r.Usage)
+	if r.ChatID != "" {
+		attrs["chat_id"] = r.ChatID
+	}
+	if r.ExitCode != 0 {
+		attrs["exit_code"] = fmt.Sprintf("%d", r.ExitCode)
+	}
+	if r.Attempt > 1 {
+		attrs["attempt"] = fmt.Sprintf("%d", r.Attempt)
+	}
+	if r.Review != nil {
+		attrs["review_verdict"] = string(r.Review.Verdict)
+		if r.Review.Model != "" {
+			attrs["review_model"] = r.Review.Model
+		}
+		if r.Review.ChatID != "" {
+			attrs["review_chat_id"] = r.Review.ChatID
+		}
+	}
+	return attrs
+}
+
+// usageAttrs returns the trace attributes for token usage, or none when
+// the CLI reported no usage.
+func usageAttrs(u agent.Usage) map[string]string {
+	attrs := map[string]string{}
+	if u.Tokens() == 0 {
+		return attrs
+	}
+	if u.Model != "" {
+		attrs["model"] = u.Model
+	}
+	attrs["input_tokens"] = fmt.Sprintf("%d", u.InputTokens)
+	attrs["output_tokens"] = fmt.Sprintf("%d", u.OutputTokens)
+	attrs["cache_read_tokens"] = fmt.Sprintf("%d", u.CacheReadTokens)
+	attrs["cache_write_tokens"] = fmt.Sprintf("%d", u.CacheWriteTokens)
+	attrs["cost_usd"] = fmt.Sprintf("%.4f", u.CostUSD)
+	return attrs
+}
+
+// beadToolObserver forwards one bead's agent tool calls to the Core's
+// ToolObserver and TraceEmitter, either of which may be nil.
+type beadToolObserver struct {
+	beadID   string
+	obs      ToolObserver
+	tracer   TraceEmitter
+	iterSpan string
+	// spans maps call IDs to tool span IDs. agent.ToolStream calls the
+	// observer from one goroutine at a time, so it needs no lock.
+	spans map[string]string
+}
+
+func newBeadToolObserver(beadID string, obs ToolObserver, tracer TraceEmitter, iterSpan string) *beadToolObserver {
+	return &beadToolObserver{beadID: beadID, obs: obs, tracer: tracer, iterSpan: iterSpan, spans: make(map[string]string)}
+}
+
+func (b *beadToolObserver) ToolStarted(call agent.ToolCall) {
+	if b.tracer != nil && b.iterSpan != "" {
+		b.spans[call.ID] = b.tracer.StartToolWithParent(call.Name, call.Attrs, b.iterSpan)
+	}
+	if b.obs != nil {
+		b.obs.OnToolStart(b.beadID, call)
+	}
+}
+
+func (b *beadToolObserver) ToolEnded(id string, attrs map[string]string) {
+	if span, ok := b.spans[id]; ok {
+		b.tracer.EndTool(span, attrs)
+		delete(b.spans, id)
+	}
+	if b.obs != nil {
+		b.obs.OnToolEnd(b.beadID, id, attrs)
+	}
+}
@@ -0,0 +1,143 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"devdeploy/internal/agent"
+	"devdeploy/internal/beads"
+)
+
+// recordingTracer logs trace calls as strings and hands out span IDs in
+// order.
+type recordingTracer struct {
+	mu     sync.Mutex
+	events []string
+	spans  int
+}
+
+func (r *recordingTracer) log(format string, args ...any) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+	r.spans++
+	return fmt.Sprintf("span%d", r.spans)
+}
+
+func (r *recordingTracer) StartLoop(model, epic, workdir string, maxIterations int) string {
+	return r.log("loop start %s %s %d", model, epic, maxIterations)
+}
+
+func (r *recordingTracer) EndLoopWithAttrs(stopReason string, iterations, succeeded, failed int, extraAttrs map[string]string) {
+	r.log("loop end %s %d/%d/%d %v", stopReason, iterations, succeeded, failed, extraAttrs)
+}
+
+func (r *recordingTracer) StartIteration(beadID, beadTitle string, iterNum int) string {
+	return r.log("iteration start %s %q %d", beadID, beadTitle, iterNum)
+}
+
+func (r *recordingTracer) EndIterationWithAttrs(spanID string, outcome string, durationMs int64, extraAttrs map[string]string) {
+	r.log("iteration end %s %s %v", spanID, outcome, extraAttrs)
+}
+
+func (r *recordingTracer) StartToolWithParent(toolName string, attrs map[string]string, parentSpanID string) string {
+	return r.log("tool start %s %v under %s", toolName, attrs, parentSpanID)
+}
+
+func (r *recordingTracer) EndTool(spanID string, attrs map[string]string) {
+	r.log("tool end %s %v", spanID, attrs)
+}
+
+func TestCore_Run_Tracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	core := &Core{
+		WorkDir:     "/tmp/test",
+		RootBead:    "epic",
+		Model:       "m1",
+		MaxParallel: 1,
+		Output:      &bytes.Buffer{},
+		Tracer:      tracer,
+		RunBD: mockBDForCore([]beads.Bead{
+			{ID: "b-1", Title: "First", Status: "open"},
+			{ID: "b-2", Title: "Second", Status: "open"},
+		}),
+		FetchPrompt: func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+			return &PromptData{ID: beadID}, nil
+		},
+		Render: func(data *PromptData) (string, error) { return "prompt", nil },
+		Execute: func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+			return &AgentResult{ChatID: "chat-1", ExitCode: 1}, nil
+		},
+		AssessFn: mockAssess(OutcomeFailure),
+	}
+
+	if _, err := core.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []string{
+		"loop start m1 epic 0",
+		`iteration start b-1 "First" 1`,
+		"iteration end span2 failure map[chat_id:chat-1 exit_code:1]",
+		`iteration start b-2 "Second" 2`,
+		"iteration end span4 failure map[chat_id:chat-1 exit_code:1]",
+		"loop end normal 2/0/2 map[]",
+	}
+	if !reflect.DeepEqual(tracer.events, want) {
+		t.Errorf("trace:\n%s\nwant:\n%s", strings.Join(tracer.events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// toolEventObserver records tool events seen by a ProgressObserver.
+type toolEventObserver struct {
+	NoopObserver
+	events []string
+}
+
+func (o *toolEventObserver) OnToolStart(beadID string, call agent.ToolCall) {
+	o.events = append(o.events, "start "+beadID+" "+call.ID)
+}
+
+func (o *toolEventObserver) OnToolEnd(beadID, callID string, attrs map[string]string) {
+	o.events = append(o.events, "end "+beadID+" "+callID)
+}
+
+func TestBeadToolObserver(t *testing.T) {
+	tracer := &recordingTracer{spans: 10}
+	obs := &toolEventObserver{}
+	b := newBeadToolObserver("b-1", obs, tracer, "iter")
+
+	b.ToolStarted(agent.ToolCall{ID: "c1", Name: "shell", Attrs: map[string]string{"command": "make"}})
+	b.ToolStarted(agent.ToolCall{ID: "c2", Name: "read"})
+	b.ToolEnded("c2", map[string]string{"status": "ok"})
+	b.ToolEnded("c1", map[string]string{"status": "error"})
+	b.ToolEnded("unknown", nil)
+
+	wantTrace := []string{
+		"tool start shell map[command:make] under iter",
+		"tool start read map[] under iter",
+		"tool end span12 map[status:ok]",
+		"tool end span11 map[status:error]",
+	}
+	if !reflect.DeepEqual(tracer.events, wantTrace) {
+		t.Errorf("trace:\n%s\nwant:\n%s", strings.Join(tracer.events, "\n"), strings.Join(wantTrace, "\n"))
+	}
+	wantObs := []string{"start b-1 c1", "start b-1 c2", "end b-1 c2", "end b-1 c1", "end b-1 unknown"}
+	if !reflect.DeepEqual(obs.events, wantObs) {
+		t.Errorf("observer events = %v, want %v", obs.events, wantObs)
+	}
+
+	// Without a tracer, calls still reach the observer.
+	obs = &toolEventObserver{}
+	b = newBeadToolObserver("b-2", obs, nil, "")
+	b.ToolStarted(agent.ToolCall{ID: "c1"})
+	b.ToolEnded("c1", nil)
+	if len(obs.events) != 2 {
+		t.Errorf("observer events = %v, want start and end", obs.events)
+	}
+}
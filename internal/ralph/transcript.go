@@ -0,0 +1,206 @@
+package ralph
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Transcript files of one agent invocation, named by appending these to
+// its path prefix.
+const (
+	TranscriptPromptExt = ".prompt.md"
+	TranscriptStdoutExt = ".jsonl"
+	TranscriptStderrExt = ".stderr"
+)
+
+// DefaultTranscriptRuns is how many runs keep their transcripts.
+const DefaultTranscriptRuns = 20
+
+// Transcript roles: which agent of a bead an invocation was.
+const (
+	TranscriptAgent  = "agent"
+	TranscriptReview = "review"
+	TranscriptMerge  = "merge"
+)
+
+// openTranscript writes the prompt file for prefix and creates its stdout
+// file.
+func openTranscript(prefix, prompt string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(prefix), 0755); err != nil {
+		return nil, fmt.Errorf("creating transcript dir: %w", err)
+	}
+	if err := os.WriteFile(prefix+TranscriptPromptExt, []byte(prompt), 0644); err != nil {
+		return nil, fmt.Errorf("writing transcript: %w", err)
+	}
+	f, err := os.Create(prefix + TranscriptStdoutExt)
+	if err != nil {
+		return nil, fmt.Errorf("writing transcript: %w", err)
+	}
+	return f, nil
+}
+
+// TranscriptDir returns the transcript directory of a run, next to its
+// journal: <git-common-dir>/ralph/runs/<run-id>.
+func TranscriptDir(workDir, runID string) (string, error) {
+	dir, err := JournalDir(workDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, runID), nil
+}
+
+// Transcripts hands out transcript paths for the agent invocations of one
+// run: <run dir>/<bead-id>/<role>-<n>. Set it as Core.Transcripts.
+type Transcripts struct {
+	dir string
+	mu  sync.Mutex
+	seq map[string]int // bead/role -> last n
+}
+
+// NewTranscripts returns the transcripts of run runID in workDir's
+// repository.
+func NewTranscripts(workDir, runID string) (*Transcripts, error) {
+	dir, err := TranscriptDir(workDir, runID)
+	if err != nil {
+		return nil, err
+	}
+	return &Transcripts{dir: dir, seq: make(map[string]int)}, nil
+}
+
+// Dir returns the run's transcript directory.
+func (t *Transcripts) Dir() string { return t.dir }
+
+// path returns the prefix for the next invocation of role on beadID.
+// Numbering continues after transcripts of earlier sessions of a resumed
+// run.
+func (t *Transcripts) path(beadID, role string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := beadID + "/" + role
+	if _, ok := t.seq[key]; !ok {
+		for _, tr := range listTranscripts(filepath.Join(t.dir, beadID)) {
+			if tr.Role == role && tr.N > t.seq[key] {
+				t.seq[key] = tr.N
+			}
+		}
+	}
+	t.seq[key]++
+	return filepath.Join(t.dir, beadID, fmt.Sprintf("%s-%d", role, t.seq[key]))
+}
+
+// Transcript is one recorded agent invocation.
+type Transcript struct {
+	Role string // TranscriptAgent, TranscriptReview or TranscriptMerge
+	N    int    // 1 for the role's first invocation on the bead
+	Path string // prefix of the transcript files
+}
+
+// ListTranscripts returns a bead's transcripts in a run, oldest first.
+func ListTranscripts(workDir, runID, beadID string) ([]Transcript, error) {
+	dir, err := TranscriptDir(workDir, runID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, beadID)); err != nil {
+		return nil, fmt.Errorf("no transcripts for %s in run %s", beadID, runID)
+	}
+	return listTranscripts(filepath.Join(dir, beadID)), nil
+}
+
+// listTranscripts returns the transcripts in a bead directory, ordered by
+// when their prompts were written.
+func listTranscripts(beadDir string) []Transcript {
+	matches, _ := filepath.Glob(filepath.Join(beadDir, "*"+TranscriptPromptExt))
+	type entry struct {
+		Transcript
+		mtime int64
+	}
+	var entries []entry
+	for _, m := range matches {
+		prefix := strings.TrimSuffix(m, TranscriptPromptExt)
+		role, num, ok := strings.Cut(filepath.Base(prefix), "-")
+		n, err := strconv.Atoi(num)
+		if !ok || err != nil {
+			continue
+		}
+		var mtime int64
+		if info, err := os.Stat(m); err == nil {
+			mtime = info.ModTime().UnixNano()
+		}
+		entries = append(entries, entry{Transcript{Role: role, N: n, Path: prefix}, mtime})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].mtime != entries[j].mtime {
+			return entries[i].mtime < entries[j].mtime
+		}
+		return entries[i].N < entries[j].N
+	})
+	out := make([]Transcript, len(entries))
+	for i, e := range entries {
+		out[i] = e.Transcript
+	}
+	return out
+}
+
+// TranscriptBeads returns the IDs of the beads with transcripts in a run.
+func TranscriptBeads(workDir, runID string) ([]string, error) {
+	dir, err := TranscriptDir(workDir, runID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no transcripts for run %s", runID)
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+}
+
+// PruneTranscripts removes the transcripts and logs of all but the newest
+// keep runs in workDir's repository. Journals are kept so runs can still
+// be resumed or reported on. The run current is never removed.
+func PruneTranscripts(workDir string, keep int, current string) error {
+	dir, err := JournalDir(workDir)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	// Run IDs sort by start time; ReadDir returns them sorted.
+	var runs []string
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != current {
+			runs = append(runs, e.Name())
+		}
+	}
+	if current != "" {
+		keep-- // the current run takes one of the slots
+	}
+	for len(runs) > max(keep, 0) {
+		run := runs[0]
+		runs = runs[1:]
+		if err := os.RemoveAll(filepath.Join(dir, run)); err != nil {
+			return fmt.Errorf("pruning transcripts of %s: %w", run, err)
+		}
+		_ = os.Remove(filepath.Join(dir, run+".log"))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ralph
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTranscript records an empty agent run at the next path for role.
+func writeTranscript(t *testing.T, tr *Transcripts, beadID, role string) string {
+	t.Helper()
+	prefix := tr.path(beadID, role)
+	f, err := openTranscript(prefix, "prompt")
+	if err != nil {
+		t.Fatalf("openTranscript: %v", err)
+	}
+	f.Close()
+	return prefix
+}
+
+func TestTranscripts_Paths(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	tr, err := NewTranscripts(repo, "run-1")
+	if err != nil {
+		t.Fatalf("NewTranscripts: %v", err)
+	}
+	wantDir := filepath.Join(repo, ".git", JournalDirName, "run-1")
+	if tr.Dir() != wantDir {
+		t.Errorf("Dir = %q, want %q", tr.Dir(), wantDir)
+	}
+
+	writeTranscript(t, tr, "b-1", TranscriptAgent)
+	writeTranscript(t, tr, "b-1", TranscriptReview)
+	writeTranscript(t, tr, "b-2", TranscriptAgent)
+
+	// A resumed run continues the numbering.
+	tr, _ = NewTranscripts(repo, "run-1")
+	if got := writeTranscript(t, tr, "b-1", TranscriptAgent); got != filepath.Join(wantDir, "b-1", "agent-2") {
+		t.Errorf("path after resume = %q, want agent-2", got)
+	}
+
+	ids, err := TranscriptBeads(repo, "run-1")
+	if err != nil {
+		t.Fatalf("TranscriptBeads: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"b-1", "b-2"}) {
+		t.Errorf("beads = %v, want [b-1 b-2]", ids)
+	}
+
+	ts, err := ListTranscripts(repo, "run-1", "b-1")
+	if err != nil {
+		t.Fatalf("ListTranscripts: %v", err)
+	}
+	var names []string
+	for _, tr := range ts {
+		names = append(names, filepath.Base(tr.Path))
+	}
+	if !reflect.DeepEqual(names, []string{"agent-1", "review-1", "agent-2"}) {
+		t.Errorf("transcripts = %v, want agent-1 review-1 agent-2", names)
+	}
+
+	if _, err := ListTranscripts(repo, "run-1", "b-9"); err == nil {
+		t.Error("ListTranscripts of unknown bead: want error")
+	}
+}
+
+func TestPruneTranscripts(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	runs := []string{"20260101-000000-aaaa", "20260102-000000-aaaa", "20260103-000000-aaaa", "20260104-000000-aaaa"}
+	for _, run := range runs {
+		tr, _ := NewTranscripts(repo, run)
+		writeTranscript(t, tr, "b-1", TranscriptAgent)
+		if err := os.WriteFile(tr.Dir()+".log", nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Resuming the oldest run keeps it, plus the newest other one.
+	if err := PruneTranscripts(repo, 2, runs[0]); err != nil {
+		t.Fatalf("PruneTranscripts: %v", err)
+	}
+	dir, _ := JournalDir(repo)
+	for i, run := range runs {
+		_, errDir := os.Stat(filepath.Join(dir, run))
+		_, errLog := os.Stat(filepath.Join(dir, run+".log"))
+		kept := i == 0 || i == 3
+		if (errDir == nil) != kept || (errLog == nil) != kept {
+			t.Errorf("run %s: dir err %v, log err %v, want kept = %v", run, errDir, errLog, kept)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"devdeploy/internal/ralph"
 	"devdeploy/internal/trace"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -12,6 +13,7 @@ import (
 
 // LocalTraceEmitter emits trace events as Bubble Tea messages
 // instead of HTTP requests. It maintains local trace state.
+// It implements ralph.TraceEmitter.
 type LocalTraceEmitter struct {
 	manager    *trace.Manager
 	program    *tea.Program // Set after TUI starts
@@ -21,6 +23,8 @@ type LocalTraceEmitter struct {
 	parentID   string // Current parent span ID for nesting (iteration spanID for tools)
 }
 
+var _ ralph.TraceEmitter = (*LocalTraceEmitter)(nil)
+
 // NewLocalTraceEmitter creates a new local trace emitter
 func NewLocalTraceEmitter() *LocalTraceEmitter {
 	return &LocalTraceEmitter{
@@ -78,6 +82,11 @@ func (e *LocalTraceEmitter) StartLoop(model, epic, workdir string, maxIterations
 
 // EndLoop completes the trace
 func (e *LocalTraceEmitter) EndLoop(stopReason string, iterations, succeeded, failed int) {
+	e.EndLoopWithAttrs(stopReason, iterations, succeeded, failed, nil)
+}
+
+// EndLoopWithAttrs completes the trace with additional attributes
+func (e *LocalTraceEmitter) EndLoopWithAttrs(stopReason string, iterations, succeeded, failed int, extraAttrs map[string]string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -85,18 +94,23 @@ func (e *LocalTraceEmitter) EndLoop(stopReason string, iterations, succeeded, fa
 		return
 	}
 
+	attrs := map[string]string{
+		"stop_reason": stopReason,
+		"iterations":  fmt.Sprintf("%d", iterations),
+		"succeeded":   fmt.Sprintf("%d", succeeded),
+		"failed":      fmt.Sprintf("%d", failed),
+	}
+	for k, v := range extraAttrs {
+		attrs[k] = v
+	}
+
 	event := trace.TraceEvent{
-		TraceID:   e.traceID,
-		SpanID:    e.loopSpanID, // Use the same SpanID from StartLoop
-		Type:      trace.EventLoopEnd,
-		Name:      "ralph-loop",
-		Timestamp: time.Now(),
-		Attributes: map[string]string{
-			"stop_reason": stopReason,
-			"iterations":  fmt.Sprintf("%d", iterations),
-			"succeeded":   fmt.Sprintf("%d", succeeded),
-			"failed":      fmt.Sprintf("%d", failed),
-		},
+		TraceID:    e.traceID,
+		SpanID:     e.loopSpanID, // Use the same SpanID from StartLoop
+		Type:       trace.EventLoopEnd,
+		Name:       "ralph-loop",
+		Timestamp:  time.Now(),
+		Attributes: attrs,
 	}
 
 	e.manager.HandleEvent(event)
@@ -247,13 +261,13 @@ type TraceUpdateMsg struct {
 	Trace *trace.Trace
 }
 
-// sendUpdate sends a trace update message to the TUI
+// sendUpdate sends a trace update message to the TUI. The message carries
+// a snapshot: the loop keeps changing the live trace while the TUI renders.
 func (e *LocalTraceEmitter) sendUpdate() {
-	if e.program == nil {
+	if e.program == nil || e.traceID == "" {
 		return
 	}
-	activeTrace := e.manager.GetActiveTrace()
-	if activeTrace != nil {
-		e.program.Send(TraceUpdateMsg{Trace: activeTrace})
+	if snapshot := e.manager.Snapshot(e.traceID); snapshot != nil {
+		e.program.Send(TraceUpdateMsg{Trace: snapshot})
 	}
 }
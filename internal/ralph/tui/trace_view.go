@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"devdeploy/internal/ralph"
@@ -152,6 +153,9 @@ func (v *TraceViewModel) renderIteration(span *trace.Span, prefix string, isLast
 	if span.Duration > 0 {
 		line += " " + v.styles.Duration.Render(ralph.FormatDuration(span.Duration))
 	}
+	if cost, err := strconv.ParseFloat(span.Attributes["cost_usd"], 64); err == nil && cost > 0 {
+		line += " " + v.styles.Muted.Render(fmt.Sprintf("$%.2f", cost))
+	}
 
 	// Show exit code for failures
 	if outcome == ralph.OutcomeFailure || outcome == ralph.OutcomeTimeout {
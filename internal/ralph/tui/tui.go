@@ -4,10 +4,12 @@ package tui
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"devdeploy/internal/agent"
 	"devdeploy/internal/beads"
 	"devdeploy/internal/ralph"
 
@@ -33,12 +35,23 @@ type Model struct {
 	loopStart    time.Time
 	iterNum      int // Current iteration number
 
+	// running holds the live status of in-flight beads, by bead ID.
+	running map[string]*beadStatus
+
 	// Failure tracking for display
 	lastFailure *ralph.BeadResult
 
 	mu sync.Mutex // Protects fields updated from observer goroutine
 }
 
+// beadStatus is the live status of a bead whose agent is running.
+type beadStatus struct {
+	bead   beads.Bead
+	start  time.Time
+	tool   string // current tool call, e.g. "shell go test ./..."
+	toolID string
+}
+
 // summary tracks aggregate results
 type summary struct {
 	Iterations int
@@ -47,6 +60,7 @@ type summary struct {
 	Failed     int
 	TimedOut   int
 	Duration   time.Duration
+	CostUSD    float64
 }
 
 // Compile-time interface compliance check
@@ -60,6 +74,15 @@ type (
 	loopEndMsg       struct{ Result *ralph.CoreResult }
 	loopErrorMsg     struct{ Err error }
 	durationTickMsg  struct{}
+	toolStartMsg     struct {
+		BeadID string
+		Call   agent.ToolCall
+	}
+	toolEndMsg struct {
+		BeadID string
+		CallID string
+		Attrs  map[string]string
+	}
 )
 
 // Observer implements ralph.ProgressObserver and forwards events to the TUI.
@@ -96,6 +119,20 @@ func (o *Observer) OnLoopEnd(result *ralph.CoreResult) {
 	}
 }
 
+// OnToolStart is called when a bead's agent starts a tool call.
+func (o *Observer) OnToolStart(beadID string, call agent.ToolCall) {
+	if o.program != nil {
+		o.program.Send(toolStartMsg{BeadID: beadID, Call: call})
+	}
+}
+
+// OnToolEnd is called when a bead's agent finishes a tool call.
+func (o *Observer) OnToolEnd(beadID, callID string, attrs map[string]string) {
+	if o.program != nil {
+		o.program.Send(toolEndMsg{BeadID: beadID, CallID: callID, Attrs: attrs})
+	}
+}
+
 // NewModel creates a new TUI model for the given Core.
 func NewModel(core *ralph.Core) *Model {
 	styles := DefaultStyles()
@@ -104,12 +141,15 @@ func NewModel(core *ralph.Core) *Model {
 		traceView:    NewTraceViewModel(styles),
 		traceEmitter: NewLocalTraceEmitter(),
 		styles:       styles,
+		running:      make(map[string]*beadStatus),
 	}
 }
 
 // Run starts the TUI and runs the Core loop.
 // This is the main entry point for running ralph with a TUI.
-func Run(ctx context.Context, core *ralph.Core) error {
+// The Core's Observer and Tracer are replaced; its Output should not be
+// the terminal. Run returns once the user quits and the loop has stopped.
+func Run(ctx context.Context, core *ralph.Core) (*ralph.CoreResult, error) {
 	// Create cancellable context
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -127,9 +167,14 @@ func Run(ctx context.Context, core *ralph.Core) error {
 	// Set up observer to forward events to TUI
 	observer := &Observer{program: p}
 	core.Observer = observer
+	core.Tracer = m.traceEmitter
 
 	// Run Core in background goroutine
+	var result *ralph.CoreResult
+	var coreErr error
+	coreDone := make(chan struct{})
 	go func() {
+		defer close(coreDone)
 		m.loopStart = time.Now()
 
 		// Start duration ticker
@@ -148,9 +193,9 @@ func Run(ctx context.Context, core *ralph.Core) error {
 		}()
 
 		// Run the core loop
-		result, err := core.Run(ctx)
-		if err != nil {
-			p.Send(loopErrorMsg{Err: err})
+		result, coreErr = core.Run(ctx)
+		if coreErr != nil {
+			p.Send(loopErrorMsg{Err: coreErr})
 			return
 		}
 
@@ -163,7 +208,14 @@ func Run(ctx context.Context, core *ralph.Core) error {
 
 	// Run TUI
 	_, err := p.Run()
-	return err
+
+	// Quitting early cancels the loop; wait for it to clean up.
+	cancel()
+	<-coreDone
+	if err != nil {
+		return result, err
+	}
+	return result, coreErr
 }
 
 // Init implements tea.Model
@@ -192,12 +244,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		// Reserve space for header/footer
-		traceHeight := msg.Height - 4
-		if traceHeight < 5 {
-			traceHeight = 5
-		}
-		m.traceView.SetSize(msg.Width, traceHeight)
+		m.resizeTrace()
 
 	case TraceUpdateMsg:
 		m.traceView.SetTrace(msg.Trace)
@@ -214,8 +261,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.status = fmt.Sprintf("Working on %s: %s", msg.Bead.ID, msg.Bead.Title)
 		m.mu.Unlock()
 
-		// Also update trace emitter
-		m.traceEmitter.StartIteration(msg.Bead.ID, msg.Bead.Title, m.iterNum)
+		m.running[msg.Bead.ID] = &beadStatus{bead: msg.Bead, start: time.Now()}
+		m.resizeTrace()
 
 	case beadCompleteMsg:
 		m.mu.Lock()
@@ -233,20 +280,26 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.summary.Questions++
 		}
 		m.summary.Iterations++
+		m.summary.CostUSD += r.Usage.CostUSD
 		m.mu.Unlock()
 
-		// Update trace emitter
-		attrs := map[string]string{}
-		if r.ChatID != "" {
-			attrs["chat_id"] = r.ChatID
+		delete(m.running, r.Bead.ID)
+		m.resizeTrace()
+
+	case toolStartMsg:
+		if st := m.running[msg.BeadID]; st != nil {
+			st.tool = describeTool(msg.Call)
+			st.toolID = msg.Call.ID
 		}
-		if r.ExitCode != 0 {
-			attrs["exit_code"] = fmt.Sprintf("%d", r.ExitCode)
+
+	case toolEndMsg:
+		if st := m.running[msg.BeadID]; st != nil && st.toolID == msg.CallID {
+			st.tool, st.toolID = "", ""
 		}
-		// Note: We need the spanID from StartIteration, but we don't have it here.
-		// For now, trace emitter handles this internally.
 
 	case loopEndMsg:
+		clear(m.running)
+		m.resizeTrace()
 		m.mu.Lock()
 		m.loopDone = true
 		if msg.Result != nil {
@@ -255,6 +308,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.summary.Questions = msg.Result.Questions
 			m.summary.Failed = msg.Result.Failed
 			m.summary.TimedOut = msg.Result.TimedOut
+			m.summary.CostUSD = msg.Result.Usage.CostUSD
 		}
 		if m.summary.Iterations == 0 {
 			m.status = "No beads available"
@@ -278,6 +332,56 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// resizeTrace fits the trace view around the header, the live bead
+// status lines and the status bar.
+func (m *Model) resizeTrace() {
+	if m.width == 0 {
+		return
+	}
+	traceHeight := m.height - 4 - len(m.running)
+	if traceHeight < 5 {
+		traceHeight = 5
+	}
+	m.traceView.SetSize(m.width, traceHeight)
+}
+
+// describeTool renders a tool call for the live status line.
+func describeTool(call agent.ToolCall) string {
+	for _, key := range []string{"file_path", "command", "pattern", "query"} {
+		if v := call.Attrs[key]; v != "" {
+			if len(v) > 60 {
+				v = v[:57] + "..."
+			}
+			return call.Name + " " + v
+		}
+	}
+	return call.Name
+}
+
+// runningView renders one line per in-flight bead, oldest first.
+func (m *Model) runningView() string {
+	running := make([]*beadStatus, 0, len(m.running))
+	for _, st := range m.running {
+		running = append(running, st)
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].start.Before(running[j].start) })
+
+	var b strings.Builder
+	for _, st := range running {
+		line := fmt.Sprintf("%s %s %s %s",
+			m.styles.Status.Render("●"),
+			st.bead.ID,
+			st.bead.Title,
+			m.styles.Muted.Render(ralph.FormatDuration(time.Since(st.start))))
+		if st.tool != "" {
+			line += " " + m.styles.Muted.Render("→ "+st.tool)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
 // View implements tea.Model
 func (m *Model) View() string {
 	if m.err != nil {
@@ -298,6 +402,9 @@ func (m *Model) View() string {
 	b.WriteString(m.traceView.View())
 	b.WriteString("\n")
 
+	// Live status of parallel agents
+	b.WriteString(m.runningView())
+
 	// Status bar
 	m.mu.Lock()
 	statusParts := make([]string, 0, 5)
@@ -324,6 +431,14 @@ func (m *Model) View() string {
 		statusParts = append(statusParts, m.styles.Muted.Render(durationStr))
 	}
 
+	if m.summary.CostUSD > 0 {
+		cost := fmt.Sprintf("$%.2f", m.summary.CostUSD)
+		if m.core.Budget > 0 {
+			cost += fmt.Sprintf(" of $%.2f", m.core.Budget)
+		}
+		statusParts = append(statusParts, m.styles.Muted.Render(cost))
+	}
+
 	lastFailure := m.lastFailure
 	loopDone := m.loopDone
 	m.mu.Unlock()
@@ -346,6 +461,9 @@ func (m *Model) View() string {
 		if lastFailure.ChatID != "" {
 			b.WriteString(fmt.Sprintf("  ChatID: %s\n", m.styles.Muted.Render(lastFailure.ChatID)))
 		}
+		if lastFailure.Transcript != "" {
+			b.WriteString(fmt.Sprintf("  Transcript: %s\n", m.styles.Muted.Render(lastFailure.Transcript+ralph.TranscriptStdoutExt)))
+		}
 
 		if lastFailure.ErrorMessage != "" {
 			errMsg := lastFailure.ErrorMessage
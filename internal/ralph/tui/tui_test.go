@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"devdeploy/internal/agent"
 	"devdeploy/internal/beads"
 	"devdeploy/internal/ralph"
 
@@ -321,9 +322,45 @@ func TestModel_HandleKeyQuit(t *testing.T) {
 	}
 }
 
+func TestModel_LiveBeadStatus(t *testing.T) {
+	core := &ralph.Core{WorkDir: "/tmp/test", RootBead: "epic"}
+	model := NewModel(core)
+
+	for _, msg := range []tea.Msg{
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		loopStartedMsg{RootBead: "epic"},
+		beadStartMsg{Bead: beads.Bead{ID: "bead-1", Title: "One"}},
+		beadStartMsg{Bead: beads.Bead{ID: "bead-2", Title: "Two"}},
+		toolStartMsg{BeadID: "bead-1", Call: agent.ToolCall{ID: "c1", Name: "shell", Attrs: map[string]string{"command": "go test ./..."}}},
+		toolStartMsg{BeadID: "bead-2", Call: agent.ToolCall{ID: "c1", Name: "read", Attrs: map[string]string{"file_path": "main.go"}}},
+		toolEndMsg{BeadID: "bead-2", CallID: "c1"},
+	} {
+		model.Update(msg)
+	}
+
+	view := model.View()
+	for _, want := range []string{"bead-1 One", "→ shell go test ./...", "bead-2 Two"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "main.go") {
+		t.Errorf("view shows a finished tool call:\n%s", view)
+	}
+	if model.traceView.height != 40-4-2 {
+		t.Errorf("trace height = %d, want room for two status lines", model.traceView.height)
+	}
+
+	model.Update(beadCompleteMsg{Result: ralph.BeadResult{Bead: beads.Bead{ID: "bead-1", Title: "One"}, Outcome: ralph.OutcomeSuccess}})
+	if view := model.View(); strings.Contains(view, "bead-1 One") || !strings.Contains(view, "bead-2 Two") {
+		t.Errorf("completed bead still shown as running:\n%s", view)
+	}
+}
+
 func TestObserver_ImplementsInterface(t *testing.T) {
 	// Compile-time check that Observer implements ProgressObserver
 	var _ ralph.ProgressObserver = (*Observer)(nil)
+	var _ ralph.ToolObserver = (*Observer)(nil)
 }
 
 type testError struct {
@@ -0,0 +1,114 @@
+package ralph
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"devdeploy/internal/bd"
+)
+
+// DefaultVerifyTimeout bounds one run of the verification command.
+const DefaultVerifyTimeout = 15 * time.Minute
+
+// verifyOutputLines is how much of a failed check's output is kept in the
+// error shown in logs and reports.
+const verifyOutputLines = 20
+
+// verify runs Core.VerifyCommand in dir. It returns nil when no command is
+// configured, and an error with the tail of the output when the check fails.
+func (c *Core) verify(ctx context.Context, dir string) error {
+	if c.VerifyCommand == "" {
+		return nil
+	}
+	timeout := c.VerifyTimeout
+	if timeout == 0 {
+		timeout = DefaultVerifyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.VerifyCommand)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), c.Env...)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return fmt.Errorf("%s: %w\n%s", c.VerifyCommand, err, tailLines(string(out), verifyOutputLines))
+}
+
+// tailLines returns the last n lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reopenBead puts a bead the agent closed back to open, so bd does not
+// treat unverified work as done. Non-empty notes replace the bead's notes.
+func (c *Core) reopenBead(beadID, notes string) error {
+	runner := c.RunBD
+	if runner == nil {
+		runner = bd.Run
+	}
+	args := []string{"update", beadID, "--status", "open"}
+	if notes != "" {
+		args = append(args, "--notes", notes)
+	}
+	if _, err := runner(c.WorkDir, args...); err != nil {
+		return fmt.Errorf("bd update %s: %w", beadID, err)
+	}
+	return nil
+}
+
+// revertMerge undoes the merge that moved repoPath's HEAD from base, with
+// a new commit so the target branch history is kept.
+func revertMerge(repoPath, base string) error {
+	git := func(args ...string) (string, error) {
+		out, err := exec.Command("git", append([]string{"-C", repoPath}, args...)...).CombinedOutput()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	parents, err := git("rev-list", "--parents", "-n", "1", "HEAD")
+	if err != nil {
+		return fmt.Errorf("git rev-list: %w: %s", err, parents)
+	}
+	if fields := strings.Fields(parents); len(fields) == 3 && fields[1] == base {
+		// Merge commit: revert it against the target branch side.
+		if out, err := git("revert", "--no-edit", "-m", "1", "HEAD"); err != nil {
+			_, _ = git("revert", "--abort")
+			return fmt.Errorf("git revert: %w: %s", err, out)
+		}
+		return nil
+	}
+
+	// Fast-forward (or squash or rebase): undo base..HEAD in one commit
+	// that restores base's tree. Reverting the commits one by one fails on
+	// merge commits among them, e.g. the agent merging the target in.
+	var revs []string
+	for _, rev := range []string{base, "HEAD"} {
+		short, err := git("rev-parse", "--short", rev)
+		if err != nil {
+			return fmt.Errorf("git rev-parse: %w: %s", err, short)
+		}
+		revs = append(revs, short)
+	}
+	if out, err := git("read-tree", "-m", "-u", "HEAD", base); err != nil {
+		return fmt.Errorf("git read-tree: %w: %s", err, out)
+	}
+	msg := fmt.Sprintf("Revert %s\n\nThe target branch failed verification after the merge.", strings.Join(revs, ".."))
+	if out, err := git("commit", "--no-verify", "-m", msg); err != nil {
+		_, _ = git("reset", "--hard", "--quiet", "HEAD")
+		return fmt.Errorf("git commit: %w: %s", err, out)
+	}
+	return nil
+}
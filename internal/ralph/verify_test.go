@@ -0,0 +1,252 @@
+package ralph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// commitFile writes name in dir and commits it.
+func commitFile(dir, name string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0644); err != nil {
+		return err
+	}
+	for _, args := range [][]string{{"add", name}, {"commit", "-m", "add " + name}} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("git %v: %v: %s", args, err, out)
+		}
+	}
+	return nil
+}
+
+// recordingBD wraps a fake bd and records `bd update` calls.
+type recordingBD struct {
+	*fakeReadyBD
+	mu      sync.Mutex
+	updates []string
+}
+
+func (r *recordingBD) run(dir string, args ...string) ([]byte, error) {
+	if len(args) > 0 && args[0] == "update" {
+		r.mu.Lock()
+		r.updates = append(r.updates, strings.Join(args, " "))
+		r.mu.Unlock()
+		return nil, nil
+	}
+	return r.fakeReadyBD.run(dir, args...)
+}
+
+func TestCore_Run_PreMergeVerifyFailure(t *testing.T) {
+	prefix := fmt.Sprintf("verify%d", time.Now().UnixNano())
+	good, bad := prefix+"-good", prefix+"-bad"
+	bd := &recordingBD{fakeReadyBD: newFakeReadyBD([]string{good, bad}, nil)}
+	c := newSchedulerTestCore(t, bd.fakeReadyBD)
+	c.RunBD = bd.run
+	c.VerifyCommand = "test ! -f broken.txt"
+
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		name := "ok.txt"
+		if beadIDFromWorkDir(workDir) == bad {
+			name = "broken.txt"
+		}
+		return &AgentResult{}, commitFile(workDir, name)
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 1 {
+		t.Errorf("succeeded/failed = %d/%d, want 1/1\n%s", result.Succeeded, result.Failed, c.Output)
+	}
+	if _, err := os.Stat(filepath.Join(c.WorkDir, "ok.txt")); err != nil {
+		t.Errorf("verified bead was not merged: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(c.WorkDir, "broken.txt")); !os.IsNotExist(err) {
+		t.Error("bead failing verification was merged")
+	}
+	if want := "update " + bad + " --status open"; len(bd.updates) != 1 || bd.updates[0] != want {
+		t.Errorf("bd updates = %v, want [%s]", bd.updates, want)
+	}
+}
+
+func TestCore_Run_PostMergeVerifyRevertsMerge(t *testing.T) {
+	prefix := fmt.Sprintf("verify%d", time.Now().UnixNano())
+	a, b := prefix+"-a", prefix+"-b"
+	bd := &recordingBD{fakeReadyBD: newFakeReadyBD([]string{a, b}, nil)}
+	c := newSchedulerTestCore(t, bd.fakeReadyBD)
+	c.RunBD = bd.run
+	// Each bead passes alone; together they break the build.
+	c.VerifyCommand = "! { test -f a.txt && test -f b.txt; }"
+	var report bytes.Buffer
+	c.Reporter = NewReporter(&report, ReportJSON)
+
+	// Both worktrees must exist before either merge lands.
+	var started sync.WaitGroup
+	started.Add(2)
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		started.Done()
+		started.Wait()
+		name := "a.txt"
+		if beadIDFromWorkDir(workDir) == b {
+			name = "b.txt"
+		}
+		return &AgentResult{}, commitFile(workDir, name)
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 1 {
+		t.Errorf("succeeded/failed = %d/%d, want 1/1: the reverted bead is no success\n%s", result.Succeeded, result.Failed, c.Output)
+	}
+	var got Report
+	if err := json.Unmarshal(report.Bytes(), &got); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	var merges []string
+	for _, b := range got.Beads {
+		merges = append(merges, b.Merge)
+	}
+	slices.Sort(merges)
+	if !slices.Equal(merges, []string{MergeResultMerged, MergeResultReverted}) {
+		t.Errorf("report merge results = %v, want one merged and one reverted", merges)
+	}
+	_, errA := os.Stat(filepath.Join(c.WorkDir, "a.txt"))
+	_, errB := os.Stat(filepath.Join(c.WorkDir, "b.txt"))
+	if (errA == nil) == (errB == nil) {
+		t.Errorf("want exactly one bead's work on the branch, a.txt err=%v b.txt err=%v", errA, errB)
+	}
+	if len(bd.updates) != 1 {
+		t.Errorf("bd updates = %v, want the reverted bead reopened", bd.updates)
+	}
+	if out, err := exec.Command("git", "-C", c.WorkDir, "status", "--porcelain").Output(); err != nil || len(out) != 0 {
+		t.Errorf("target worktree not clean after revert: %s %v", out, err)
+	}
+}
+
+func TestCore_Run_RetryAfterRevertLandsAgain(t *testing.T) {
+	id := fmt.Sprintf("verify%d-revert", time.Now().UnixNano())
+	repo := setupTestGitRepo(t)
+	// Like an agent, only does the work if the worktree does not have it.
+	execute := func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		if _, err := os.Stat(filepath.Join(workDir, "work.txt")); err == nil {
+			return &AgentResult{}, nil
+		}
+		return &AgentResult{}, commitFile(workDir, "work.txt")
+	}
+	run := func(verify string) *RunSummary {
+		t.Helper()
+		bd := &recordingBD{fakeReadyBD: newFakeReadyBD([]string{id}, nil)}
+		c := newSchedulerTestCore(t, bd.fakeReadyBD)
+		c.WorkDir = repo
+		c.RunBD = bd.run
+		c.VerifyCommand = verify
+		c.Execute = execute
+		c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+			bd.close(beadID)
+			return OutcomeSuccess, ""
+		}
+		result, err := c.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return result
+	}
+
+	// The first run's merge fails verification on the target (where .git
+	// is a directory, not a worktree's file) and is reverted.
+	if result := run("test -f .git"); result.Failed != 1 {
+		t.Fatalf("failed = %d, want the merge reverted", result.Failed)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "work.txt")); !os.IsNotExist(err) {
+		t.Fatal("reverted work is still on the target")
+	}
+
+	// The retry starts from the target, redoes the work and lands it.
+	if result := run(""); result.Succeeded != 1 {
+		t.Fatalf("succeeded = %d, want 1", result.Succeeded)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "work.txt")); err != nil {
+		t.Errorf("the retried bead's work did not land: %v", err)
+	}
+}
+
+func TestRevertMerge_FastForwardWithMergeCommit(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	target, err := getCurrentBranch(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := gitOutput(t, repo, "rev-parse", "HEAD")
+
+	// The bead branch merged an unrelated side branch into itself before
+	// fast-forwarding the target.
+	gitOutput(t, repo, "checkout", "--quiet", "-b", "side")
+	if err := commitFile(repo, "side.txt"); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, repo, "checkout", "--quiet", target)
+	if err := commitFile(repo, "one.txt"); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, repo, "merge", "--quiet", "--no-edit", "side")
+	if err := commitFile(repo, "two.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := revertMerge(repo, base); err != nil {
+		t.Fatalf("revertMerge: %v", err)
+	}
+	for _, name := range []string{"one.txt", "side.txt", "two.txt"} {
+		if _, err := os.Stat(filepath.Join(repo, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still present after revert", name)
+		}
+	}
+	if got := gitOutput(t, repo, "diff", base, "HEAD"); got != "" {
+		t.Errorf("target differs from before the merge:\n%s", got)
+	}
+	if got := gitOutput(t, repo, "status", "--porcelain"); got != "" {
+		t.Errorf("revert left changes behind:\n%s", got)
+	}
+}
+
+func TestRevertMerge_FastForward(t *testing.T) {
+	repo := setupTestGitRepo(t)
+	base, err := exec.Command("git", "-C", repo, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"one.txt", "two.txt"} {
+		if err := commitFile(repo, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := revertMerge(repo, strings.TrimSpace(string(base))); err != nil {
+		t.Fatalf("revertMerge: %v", err)
+	}
+	for _, name := range []string{"one.txt", "two.txt"} {
+		if _, err := os.Stat(filepath.Join(repo, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still present after revert", name)
+		}
+	}
+}
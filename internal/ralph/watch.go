@@ -0,0 +1,350 @@
+package ralph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"devdeploy/internal/agent"
+)
+
+// Watch defaults.
+const (
+	// DefaultPollInterval is how often a Watcher checks a repo's beads
+	// database for changes, and how soon it asks bd for ready beads again
+	// after a pass that did work.
+	DefaultPollInterval = 10 * time.Second
+
+	// DefaultMaxIdleInterval caps the idle backoff between `bd ready`
+	// queries of a repo whose beads database has not changed.
+	DefaultMaxIdleInterval = 5 * time.Minute
+
+	// DefaultMaxConcurrency is the default cap on agents running at once
+	// across all of a Watcher's repos.
+	DefaultMaxConcurrency = 4
+)
+
+// Slots is a limit on running agents shared by several Cores, so that a
+// Watcher never runs more than a fixed number of agents across its repos.
+type Slots struct {
+	ch chan struct{}
+}
+
+// NewSlots returns a limit of n concurrent agents (at least one).
+func NewSlots(n int) *Slots {
+	return &Slots{ch: make(chan struct{}, max(n, 1))}
+}
+
+// InUse returns the number of agents holding a slot.
+func (s *Slots) InUse() int { return len(s.ch) }
+
+// tryAcquire takes up to n free slots without waiting and returns how many
+// it took.
+func (s *Slots) tryAcquire(n int) int {
+	for got := 0; got < n; got++ {
+		select {
+		case s.ch <- struct{}{}:
+		default:
+			return got
+		}
+	}
+	return n
+}
+
+// acquire takes one slot, waiting until one is free or ctx is done.
+func (s *Slots) acquire(ctx context.Context) error {
+	select {
+	case s.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Slots) release() { <-s.ch }
+
+// Watcher keeps dispatching agents as beads become ready in a set of
+// repositories, where Core.Run returns as soon as `bd ready` is empty. Each
+// repo is watched on its own: its beads database is checked for changes
+// every PollInterval, and `bd ready` is queried when it changed or, with
+// an idle backoff doubling up to MaxIdleInterval, when it did not. Ready
+// beads start a pass: a Core.Run over all of the repo's ready work. A bead
+// answered by a human, or unblocked by another pass, thus starts within a
+// PollInterval.
+//
+// Beads that fail or time out are not started again by later passes until
+// Reload is called, so a broken bead does not burn agents every poll.
+type Watcher struct {
+	// Repos are the repositories to watch.
+	Repos []string
+
+	// NewCore returns the Core for a pass over a repo. It is called for
+	// every pass, so configuration reloaded in between applies to the next
+	// one. The Watcher sets the Core's Slots and Exclude, logs to Output
+	// when the Core's Output is nil, and closes its Journal after the pass.
+	NewCore func(workDir string) (*Core, error)
+
+	// MaxConcurrency caps the agents running at once across all repos.
+	// Zero means DefaultMaxConcurrency.
+	MaxConcurrency int
+
+	// PollInterval and MaxIdleInterval tune change detection and the idle
+	// backoff. Zero means DefaultPollInterval and DefaultMaxIdleInterval.
+	PollInterval    time.Duration
+	MaxIdleInterval time.Duration
+
+	// Output is where the Watcher logs. Defaults to os.Stdout.
+	Output io.Writer
+
+	// RunBD runs bd for the ready check. Nil means the bd CLI.
+	RunBD BDRunner
+
+	mu     sync.Mutex
+	failed map[string]map[string]bool // repo -> beads that failed in a pass
+	wake   []chan struct{}
+}
+
+// Watch watches the repos until ctx is done. In-flight passes are
+// cancelled with it.
+func (w *Watcher) Watch(ctx context.Context) error {
+	if len(w.Repos) == 0 {
+		return errors.New("no repositories to watch")
+	}
+	if w.NewCore == nil {
+		return errors.New("watcher has no NewCore")
+	}
+	out := w.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	out = &syncWriter{w: out}
+
+	maxConcurrency := w.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	slots := NewSlots(maxConcurrency)
+
+	w.mu.Lock()
+	w.failed = make(map[string]map[string]bool)
+	w.wake = make([]chan struct{}, len(w.Repos))
+	for i := range w.wake {
+		w.wake[i] = make(chan struct{}, 1)
+	}
+	w.mu.Unlock()
+
+	writef(out, "Watching %d repo(s), at most %d agent(s) at once\n", len(w.Repos), maxConcurrency)
+	var wg sync.WaitGroup
+	for i, repo := range w.Repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.watchRepo(ctx, repo, slots, w.wake[i], out)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Reload forgets the beads that failed in earlier passes and checks every
+// repo for ready beads right away. Call it after reloading configuration,
+// e.g. on SIGHUP.
+func (w *Watcher) Reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	clear(w.failed)
+	for _, ch := range w.wake {
+		select {
+		case ch <- struct{}{}:
+		default: // already pending
+		}
+	}
+}
+
+// watchRepo runs passes over repo whenever it has ready work, until ctx is
+// done.
+func (w *Watcher) watchRepo(ctx context.Context, repo string, slots *Slots, wake <-chan struct{}, out io.Writer) {
+	poll := w.PollInterval
+	if poll <= 0 {
+		poll = DefaultPollInterval
+	}
+	maxIdle := w.MaxIdleInterval
+	if maxIdle <= 0 {
+		maxIdle = DefaultMaxIdleInterval
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	interval := poll
+	var lastPass time.Time // zero: query right away
+	lastFingerprint := beadsFingerprint(repo)
+	for {
+		if fp := beadsFingerprint(repo); fp != lastFingerprint {
+			lastFingerprint = fp
+			interval = poll
+			lastPass = time.Time{}
+		}
+		if time.Since(lastPass) >= interval {
+			if w.pass(ctx, repo, slots, out) {
+				interval = poll
+			} else {
+				interval = min(interval*2, maxIdle)
+			}
+			lastPass = time.Now()
+			// Don't mistake the pass's own bd updates for new work.
+			lastFingerprint = beadsFingerprint(repo)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+			interval = poll
+			lastPass = time.Time{}
+		}
+	}
+}
+
+// pass runs a Core over repo if it has ready beads that have not failed in
+// an earlier pass, and reports whether it started any.
+func (w *Watcher) pass(ctx context.Context, repo string, slots *Slots, out io.Writer) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	ready, err := (&Core{WorkDir: repo, RunBD: w.RunBD}).readyBeads()
+	if err != nil {
+		writef(out, "[watch] %s: fetching ready beads: %v\n", repo, err)
+		return false
+	}
+	exclude := w.failedBeads(repo)
+	n := 0
+	for _, b := range ready {
+		if !exclude[b.ID] {
+			n++
+		}
+	}
+	if n == 0 {
+		return false
+	}
+
+	core, err := w.NewCore(repo)
+	if err != nil {
+		writef(out, "[watch] %s: %v\n", repo, err)
+		return false
+	}
+	if core.Journal != nil {
+		defer core.Journal.Close()
+	}
+	core.Slots = slots
+	core.Exclude = exclude
+	if core.Output == nil {
+		core.Output = out
+	}
+	obs := &failureObserver{ProgressObserver: core.Observer, failed: make(map[string]bool)}
+	if obs.ProgressObserver == nil {
+		obs.ProgressObserver = NoopObserver{}
+	}
+	core.Observer = obs
+
+	writef(out, "[watch] %s: %d ready bead(s)\n", repo, n)
+	summary, err := core.Run(ctx)
+	w.recordFailures(repo, obs.beads())
+	if err != nil {
+		writef(out, "[watch] %s: %v\n", repo, err)
+		return false
+	}
+	return summary.Iterations > 0
+}
+
+// failedBeads returns a copy of the beads of repo that failed in earlier
+// passes.
+func (w *Watcher) failedBeads(repo string) map[string]bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ids := make(map[string]bool, len(w.failed[repo]))
+	for id := range w.failed[repo] {
+		ids[id] = true
+	}
+	return ids
+}
+
+func (w *Watcher) recordFailures(repo string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failed[repo] == nil {
+		w.failed[repo] = make(map[string]bool)
+	}
+	for _, id := range ids {
+		w.failed[repo][id] = true
+	}
+}
+
+// failureObserver collects the beads that failed or timed out in a pass
+// and forwards every event to the Core's own observer.
+type failureObserver struct {
+	ProgressObserver
+	mu     sync.Mutex
+	failed map[string]bool
+}
+
+func (o *failureObserver) OnBeadComplete(r BeadResult) {
+	if r.Outcome == OutcomeFailure || r.Outcome == OutcomeTimeout {
+		o.mu.Lock()
+		o.failed[r.Bead.ID] = true
+		o.mu.Unlock()
+	}
+	o.ProgressObserver.OnBeadComplete(r)
+}
+
+func (o *failureObserver) OnToolStart(beadID string, call agent.ToolCall) {
+	if tools, ok := o.ProgressObserver.(ToolObserver); ok {
+		tools.OnToolStart(beadID, call)
+	}
+}
+
+func (o *failureObserver) OnToolEnd(beadID, callID string, attrs map[string]string) {
+	if tools, ok := o.ProgressObserver.(ToolObserver); ok {
+		tools.OnToolEnd(beadID, callID, attrs)
+	}
+}
+
+func (o *failureObserver) beads() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ids := make([]string, 0, len(o.failed))
+	for id := range o.failed {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// beadsFingerprint summarizes the size and modification time of the files
+// in a repo's .beads directory (the database, its WAL and the JSONL
+// export), so a change made through bd, by hand or by a git pull is
+// noticed without querying bd.
+func beadsFingerprint(repo string) string {
+	entries, err := os.ReadDir(filepath.Join(repo, ".beads"))
+	if err != nil {
+		return ""
+	}
+	var parts []string
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", e.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(parts)
+	return fmt.Sprint(parts)
+}
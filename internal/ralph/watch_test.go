@@ -0,0 +1,188 @@
+package ralph
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"devdeploy/internal/agent"
+)
+
+func TestWatcher_DispatchesAcrossRepos(t *testing.T) {
+	repoA, repoB := t.TempDir(), t.TempDir()
+	// a-2 waits on a question bead that a human answers later.
+	bds := map[string]*fakeReadyBD{
+		repoA: newFakeReadyBD([]string{"a-1", "a-2"}, map[string][]string{"a-2": {"a-question"}}),
+		repoB: newFakeReadyBD([]string{"b-1", "b-bad"}, nil),
+	}
+	runBD := func(dir string, args ...string) ([]byte, error) { return bds[dir].run(dir, args...) }
+
+	var running, maxRunning atomic.Int32
+	var mu sync.Mutex
+	runs := map[string]int{}
+	started := make(chan string, 10)
+
+	w := &Watcher{
+		Repos:           []string{repoA, repoB},
+		MaxConcurrency:  1,
+		PollInterval:    5 * time.Millisecond,
+		MaxIdleInterval: 20 * time.Millisecond,
+		Output:          io.Discard,
+		RunBD:           runBD,
+		NewCore: func(workDir string) (*Core, error) {
+			return &Core{
+				WorkDir:     workDir,
+				MaxParallel: 1,
+				RunBD:       runBD,
+				FetchPrompt: func(runBD BDRunner, workDir, beadID string) (*PromptData, error) {
+					return &PromptData{ID: beadID}, nil
+				},
+				Render: func(data *PromptData) (string, error) { return data.ID, nil },
+				Execute: func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+					n := running.Add(1)
+					defer running.Add(-1)
+					for {
+						m := maxRunning.Load()
+						if n <= m || maxRunning.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					mu.Lock()
+					runs[prompt]++
+					mu.Unlock()
+					started <- prompt
+					return &AgentResult{}, nil
+				},
+				AssessFn: func(workDir, beadID string, r *AgentResult) (Outcome, string) {
+					if beadID == "b-bad" {
+						return OutcomeFailure, "broken"
+					}
+					bds[workDir].close(beadID)
+					return OutcomeSuccess, ""
+				},
+			}, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watchDone := make(chan error, 1)
+	go func() { watchDone <- w.Watch(ctx) }()
+
+	waitFor := func(ids ...string) {
+		t.Helper()
+		want := map[string]bool{}
+		for _, id := range ids {
+			want[id] = true
+		}
+		for len(want) > 0 {
+			select {
+			case id := <-started:
+				delete(want, id)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %v", want)
+			}
+		}
+	}
+
+	waitFor("a-1", "b-1", "b-bad")
+	bds[repoA].close("a-question")
+	waitFor("a-2")
+
+	// Idle passes leave the failed bead alone until a reload.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	if runs["b-bad"] != 1 {
+		t.Errorf("b-bad ran %d times before reload, want 1", runs["b-bad"])
+	}
+	mu.Unlock()
+	w.Reload()
+	waitFor("b-bad")
+
+	cancel()
+	select {
+	case err := <-watchDone:
+		if err != nil {
+			t.Errorf("Watch: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not return after cancel")
+	}
+	if m := maxRunning.Load(); m != 1 {
+		t.Errorf("max concurrent agents = %d, want 1", m)
+	}
+	for _, id := range []string{"a-1", "a-2", "b-1"} {
+		if runs[id] != 1 {
+			t.Errorf("%s ran %d times, want 1", id, runs[id])
+		}
+	}
+}
+
+func TestWatcher_NoRepos(t *testing.T) {
+	w := &Watcher{NewCore: func(string) (*Core, error) { return &Core{}, nil }}
+	if err := w.Watch(context.Background()); err == nil {
+		t.Error("Watch without repos: want error")
+	}
+}
+
+func TestSlots(t *testing.T) {
+	s := NewSlots(2)
+	if got := s.tryAcquire(3); got != 2 {
+		t.Errorf("tryAcquire(3) = %d, want 2", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(ctx); err == nil {
+		t.Error("acquire with all slots taken: want context error")
+	}
+	s.release()
+	if err := s.acquire(context.Background()); err != nil {
+		t.Errorf("acquire after release: %v", err)
+	}
+	if s.InUse() != 2 {
+		t.Errorf("InUse = %d, want 2", s.InUse())
+	}
+}
+
+func TestBeadsFingerprint(t *testing.T) {
+	repo := t.TempDir()
+	if fp := beadsFingerprint(repo); fp != "" {
+		t.Errorf("fingerprint without .beads = %q, want empty", fp)
+	}
+	dir := filepath.Join(repo, ".beads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "issues.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := beadsFingerprint(repo)
+	if err := os.WriteFile(path, []byte("{}\n{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if after := beadsFingerprint(repo); after == before {
+		t.Errorf("fingerprint unchanged after writing %s: %q", path, after)
+	}
+}
+
+func TestFailureObserver_ForwardsToolEvents(t *testing.T) {
+	inner := &toolEventObserver{}
+	obs := &failureObserver{ProgressObserver: inner, failed: make(map[string]bool)}
+	obs.OnToolStart("b-1", agent.ToolCall{ID: "c1"})
+	obs.OnToolEnd("b-1", "c1", nil)
+	if want := []string{"start b-1 c1", "end b-1 c1"}; !reflect.DeepEqual(inner.events, want) {
+		t.Errorf("events = %v, want %v", inner.events, want)
+	}
+
+	// An observer without tool methods just doesn't see them.
+	obs = &failureObserver{ProgressObserver: NoopObserver{}, failed: make(map[string]bool)}
+	obs.OnToolStart("b-1", agent.ToolCall{ID: "c1"})
+	obs.OnToolEnd("b-1", "c1", nil)
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // WorktreeManager manages temporary git worktrees for concurrent agent execution.
@@ -16,6 +17,14 @@ type WorktreeManager struct {
 	srcRepo string
 	// branch is the branch name to use for worktrees
 	branch string
+	// mu serializes worktree add/remove: concurrent git worktree commands
+	// can read each other's half-written admin files and fail.
+	mu sync.Mutex
+	// poolSize is how many worktrees are kept for reuse (see SetPoolSize);
+	// zero adds and removes a worktree per bead.
+	poolSize int
+	// poolBusy holds the pool slots this manager has handed out.
+	poolBusy map[int]bool
 }
 
 // NewWorktreeManager creates a new worktree manager for the given workdir.
@@ -87,51 +96,68 @@ func (w *WorktreeManager) Branch() string {
 	return w.branch
 }
 
-// CreateWorktree creates a temporary worktree for the given bead ID.
+// CreateWorktree creates a temporary worktree for the given bead ID, or
+// checks the bead's branch out in a pooled one (see SetPoolSize). The
+// branch always starts from the target branch; one left by an earlier
+// attempt is deleted first (see dropBeadBranch).
 // Returns the path to the worktree directory and the branch name used.
 func (w *WorktreeManager) CreateWorktree(beadID string) (worktreePath string, branchName string, err error) {
+	if w.pooling() {
+		return w.acquirePooled(beadID)
+	}
+
 	// Create worktree in /tmp/ralph-<bead-id>
 	worktreePath = filepath.Join(os.TempDir(), fmt.Sprintf("ralph-%s", beadID))
 	// Create a unique branch name for this worktree
 	branchName = fmt.Sprintf("ralph/%s", beadID)
 
-	// Empty dir for core.hooksPath to disable hooks
-	emptyHooksDir, err := os.MkdirTemp("", "devdeploy-nohooks")
+	gitNoHooks, cleanup, err := noHooksArgs(w.srcRepo)
 	if err != nil {
-		return "", "", fmt.Errorf("create temp hooks dir: %w", err)
+		return "", "", err
 	}
-	defer func() { _ = os.RemoveAll(emptyHooksDir) }()
-	gitNoHooks := []string{"-C", w.srcRepo, "-c", "core.hooksPath=" + emptyHooksDir}
+	defer cleanup()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	// Check if branch already exists
+	if err := w.dropBeadBranch(branchName); err != nil {
+		return "", "", fmt.Errorf("creating worktree for %s: %w", beadID, err)
+	}
 	var addStderr strings.Builder
-	if err := exec.Command("git", "-C", w.srcRepo, "rev-parse", "--verify", branchName).Run(); err != nil {
-		// Branch doesn't exist: create it when adding worktree
-		addCmd := exec.Command("git", append(gitNoHooks, "worktree", "add", "-b", branchName, worktreePath, w.branch)...)
-		addCmd.Stderr = &addStderr
-		if err := addCmd.Run(); err != nil {
-			msg := strings.TrimSpace(addStderr.String())
-			if msg != "" {
-				return "", "", fmt.Errorf("creating worktree for %s: %s: %w", beadID, msg, err)
-			}
-			return "", "", fmt.Errorf("creating worktree for %s: %w", beadID, err)
-		}
-	} else {
-		// Branch exists: add worktree to existing branch
-		addCmd := exec.Command("git", append(gitNoHooks, "worktree", "add", worktreePath, branchName)...)
-		addCmd.Stderr = &addStderr
-		if err := addCmd.Run(); err != nil {
-			msg := strings.TrimSpace(addStderr.String())
-			if msg != "" {
-				return "", "", fmt.Errorf("creating worktree for %s: %s: %w", beadID, msg, err)
-			}
-			return "", "", fmt.Errorf("creating worktree for %s: %w", beadID, err)
+	addCmd := exec.Command("git", append(gitNoHooks, "worktree", "add", "-b", branchName, worktreePath, w.branch)...)
+	addCmd.Stderr = &addStderr
+	if err := addCmd.Run(); err != nil {
+		msg := strings.TrimSpace(addStderr.String())
+		if msg != "" {
+			return "", "", fmt.Errorf("creating worktree for %s: %s: %w", beadID, msg, err)
 		}
+		return "", "", fmt.Errorf("creating worktree for %s: %w", beadID, err)
 	}
 
 	return worktreePath, branchName, nil
 }
 
+// dropBeadBranch deletes a bead branch left by an earlier attempt or run,
+// so the bead starts over from the target branch: the old commits failed,
+// were rejected in review or had their merge reverted, and building on
+// them would hide them from the retry's diff and the next merge.
+// Deleting rather than resetting the branch also restarts its reflog,
+// where branchChanges finds the branch's base.
+//
+// Until then other beads may point at the branch: a merge conflict
+// question bead names it, and blocks the bead, so the branch survives
+// until a human has answered it. A pull request's branch was pushed and
+// stays on the remote; a new attempt at its bead force-pushes over it.
+func (w *WorktreeManager) dropBeadBranch(branchName string) error {
+	if exec.Command("git", "-C", w.srcRepo, "rev-parse", "--verify", "--quiet", "refs/heads/"+branchName).Run() != nil {
+		return nil
+	}
+	if out, err := exec.Command("git", "-C", w.srcRepo, "branch", "-D", branchName).CombinedOutput(); err != nil {
+		return fmt.Errorf("deleting %s of an earlier attempt: %s: %w", branchName, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
 // FindWorktreeForBranch finds the worktree that has the given branch checked out.
 // Returns the worktree path, or empty string if not found.
 // Searches from the source repository.
@@ -178,11 +204,18 @@ func (w *WorktreeManager) MergeRepo(targetBranch string) string {
 	return w.srcRepo
 }
 
-// RemoveWorktree removes a worktree created by CreateWorktree.
-// The associated branch (ralph/<beadID>) is preserved, as it may have been
-// pushed to remote or referenced elsewhere. To clean up branches, use
-// 'git branch -D <branchName>' separately.
+// RemoveWorktree removes a worktree created by CreateWorktree, or returns
+// a pooled one to the pool.
+// The associated branch (ralph/<beadID>) is kept until the bead's next
+// attempt, which discards it (see dropBeadBranch).
 func (w *WorktreeManager) RemoveWorktree(worktreePath string) error {
+	if n, ok := w.poolSlot(worktreePath); ok {
+		return w.releasePooled(n, worktreePath)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	// Remove the worktree
 	cmd := exec.Command("git", "-C", w.srcRepo, "worktree", "remove", worktreePath, "--force")
 	var stderr strings.Builder
@@ -199,9 +232,5 @@ func (w *WorktreeManager) RemoveWorktree(worktreePath string) error {
 		return fmt.Errorf("removing worktree %s: %w", worktreePath, err)
 	}
 
-	// Note: Branch is intentionally preserved after worktree removal.
-	// The branch may have been pushed to remote or referenced elsewhere.
-	// To delete the branch, use: git branch -D <branchName>
-
 	return nil
 }
@@ -0,0 +1,300 @@
+package ralph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// poolWorktreePrefix starts the directory names of pooled worktrees,
+// <temp>/ralph-pool-<repo key>-<slot>.
+const poolWorktreePrefix = "ralph-pool-"
+
+// poolLockReason is the `git worktree lock` reason of a pooled worktree in
+// use; the pid tells a later run whether its owner is still alive.
+const poolLockReason = "ralph pid %d"
+
+// worktreeInfo is an entry of `git worktree list --porcelain`.
+type worktreeInfo struct {
+	Path       string
+	Locked     bool
+	LockReason string
+}
+
+// listWorktrees lists the worktrees of repo.
+func listWorktrees(repo string) ([]worktreeInfo, error) {
+	out, err := exec.Command("git", "-C", repo, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing worktrees: %w", err)
+	}
+	var list []worktreeInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			list = append(list, worktreeInfo{Path: strings.TrimPrefix(line, "worktree ")})
+		case len(list) == 0:
+		case line == "locked" || strings.HasPrefix(line, "locked "):
+			list[len(list)-1].Locked = true
+			list[len(list)-1].LockReason = strings.TrimPrefix(line, "locked ")
+		}
+	}
+	return list, nil
+}
+
+// SetPoolSize makes CreateWorktree and RemoveWorktree reuse up to n
+// worktrees instead of adding and removing one per bead; n <= 0 turns
+// pooling off. A pooled worktree is checked out on the bead's branch,
+// created afresh from the target branch, when handed out and detached at
+// the target branch when returned, both with `git clean -fd`, so ignored
+// build output survives from bead to bead.
+// Beads beyond n (e.g. while others wait to merge) still get a worktree,
+// which is removed when returned. A pooled worktree in use is locked with
+// `git worktree lock`, naming the process, so PrunePool in a later run can
+// tell it from one a dead run left behind.
+func (w *WorktreeManager) SetPoolSize(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.poolSize = max(n, 0)
+}
+
+func (w *WorktreeManager) pooling() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.poolSize > 0
+}
+
+// poolKey tells apart the pools of repositories sharing the temp dir.
+func (w *WorktreeManager) poolKey() string {
+	sum := sha256.Sum256([]byte(w.srcRepo))
+	return hex.EncodeToString(sum[:4])
+}
+
+// poolPath is the directory of pool slot n.
+func (w *WorktreeManager) poolPath(n int) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s%s-%d", poolWorktreePrefix, w.poolKey(), n))
+}
+
+// poolSlot returns the pool slot of path, if it is one of w's pooled
+// worktrees.
+func (w *WorktreeManager) poolSlot(path string) (int, bool) {
+	rest, ok := strings.CutPrefix(filepath.Base(path), poolWorktreePrefix+w.poolKey()+"-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	return n, err == nil && n >= 0
+}
+
+// acquirePooled checks out ralph/<beadID> in a free pooled worktree,
+// adding one when all are busy. A reused worktree that cannot be reset is
+// replaced by a fresh one.
+func (w *WorktreeManager) acquirePooled(beadID string) (string, string, error) {
+	branchName := fmt.Sprintf("ralph/%s", beadID)
+	for {
+		w.mu.Lock()
+		n, reused, err := w.reserveSlotLocked()
+		w.mu.Unlock()
+		if err != nil {
+			return "", "", fmt.Errorf("creating worktree for %s: %w", beadID, err)
+		}
+		path := w.poolPath(n)
+		err = w.checkoutPooled(path, branchName)
+		if err == nil {
+			return path, branchName, nil
+		}
+		_ = w.removePooled(n, path)
+		if !reused {
+			return "", "", fmt.Errorf("creating worktree for %s: %w", beadID, err)
+		}
+	}
+}
+
+// reserveSlotLocked takes the lowest pool slot that neither this manager
+// nor another ralph process is using, adding its worktree if there is
+// none, and locks it. It reports whether the worktree already existed.
+// w.mu must be held.
+func (w *WorktreeManager) reserveSlotLocked() (int, bool, error) {
+	list, err := listWorktrees(w.srcRepo)
+	if err != nil {
+		return 0, false, err
+	}
+	registered := make(map[int]worktreeInfo)
+	for _, wt := range list {
+		if n, ok := w.poolSlot(wt.Path); ok {
+			registered[n] = wt
+		}
+	}
+	if w.poolBusy == nil {
+		w.poolBusy = make(map[int]bool)
+	}
+	reason := fmt.Sprintf(poolLockReason, os.Getpid())
+	for n := 0; ; n++ {
+		wt, exists := registered[n]
+		if w.poolBusy[n] || wt.Locked {
+			continue
+		}
+		path := w.poolPath(n)
+		if exists {
+			// Fails if another process locked it since the listing.
+			if err := exec.Command("git", "-C", w.srcRepo, "worktree", "lock", "--reason", reason, wt.Path).Run(); err != nil {
+				continue
+			}
+			w.poolBusy[n] = true
+			return n, true, nil
+		}
+
+		_ = os.RemoveAll(path) // left over from a worktree git no longer knows
+		gitNoHooks, cleanup, err := noHooksArgs(w.srcRepo)
+		if err != nil {
+			return 0, false, err
+		}
+		out, err := exec.Command("git", append(gitNoHooks, "worktree", "add", "--detach", "--lock", "--reason", reason, path, w.branch)...).CombinedOutput()
+		cleanup()
+		if err != nil {
+			return 0, false, fmt.Errorf("adding pooled worktree %s: %s: %w", path, strings.TrimSpace(string(out)), err)
+		}
+		w.poolBusy[n] = true
+		return n, false, nil
+	}
+}
+
+// checkoutPooled checks out branchName in the pooled worktree at path,
+// (re)created from the target branch like CreateWorktree does, and deletes
+// the untracked files the last bead left behind.
+func (w *WorktreeManager) checkoutPooled(path, branchName string) error {
+	if err := w.dropBeadBranch(branchName); err != nil {
+		return err
+	}
+	return resetPooled(path, "checkout", "--force", "-b", branchName, w.branch)
+}
+
+// resetPooled runs a hook-less `git checkout` with args in the pooled
+// worktree at path, then `git clean -fd` and `git reset --hard`.
+func resetPooled(path string, checkoutArgs ...string) error {
+	gitNoHooks, cleanup, err := noHooksArgs(path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	for _, args := range [][]string{checkoutArgs, {"clean", "-fd"}, {"reset", "--hard", "--quiet"}} {
+		if out, err := exec.Command("git", append(gitNoHooks, args...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s in %s: %s: %w", args[0], path, strings.TrimSpace(string(out)), err)
+		}
+	}
+	return nil
+}
+
+// releasePooled returns pool slot n: it is detached at the target branch,
+// freeing the bead's branch, cleaned and unlocked. Slots beyond the pool
+// size, and ones that fail to reset, are removed. Slots this manager did
+// not hand out are left alone; they may be another process's by now.
+func (w *WorktreeManager) releasePooled(n int, path string) error {
+	w.mu.Lock()
+	busy, keep := w.poolBusy[n], n < w.poolSize
+	w.mu.Unlock()
+	if !busy {
+		return nil
+	}
+	if keep && resetPooled(path, "checkout", "--force", "--detach", w.branch) == nil &&
+		exec.Command("git", "-C", w.srcRepo, "worktree", "unlock", path).Run() == nil {
+		w.mu.Lock()
+		delete(w.poolBusy, n)
+		w.mu.Unlock()
+		return nil
+	}
+	return w.removePooled(n, path)
+}
+
+// removePooled removes the worktree of pool slot n, locked or not.
+func (w *WorktreeManager) removePooled(n int, path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.poolBusy, n)
+	out, err := exec.Command("git", "-C", w.srcRepo, "worktree", "remove", "--force", "--force", path).CombinedOutput()
+	if err != nil && !strings.Contains(string(out), "is not a working tree") {
+		return fmt.Errorf("removing pooled worktree %s: %s: %w", path, strings.TrimSpace(string(out)), err)
+	}
+	return os.RemoveAll(path)
+}
+
+// PrunePool garbage-collects the pooled worktrees earlier runs left
+// behind. Ones locked by a ralph process that is still running are left
+// alone. Of the rest, slots within the pool size are detached, cleaned
+// and unlocked for reuse and the others removed, as are pool directories
+// git no longer knows. Call it at startup, after SetPoolSize.
+func (w *WorktreeManager) PrunePool() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Forget worktrees whose directories are gone, e.g. after a reboot
+	// cleared the temp dir.
+	_ = exec.Command("git", "-C", w.srcRepo, "worktree", "prune").Run()
+	list, err := listWorktrees(w.srcRepo)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	known := make(map[int]bool)
+	for _, wt := range list {
+		n, ok := w.poolSlot(wt.Path)
+		if !ok || w.poolBusy[n] {
+			continue
+		}
+		known[n] = true
+		if wt.Locked {
+			var pid int
+			if _, err := fmt.Sscanf(wt.LockReason, poolLockReason, &pid); err != nil {
+				continue // locked by hand
+			}
+			if pid != os.Getpid() && processAlive(pid) {
+				continue
+			}
+		}
+		if n < w.poolSize && resetPooled(wt.Path, "checkout", "--force", "--detach", w.branch) == nil {
+			if !wt.Locked || exec.Command("git", "-C", w.srcRepo, "worktree", "unlock", wt.Path).Run() == nil {
+				continue
+			}
+		}
+		out, err := exec.Command("git", "-C", w.srcRepo, "worktree", "remove", "--force", "--force", wt.Path).CombinedOutput()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("removing pooled worktree %s: %s: %w", wt.Path, strings.TrimSpace(string(out)), err))
+		}
+	}
+
+	strays, _ := filepath.Glob(filepath.Join(os.TempDir(), poolWorktreePrefix+w.poolKey()+"-*"))
+	for _, path := range strays {
+		if n, ok := w.poolSlot(path); ok && !known[n] && !w.poolBusy[n] {
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// processAlive reports whether a process with the given pid exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// noHooksArgs returns the git arguments to run git in dir with hooks
+// disabled, and a function deleting the empty hooks directory they use.
+func noHooksArgs(dir string) ([]string, func(), error) {
+	emptyHooksDir, err := os.MkdirTemp("", "devdeploy-nohooks")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create temp hooks dir: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(emptyHooksDir) }
+	return []string{"-C", dir, "-c", "core.hooksPath=" + emptyHooksDir}, cleanup, nil
+}
@@ -0,0 +1,215 @@
+package ralph
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newPoolTestManager returns a worktree manager with a pool of size for a
+// fresh repository whose .gitignore ignores build/. Pooled worktrees go to
+// a temp dir of the test's own.
+func newPoolTestManager(t *testing.T, size int) (*WorktreeManager, string) {
+	t.Helper()
+	t.Setenv("TMPDIR", t.TempDir())
+	repo := setupTestGitRepo(t)
+	target, err := getCurrentBranch(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createBranch(t, repo, target, ".gitignore", "build/\n")
+	wtMgr, err := NewWorktreeManager(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wtMgr.SetPoolSize(size)
+	return wtMgr, repo
+}
+
+func TestWorktreePool_Reuse(t *testing.T) {
+	wtMgr, repo := newPoolTestManager(t, 1)
+
+	path, branch, err := wtMgr.CreateWorktree("b-1")
+	if err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	if branch != "ralph/b-1" || gitOutput(t, path, "rev-parse", "--abbrev-ref", "HEAD") != branch {
+		t.Fatalf("worktree %s is not on %s", path, branch)
+	}
+	if err := commitFile(path, "one.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.MkdirAll(filepath.Join(path, "build"), 0755)
+	_ = os.WriteFile(filepath.Join(path, "build", "cache"), []byte("cached\n"), 0644)
+	_ = os.WriteFile(filepath.Join(path, "scratch.txt"), []byte("left behind\n"), 0644)
+
+	// Another bead while b-1 holds the pool's only worktree gets one of
+	// its own, which is removed when returned.
+	extra, _, err := wtMgr.CreateWorktree("b-2")
+	if err != nil {
+		t.Fatalf("CreateWorktree while the pool is busy: %v", err)
+	}
+	if extra == path {
+		t.Fatal("busy pooled worktree handed out twice")
+	}
+	if err := wtMgr.RemoveWorktree(extra); err != nil {
+		t.Fatalf("RemoveWorktree(%s): %v", extra, err)
+	}
+	if _, err := os.Stat(extra); !os.IsNotExist(err) {
+		t.Errorf("worktree %s beyond the pool size was kept", extra)
+	}
+
+	if err := wtMgr.RemoveWorktree(path); err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	reused, _, err := wtMgr.CreateWorktree("b-3")
+	if err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	if reused != path {
+		t.Fatalf("CreateWorktree = %s, want the pooled %s", reused, path)
+	}
+	if got, want := gitOutput(t, path, "rev-parse", "HEAD"), gitOutput(t, repo, "rev-parse", "HEAD"); got != want {
+		t.Errorf("reused worktree at %s, want the target branch's %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(path, "scratch.txt")); !os.IsNotExist(err) {
+		t.Error("untracked file of the last bead survived")
+	}
+	if _, err := os.Stat(filepath.Join(path, "one.txt")); !os.IsNotExist(err) {
+		t.Error("the last bead's commit is checked out")
+	}
+	if _, err := os.Stat(filepath.Join(path, "build", "cache")); err != nil {
+		t.Errorf("ignored build output was cleaned: %v", err)
+	}
+	if got := gitOutput(t, repo, "log", "-1", "--format=%s", "ralph/b-1"); got != "add one.txt" {
+		t.Errorf("ralph/b-1 = %q, want the bead's commit kept", got)
+	}
+}
+
+func TestWorktreePool_RetryStartsFromTarget(t *testing.T) {
+	wtMgr, repo := newPoolTestManager(t, 1)
+	path, _, err := wtMgr.CreateWorktree("b-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := commitFile(path, "failed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wtMgr.RemoveWorktree(path); err != nil {
+		t.Fatal(err)
+	}
+	// The target moves on while the bead waits for its retry.
+	if err := commitFile(repo, "target.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := wtMgr.CreateWorktree("b-1"); err != nil {
+		t.Fatalf("CreateWorktree for the retry: %v", err)
+	}
+	if got, want := gitOutput(t, path, "rev-parse", "HEAD"), gitOutput(t, repo, "rev-parse", "HEAD"); got != want {
+		t.Errorf("retry's worktree at %s, want the target's %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(path, "failed.txt")); !os.IsNotExist(err) {
+		t.Error("retry inherited the failed attempt's commit")
+	}
+}
+
+func TestWorktreeManager_PrunePool(t *testing.T) {
+	old, repo := newPoolTestManager(t, 3)
+	var paths []string
+	for _, id := range []string{"b-1", "b-2", "b-3"} {
+		path, _, err := old.CreateWorktree(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	// A live process still uses the third; the first two belong to a
+	// run that died without returning them.
+	gitOutput(t, repo, "worktree", "unlock", paths[2])
+	gitOutput(t, repo, "worktree", "lock", "--reason", fmt.Sprintf(poolLockReason, os.Getppid()), paths[2])
+	stray := old.poolPath(7)
+	_ = os.MkdirAll(stray, 0755)
+
+	wtMgr, err := NewWorktreeManager(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wtMgr.SetPoolSize(1)
+	if err := wtMgr.PrunePool(); err != nil {
+		t.Fatalf("PrunePool: %v", err)
+	}
+
+	list := gitOutput(t, repo, "worktree", "list", "--porcelain")
+	if !strings.Contains(list, "worktree "+paths[0]+"\n") || strings.Contains(list, "worktree "+paths[1]+"\n") {
+		t.Errorf("want only slot 0 of the dead run kept:\n%s", list)
+	}
+	if !strings.Contains(list, fmt.Sprintf("locked "+poolLockReason, os.Getppid())) {
+		t.Errorf("worktree of a live process was touched:\n%s", list)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Error("stray pool directory was not removed")
+	}
+	if got := gitOutput(t, paths[0], "rev-parse", "--abbrev-ref", "HEAD"); got != "HEAD" {
+		t.Errorf("kept worktree still on %s, want it detached", got)
+	}
+
+	// The kept worktree is free for this run; the live one is skipped.
+	path, _, err := wtMgr.CreateWorktree("b-1")
+	if err != nil {
+		t.Fatalf("CreateWorktree after pruning: %v", err)
+	}
+	if path != paths[0] {
+		t.Errorf("CreateWorktree = %s, want the kept %s", path, paths[0])
+	}
+}
+
+func TestCore_Run_WorktreePool(t *testing.T) {
+	// Each bead depends on the one before, so it starts once that one has
+	// merged and returned the pool's worktree.
+	bd := newFakeReadyBD([]string{"a", "b", "c"}, map[string][]string{"b": {"a"}, "c": {"b"}})
+	c := newSchedulerTestCore(t, bd)
+	t.Setenv("TMPDIR", t.TempDir())
+	c.WorktreePool = true
+
+	var mu sync.Mutex
+	workDirs := make(map[string]bool)
+	c.Execute = func(ctx context.Context, workDir, prompt string) (*AgentResult, error) {
+		mu.Lock()
+		workDirs[workDir] = true
+		mu.Unlock()
+		for _, dep := range bd.deps[prompt] {
+			if _, err := os.Stat(filepath.Join(workDir, dep+".txt")); err != nil {
+				return &AgentResult{ExitCode: 1}, nil
+			}
+		}
+		return &AgentResult{}, commitFile(workDir, prompt+".txt")
+	}
+	c.AssessFn = func(workDir, beadID string, result *AgentResult) (Outcome, string) {
+		if result.ExitCode != 0 {
+			return OutcomeFailure, "worktree does not have the merged dependency"
+		}
+		bd.close(beadID)
+		return OutcomeSuccess, ""
+	}
+
+	result, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Succeeded != 3 {
+		t.Fatalf("succeeded = %d, want 3:\n%s", result.Succeeded, c.Output)
+	}
+	if len(workDirs) != 1 {
+		t.Errorf("beads ran in %d worktrees, want the one pooled worktree reused: %v", len(workDirs), workDirs)
+	}
+	for dir := range workDirs {
+		if got := gitOutput(t, dir, "rev-parse", "--abbrev-ref", "HEAD"); got != "HEAD" {
+			t.Errorf("pooled worktree left on %s", got)
+		}
+	}
+}
@@ -272,6 +272,37 @@ func (m *Manager) GetTrace(id string) *Trace {
 	return m.traces[id]
 }
 
+// Snapshot returns a deep copy of a trace by ID, safe to read while the
+// manager keeps handling events. Returns nil if the trace is unknown.
+func (m *Manager) Snapshot(id string) *Trace {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.traces[id]
+	if !ok {
+		return nil
+	}
+	cp := *t
+	cp.RootSpan = copySpan(t.RootSpan)
+	return &cp
+}
+
+// copySpan deep-copies a span tree
+func copySpan(s *Span) *Span {
+	if s == nil {
+		return nil
+	}
+	cp := *s
+	cp.Attributes = make(map[string]string, len(s.Attributes))
+	for k, v := range s.Attributes {
+		cp.Attributes[k] = v
+	}
+	cp.Children = make([]*Span, len(s.Children))
+	for i, child := range s.Children {
+		cp.Children[i] = copySpan(child)
+	}
+	return &cp
+}
+
 // GetActiveTrace returns the currently running trace (if any)
 func (m *Manager) GetActiveTrace() *Trace {
 	m.mu.RLock()
@@ -561,3 +561,31 @@ func TestConcurrentAccess_Safe(t *testing.T) {
 		t.Errorf("ConcurrentAccess: expected at most %d traces, got %d", m.maxTraces, len(recent))
 	}
 }
+
+func TestSnapshot_IsDeepCopy(t *testing.T) {
+	m := NewManager(10)
+	traceID := NewTraceID()
+	loopID := NewSpanID()
+	iterID := NewSpanID()
+	now := time.Now()
+
+	m.HandleEvent(TraceEvent{TraceID: traceID, SpanID: loopID, Type: EventLoopStart, Name: "loop", Timestamp: now})
+	m.HandleEvent(TraceEvent{TraceID: traceID, SpanID: iterID, ParentID: loopID, Type: EventIterationStart, Name: "iter", Timestamp: now})
+
+	snap := m.Snapshot(traceID)
+	if snap == nil || snap.RootSpan == nil || len(snap.RootSpan.Children) != 1 {
+		t.Fatalf("Snapshot: expected loop span with one child, got %+v", snap)
+	}
+
+	// Later events must not show up in the snapshot.
+	m.HandleEvent(TraceEvent{TraceID: traceID, SpanID: NewSpanID(), ParentID: iterID, Type: EventToolStart, Name: "read", Timestamp: now})
+	m.HandleEvent(TraceEvent{TraceID: traceID, SpanID: iterID, Type: EventIterationEnd, Timestamp: now.Add(time.Second), Attributes: map[string]string{"outcome": "success"}})
+
+	iter := snap.RootSpan.Children[0]
+	if len(iter.Children) != 0 || iter.Duration != 0 || iter.Attributes["outcome"] != "" {
+		t.Errorf("Snapshot changed after later events: %+v", iter)
+	}
+	if m.Snapshot("nonexistent") != nil {
+		t.Error("Snapshot(nonexistent): expected nil")
+	}
+}
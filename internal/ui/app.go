@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"devdeploy/internal/agent"
+	"devdeploy/internal/config"
 	"devdeploy/internal/progress"
 	"devdeploy/internal/project"
 	"devdeploy/internal/session"
@@ -22,6 +23,7 @@ type AppModel struct {
 	Detail          *ProjectDetailView
 	KeyHandler      *KeyHandler
 	ProjectManager  *project.Manager
+	Config          *config.Config // global devdeploy config; nil means built-in defaults
 	AgentRunner     agent.Runner
 	Sessions        *session.Tracker // tracks panes across all resources; persists across project switches
 	Overlays        OverlayStack
@@ -78,6 +80,8 @@ func (a *appModelAdapter) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a.handleProjectDetailBeadsLoaded(msg)
 	case ResourceBeadsLoadedMsg:
 		return a.handleResourceBeadsLoaded(msg)
+	case RalphRunsLoadedMsg:
+		return a.handleRalphRunsLoaded(msg)
 	case RefreshBeadsMsg:
 		return a.handleRefreshBeads()
 	case CreateProjectMsg:
@@ -207,6 +211,13 @@ func (a *appModelAdapter) View() string {
 		}
 	}
 
+	// Add ralph summary
+	if a.Mode == ModeProjectDetail && a.Detail != nil {
+		if summary := a.Detail.RalphSummary(); summary != "" {
+			mainView += "\n" + Styles.Muted.Render(summary)
+		}
+	}
+
 	// Add status
 	if a.Status != "" {
 		style := Styles.Status
@@ -291,7 +302,10 @@ func (a *AppModel) newProjectDetailView(name string) (*ProjectDetailView, tea.Cm
 	}
 	// Return command to trigger async enrichment (PRs, then beads)
 	if a.ProjectManager != nil {
-		cmds = append(cmds, loadProjectPRsCmd(a.ProjectManager, name))
+		cmds = append(cmds,
+			loadProjectPRsCmd(a.ProjectManager, name),
+			loadRalphRunsCmd(name, v.Resources),
+		)
 		return v, tea.Batch(cmds...)
 	}
 	if len(cmds) > 0 {
@@ -468,15 +482,25 @@ func resourceKeyFromResource(r project.Resource) string {
 	return session.ResourceKey("repo", r.RepoName, 0)
 }
 
+// globalConfig returns the global config, or built-in defaults when unset.
+func (a *AppModel) globalConfig() *config.Config {
+	if a.Config == nil {
+		return config.Default()
+	}
+	return a.Config
+}
+
 // AppModelOption configures NewAppModel
 type AppModelOption func(*AppModel)
 
+// WithConfig sets the global devdeploy config used by the app and its
+// project manager.
+func WithConfig(cfg *config.Config) AppModelOption {
+	return func(m *AppModel) { m.Config = cfg }
+}
+
 // NewAppModel creates the root application model.
 func NewAppModel(opts ...AppModelOption) *AppModel {
-	projMgr := (*project.Manager)(nil)
-	if base, err := project.ResolveProjectsBase(); err == nil {
-		projMgr = project.NewManager(base, "")
-	}
 	reg := NewKeybindRegistry()
 	reg.BindWithDesc("q", tea.Quit, "Quit")
 	reg.BindWithDesc("ctrl+c", tea.Quit, "Quit")
@@ -505,13 +529,12 @@ func NewAppModel(opts ...AppModelOption) *AppModel {
 		)
 	}
 	model := &AppModel{
-		Mode:           ModeDashboard,
-		Dashboard:      NewDashboardView(),
-		Detail:         nil,
-		KeyHandler:     NewKeyHandler(reg),
-		ProjectManager: projMgr,
-		AgentRunner:    &agent.StubRunner{},
-		Sessions:       session.New(tmux.ListPaneIDs),
+		Mode:        ModeDashboard,
+		Dashboard:   NewDashboardView(),
+		Detail:      nil,
+		KeyHandler:  NewKeyHandler(reg),
+		AgentRunner: &agent.StubRunner{},
+		Sessions:    session.New(tmux.ListPaneIDs),
 	}
 
 	// Apply options
@@ -519,6 +542,13 @@ func NewAppModel(opts ...AppModelOption) *AppModel {
 		opt(model)
 	}
 
+	// The project manager is built after options so it sees the global config.
+	if model.ProjectManager == nil {
+		if base, err := project.ResolveProjectsBase(); err == nil {
+			model.ProjectManager = project.NewManager(base, "", project.WithGlobalConfig(model.globalConfig()))
+		}
+	}
+
 	return model
 }
 
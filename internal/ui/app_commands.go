@@ -164,6 +164,39 @@ func loadResourceBeadsCmd(projectName string, resources []project.Resource) tea.
 	}
 }
 
+// loadRalphRunsCmd returns a command that discovers the ralph run of each
+// resource's worktree, through its control socket or else its journal,
+// in parallel, and returns RalphRunsLoadedMsg.
+func loadRalphRunsCmd(projectName string, resources []project.Resource) tea.Cmd {
+	return func() tea.Msg {
+		runs := make(map[string]*RalphRun)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+
+		for _, r := range resources {
+			if r.WorktreePath == "" {
+				continue
+			}
+			wg.Add(1)
+			go func(workDir string) {
+				defer wg.Done()
+				run := discoverRalphRun(workDir)
+				if run == nil {
+					return
+				}
+				mu.Lock()
+				runs[workDir] = run
+				mu.Unlock()
+			}(r.WorktreePath)
+		}
+
+		wg.Wait()
+
+		return RalphRunsLoadedMsg{ProjectName: projectName, Runs: runs}
+	}
+}
+
 // tickCmd returns a command that schedules a tickMsg after 5 seconds.
 // Used for periodic refresh of panes and beads in project detail view.
 func tickCmd() tea.Cmd {
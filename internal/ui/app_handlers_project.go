@@ -281,6 +281,15 @@ func (a *appModelAdapter) handleResourceBeadsLoaded(msg ResourceBeadsLoadedMsg)
 	return a, nil
 }
 
+// handleRalphRunsLoaded handles RalphRunsLoadedMsg by updating the ralph
+// markers of the detail view.
+func (a *appModelAdapter) handleRalphRunsLoaded(msg RalphRunsLoadedMsg) (tea.Model, tea.Cmd) {
+	if a.Mode == ModeProjectDetail && a.Detail != nil && a.Detail.ProjectName == msg.ProjectName {
+		a.Detail.ralphRuns = msg.Runs
+	}
+	return a, nil
+}
+
 // handleRefreshBeads handles RefreshBeadsMsg by refreshing beads for all resources.
 func (a *appModelAdapter) handleRefreshBeads() (tea.Model, tea.Cmd) {
 	// Refresh beads for all resources in project detail view
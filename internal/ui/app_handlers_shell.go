@@ -5,7 +5,9 @@ import (
 	"os/exec"
 	"strings"
 
+	"devdeploy/internal/agent"
 	"devdeploy/internal/progress"
+	"devdeploy/internal/project"
 	"devdeploy/internal/session"
 	"devdeploy/internal/tmux"
 
@@ -54,6 +56,22 @@ func (a *appModelAdapter) handleLaunchAgent() (tea.Model, tea.Cmd) {
 		a.StatusIsError = true
 		return a, nil
 	}
+	cfg, err := a.projectConfig()
+	if err != nil {
+		a.Status = fmt.Sprintf("Launch agent: %v", err)
+		a.StatusIsError = true
+		return a, nil
+	}
+	backend, err := agent.FromConfig(a.globalConfig().Agent)
+	if err != nil {
+		a.Status = fmt.Sprintf("Launch agent: %v", err)
+		a.StatusIsError = true
+		return a, nil
+	}
+	model := cfg.AgentModel
+	if model == "" {
+		model = a.globalConfig().Agent.Model
+	}
 	workDir, err := a.ensureResourceWorktree(r)
 	if err != nil {
 		a.Status = fmt.Sprintf("Launch agent: %v", err)
@@ -66,7 +84,7 @@ func (a *appModelAdapter) handleLaunchAgent() (tea.Model, tea.Cmd) {
 		a.StatusIsError = true
 		return a, nil
 	}
-	if err := tmux.SendKeys(paneID, "agent --model claude-4.5-opus-high-thinking --force\n"); err != nil {
+	if err := tmux.SendKeys(paneID, agentCommand(backend, model, cfg.Environ(), "")); err != nil {
 		a.Status = fmt.Sprintf("Send agent command: %v", err)
 		a.StatusIsError = true
 		return a, nil
@@ -79,6 +97,29 @@ func (a *appModelAdapter) handleLaunchAgent() (tea.Model, tea.Cmd) {
 	return a, nil
 }
 
+// agentCommand builds the shell line that starts an interactive agent
+// with the given backend, prefixed with extra KEY=value env vars.
+// prompt may be empty.
+func agentCommand(backend agent.Backend, model string, env []string, prompt string) string {
+	var b strings.Builder
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		fmt.Fprintf(&b, "%s='%s' ", k, strings.ReplaceAll(v, "'", `'\''`))
+	}
+	b.WriteString(agent.CommandLine(backend, backend.InteractiveArgs(model, prompt)))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// projectConfig loads the config of the project shown in the detail view.
+// Without a project manager an empty config is returned.
+func (a *AppModel) projectConfig() (*project.Config, error) {
+	if a.ProjectManager == nil || a.Detail == nil {
+		return &project.Config{}, nil
+	}
+	return a.ProjectManager.LoadConfig(a.Detail.ProjectName)
+}
+
 // handleLaunchRalph handles LaunchRalphMsg by launching a Ralph loop or agent fallback.
 func (a *appModelAdapter) handleLaunchRalph() (tea.Model, tea.Cmd) {
 	if a.Mode != ModeProjectDetail || a.Detail == nil {
@@ -105,6 +146,12 @@ func (a *appModelAdapter) handleLaunchRalph() (tea.Model, tea.Cmd) {
 	ralphPath, err := exec.LookPath("ralph")
 	if err != nil {
 		// Fall back to agent-based approach if ralph not found.
+		backend, err := agent.FromConfig(a.globalConfig().Agent)
+		if err != nil {
+			a.Status = fmt.Sprintf("Ralph: %v", err)
+			a.StatusIsError = true
+			return a, nil
+		}
 		paneID, err := tmux.SplitPane(workDir)
 		if err != nil {
 			a.Status = fmt.Sprintf("Ralph: %v", err)
@@ -122,10 +169,9 @@ func (a *appModelAdapter) handleLaunchRalph() (tea.Model, tea.Cmd) {
 				prompt = fmt.Sprintf("Run `bd show %s` to understand the issue. Claim it with `bd update %s --status in_progress`, implement it, then close it with `bd close %s`. Follow the rules in .cursor/rules/.", selectedBead.ID, selectedBead.ID, selectedBead.ID)
 			}
 		}
-		// Pass the prompt as a single-quoted positional argument to agent.
-		// Single quotes prevent the shell from interpreting backticks and $.
-		escaped := strings.ReplaceAll(prompt, "'", `'\''`)
-		cmd := fmt.Sprintf("agent --model composer-1 --force '%s'\n", escaped)
+		// agentCommand single-quotes the prompt so the shell does not
+		// interpret backticks and $.
+		cmd := agentCommand(backend, a.globalConfig().Agent.LoopModel, nil, prompt)
 		if err := tmux.SendKeys(paneID, cmd); err != nil {
 			a.Status = fmt.Sprintf("Ralph send agent: %v", err)
 			a.StatusIsError = true
@@ -236,9 +282,9 @@ func (a *appModelAdapter) handleFocusPane(msg FocusPaneMsg) (tea.Model, tea.Cmd)
 	return a, nil
 }
 
-// handleTick handles tickMsg by refreshing panes and beads periodically.
+// handleTick handles tickMsg by refreshing panes, beads and ralph runs periodically.
 func (a *appModelAdapter) handleTick(msg tickMsg) (tea.Model, tea.Cmd) {
-	// Periodic refresh: update panes and beads when in project detail mode
+	// Periodic refresh: update panes, beads and ralph runs when in project detail mode
 	if a.Mode == ModeProjectDetail && a.Detail != nil {
 		// Refresh panes (fast, local operation)
 		a.refreshDetailPanes()
@@ -256,6 +302,7 @@ func (a *appModelAdapter) handleTick(msg tickMsg) (tea.Model, tea.Cmd) {
 			if hasWorktrees {
 				return a, tea.Batch(
 					loadResourceBeadsCmd(a.Detail.ProjectName, a.Detail.Resources),
+					loadRalphRunsCmd(a.Detail.ProjectName, a.Detail.Resources),
 					tickCmd(), // Schedule next tick
 				)
 			}
@@ -78,6 +78,13 @@ type ResourceBeadsLoadedMsg struct {
 	BeadsByResource map[int][]project.BeadInfo // resource index -> beads
 }
 
+// RalphRunsLoadedMsg is sent when the ralph runs of a project's worktrees
+// have been discovered. Worktrees without a run are absent from Runs.
+type RalphRunsLoadedMsg struct {
+	ProjectName string
+	Runs        map[string]*RalphRun // worktree path -> run
+}
+
 // CreateProjectMsg is sent when user creates a project (from modal).
 type CreateProjectMsg struct {
 	Name string
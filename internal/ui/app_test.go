@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"devdeploy/internal/agent"
+	"devdeploy/internal/config"
 	"devdeploy/internal/project"
 	"devdeploy/internal/session"
 )
@@ -541,6 +542,7 @@ func TestLaunchAgentMsg_RegistersAsAgent(t *testing.T) {
 	detail.Resources = []project.Resource{
 		{Kind: project.ResourceRepo, RepoName: "myrepo", WorktreePath: ta.Dir},
 	}
+	detail.buildItems()
 	detail.setSelected(0)
 
 	ta.Mode = ModeProjectDetail
@@ -554,6 +556,49 @@ func TestLaunchAgentMsg_RegistersAsAgent(t *testing.T) {
 	}
 }
 
+// TestAgentCommand_UsesBackend validates that the agent command line is
+// built by the configured backend and carries the project's env vars.
+func TestAgentCommand_UsesBackend(t *testing.T) {
+	got := agentCommand(agent.Cursor{}, config.DefaultAgentModel, nil, "")
+	if got != "'agent' '--model' '"+config.DefaultAgentModel+"' '--force'\n" {
+		t.Errorf("default command = %q", got)
+	}
+
+	env := (&project.Config{Env: map[string]string{"B": "it's", "A": "1"}}).Environ()
+	got = agentCommand(agent.Claude{}, "", env, "fix `it`")
+	want := "A='1' B='it'\\''s' 'claude' '--dangerously-skip-permissions' 'fix `it`'\n"
+	if got != want {
+		t.Errorf("claude command = %q, want %q", got, want)
+	}
+}
+
+// TestLaunchAgentMsg_InvalidProjectConfig validates that a broken config.yaml
+// is reported instead of launching an agent with defaults.
+func TestLaunchAgentMsg_InvalidProjectConfig(t *testing.T) {
+	ta := newTestApp(t)
+	_ = ta.ProjectManager.CreateProject("test-proj")
+	cfgPath := filepath.Join(ta.ProjectManager.ProjectDir("test-proj"), project.ConfigFileName)
+	if err := os.WriteFile(cfgPath, []byte("agent_modl: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	detail := NewProjectDetailView("test-proj")
+	detail.Resources = []project.Resource{
+		{Kind: project.ResourceRepo, RepoName: "myrepo", WorktreePath: ta.Dir},
+	}
+	detail.buildItems()
+	detail.setSelected(0)
+
+	ta.Mode = ModeProjectDetail
+	ta.Detail = detail
+	adapter := ta.adapter()
+
+	_, _ = adapter.Update(LaunchAgentMsg{})
+	if !ta.StatusIsError || !strings.Contains(ta.Status, "agent_modl") {
+		t.Errorf("expected config error mentioning agent_modl, got Status=%q", ta.Status)
+	}
+}
+
 // TestEnsureResourceWorktree_RepoUsesExisting validates that repo resources
 // return their existing WorktreePath without calling EnsurePRWorktree.
 func TestEnsureResourceWorktree_RepoUsesExisting(t *testing.T) {
@@ -1241,6 +1286,7 @@ func TestLaunchRalphMsg_WithBeads(t *testing.T) {
 			},
 		},
 	}
+	detail.buildItems()
 	detail.setSelected(0)
 
 	ta.Mode = ModeProjectDetail
@@ -1646,3 +1692,34 @@ func TestEnterAfterFilter_AppLevel(t *testing.T) {
 		t.Errorf("expected OpenShellMsg from Enter after filter, got %T", msg)
 	}
 }
+
+func TestRalphRunsLoadedMsg_StatusBarSummary(t *testing.T) {
+	ta := newTestApp(t)
+
+	detail := NewProjectDetailView("test-proj")
+	detail.Resources = []project.Resource{
+		{Kind: project.ResourceRepo, RepoName: "myrepo", WorktreePath: "/tmp/myrepo"},
+	}
+	detail.buildItems()
+	ta.Mode = ModeProjectDetail
+	ta.Detail = detail
+	adapter := ta.adapter()
+
+	if strings.Contains(adapter.View(), "ralph:") {
+		t.Error("expected no ralph summary before runs are discovered")
+	}
+
+	// Runs of another project are ignored.
+	runs := map[string]*RalphRun{
+		"/tmp/myrepo": {Live: true, State: "running", Beads: map[string]RalphBeadState{"b-1": RalphBeadRunning}},
+	}
+	_, _ = adapter.Update(RalphRunsLoadedMsg{ProjectName: "other-proj", Runs: runs})
+	if strings.Contains(adapter.View(), "ralph:") {
+		t.Error("expected runs of another project to be ignored")
+	}
+
+	_, _ = adapter.Update(RalphRunsLoadedMsg{ProjectName: "test-proj", Runs: runs})
+	if view := adapter.View(); !strings.Contains(view, "ralph: 1 loop running · 1 in progress") {
+		t.Errorf("expected ralph summary in the status bar, got:\n%s", view)
+	}
+}
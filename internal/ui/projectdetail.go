@@ -11,6 +11,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 
 	"devdeploy/internal/project"
+	"devdeploy/internal/ralph"
 )
 
 // reservedChromeLines is the number of terminal lines reserved for app chrome
@@ -100,6 +101,13 @@ func (d detailItem) Description() string {
 func (d detailItem) renderResourceTitleWithLoading() string {
 	status := resourceStatusWithLoading(*d.resource, d.view)
 
+	var ralphText string
+	if d.view != nil {
+		if run := d.view.ralphRun(d.resource); run != nil {
+			ralphText = "  " + ralphRunStyle(run).Render(ralphStatus(run))
+		}
+	}
+
 	switch d.resource.Kind {
 	case project.ResourceRepo:
 		prefix := "◆ "
@@ -107,7 +115,7 @@ func (d detailItem) renderResourceTitleWithLoading() string {
 		if status != "" {
 			text += "  " + Styles.Status.Render(status)
 		}
-		return prefix + Styles.Normal.Render(text)
+		return prefix + Styles.Normal.Render(text) + ralphText
 	case project.ResourcePR:
 		if d.resource.PR == nil {
 			return ""
@@ -121,7 +129,7 @@ func (d detailItem) renderResourceTitleWithLoading() string {
 		if status != "" {
 			text += "  " + Styles.Status.Render(status)
 		}
-		return prefix + Styles.Muted.Render(text)
+		return prefix + Styles.Muted.Render(text) + ralphText
 	}
 	return ""
 }
@@ -148,6 +156,13 @@ func (d detailItem) renderBeadTitle() string {
 	if d.bead.Status != "" && d.bead.Status != "open" {
 		rendered += "  " + beadStatusStyle.Render("["+d.bead.Status+"]")
 	}
+	if d.view != nil {
+		if run := d.view.ralphRun(d.resource); run != nil {
+			if st, ok := run.Beads[d.bead.ID]; ok {
+				rendered += "  " + ralphBeadStyle(st).Render(ralphBeadMarker(st))
+			}
+		}
+	}
 	return rendered
 }
 
@@ -175,6 +190,9 @@ type ProjectDetailView struct {
 
 	// Global panes access
 	getGlobalPanes GlobalPanesGetter // function to get all panes globally; nil falls back to project-only panes
+
+	// Ralph loops discovered in the resources' worktrees
+	ralphRuns map[string]*RalphRun // worktree path -> run
 }
 
 // Ensure ProjectDetailView implements View.
@@ -552,6 +570,80 @@ func resourceStatusWithLoading(r project.Resource, view *ProjectDetailView) stri
 	return status
 }
 
+// ralphRun returns the ralph run discovered in r's worktree, or nil.
+func (p *ProjectDetailView) ralphRun(r *project.Resource) *RalphRun {
+	if r == nil || r.WorktreePath == "" {
+		return nil
+	}
+	return p.ralphRuns[r.WorktreePath]
+}
+
+// RalphSummary returns a one-line summary of the project's ralph runs for
+// the status bar, e.g. "ralph: 1 loop running · 2 in progress, 3
+// succeeded", or "" when no worktree has a run.
+func (p *ProjectDetailView) RalphSummary() string {
+	if len(p.ralphRuns) == 0 {
+		return ""
+	}
+	var c ralphCounts
+	live := 0
+	for _, run := range p.ralphRuns {
+		c.add(run)
+		if run.Live && run.State != ralph.ControlDone {
+			live++
+		}
+	}
+	var loops string
+	switch live {
+	case 0:
+		loops = "no loop running"
+	case 1:
+		loops = "1 loop running"
+	default:
+		loops = fmt.Sprintf("%d loops running", live)
+	}
+	var parts []string
+	for _, n := range []struct {
+		n    int
+		what string
+	}{
+		{c.running, "in progress"},
+		{c.succeeded, "succeeded"},
+		{c.failed, "failed"},
+		{c.questions, "waiting on a question"},
+	} {
+		if n.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n.n, n.what))
+		}
+	}
+	if len(parts) == 0 {
+		return "ralph: " + loops
+	}
+	return "ralph: " + loops + " · " + strings.Join(parts, ", ")
+}
+
+// ralphRunStyle colors a resource's ralph marker: warning when a bead
+// failed or waits on a question.
+func ralphRunStyle(run *RalphRun) lipgloss.Style {
+	for _, st := range run.Beads {
+		if st == RalphBeadFailed || st == RalphBeadQuestion {
+			return Styles.Details
+		}
+	}
+	return Styles.Status
+}
+
+// ralphBeadStyle colors a bead's ralph marker.
+func ralphBeadStyle(st RalphBeadState) lipgloss.Style {
+	switch st {
+	case RalphBeadFailed:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(ColorDanger))
+	case RalphBeadQuestion:
+		return Styles.Details
+	}
+	return Styles.Status
+}
+
 // getOrderedActivePanes returns all active panes from Resources, ordered for indexing (1-9).
 // Panes are ordered by resource order, then by pane order within each resource.
 func (p *ProjectDetailView) getOrderedActivePanes() []project.PaneInfo {
@@ -1190,3 +1190,78 @@ func TestProjectDetailView_EnterAfterFilter(t *testing.T) {
 // 	// This test was for a maxContentLen() method that no longer exists
 // 	t.Skip("maxContentLen() method removed")
 // }
+
+func TestProjectDetailView_RalphMarkers(t *testing.T) {
+	v := NewProjectDetailView("my-project")
+	v.Resources = []project.Resource{
+		{
+			Kind:         project.ResourceRepo,
+			RepoName:     "devdeploy",
+			WorktreePath: "/tmp/devdeploy",
+			Beads: []project.BeadInfo{
+				{ID: "devdeploy-run", Title: "Being worked on"},
+				{ID: "devdeploy-ok", Title: "Finished"},
+				{ID: "devdeploy-bad", Title: "Broken"},
+				{ID: "devdeploy-ask", Title: "Unclear"},
+				{ID: "devdeploy-new", Title: "Untouched"},
+			},
+		},
+		{Kind: project.ResourceRepo, RepoName: "grafana", WorktreePath: "/tmp/grafana"},
+	}
+	v.ralphRuns = map[string]*RalphRun{
+		"/tmp/devdeploy": {Live: true, State: "running", Beads: map[string]RalphBeadState{
+			"devdeploy-run": RalphBeadRunning,
+			"devdeploy-ok":  RalphBeadSucceeded,
+			"devdeploy-bad": RalphBeadFailed,
+			"devdeploy-ask": RalphBeadQuestion,
+		}},
+	}
+	v.buildItems()
+	v.list.SetWidth(200)
+
+	lines := strings.Split(v.View(), "\n")
+	lineWith := func(s string) string {
+		for _, l := range lines {
+			if strings.Contains(l, s) {
+				return l
+			}
+		}
+		t.Fatalf("no line with %q in:\n%s", s, strings.Join(lines, "\n"))
+		return ""
+	}
+	if l := lineWith("devdeploy/"); !strings.Contains(l, "ralph running ⟳1 ✓1 ✗1 ?1") {
+		t.Errorf("resource row = %q, want the ralph marker", l)
+	}
+	if l := lineWith("grafana/"); strings.Contains(l, "ralph") {
+		t.Errorf("resource without a run = %q, want no ralph marker", l)
+	}
+	for id, marker := range map[string]string{
+		"devdeploy-run": "⟳ ralph",
+		"devdeploy-ok":  "✓ done",
+		"devdeploy-bad": "✗ failed",
+		"devdeploy-ask": "? question",
+	} {
+		if l := lineWith(id); !strings.Contains(l, marker) {
+			t.Errorf("%s row = %q, want %q", id, l, marker)
+		}
+	}
+	if l := lineWith("devdeploy-new"); strings.ContainsAny(l, "⟳✓✗?") {
+		t.Errorf("untouched bead row = %q, want no marker", l)
+	}
+}
+
+func TestProjectDetailView_RalphSummary(t *testing.T) {
+	v := NewProjectDetailView("my-project")
+	if s := v.RalphSummary(); s != "" {
+		t.Errorf("summary without runs = %q, want empty", s)
+	}
+	v.ralphRuns = map[string]*RalphRun{
+		"/tmp/a": {Live: true, State: "running", Beads: map[string]RalphBeadState{"a-1": RalphBeadRunning, "a-2": RalphBeadSucceeded}},
+		"/tmp/b": {Live: true, State: "paused", Beads: map[string]RalphBeadState{"b-1": RalphBeadQuestion}},
+		"/tmp/c": {State: "done", Beads: map[string]RalphBeadState{"c-1": RalphBeadSucceeded, "c-2": RalphBeadFailed}},
+	}
+	want := "ralph: 2 loops running · 1 in progress, 2 succeeded, 1 failed, 1 waiting on a question"
+	if s := v.RalphSummary(); s != want {
+		t.Errorf("summary = %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"devdeploy/internal/ralph"
+)
+
+// ralphStatusTimeout bounds how long a control socket may take to answer.
+const ralphStatusTimeout = 500 * time.Millisecond
+
+// ralphInterrupted is the RalphRun.State of a run whose journal has no end
+// but whose ralph is gone.
+const ralphInterrupted = "interrupted"
+
+// RalphBeadState is what a ralph run did with a bead.
+type RalphBeadState int
+
+const (
+	RalphBeadRunning   RalphBeadState = iota // An agent is working on it.
+	RalphBeadSucceeded                       // Closed by the agent.
+	RalphBeadFailed                          // Failed or timed out.
+	RalphBeadQuestion                        // Waiting on a human's answer.
+)
+
+// RalphRun is what devdeploy knows about the ralph loop of a worktree:
+// the live run answering on its control socket, or else the last run in
+// its journal.
+type RalphRun struct {
+	RunID string
+	Live  bool   // answered on the control socket
+	State string // ralph.ControlRunning, ControlPaused, ControlDone or ralphInterrupted
+	Beads map[string]RalphBeadState
+}
+
+// discoverRalphRun looks for a ralph run in workDir. It returns nil when
+// there is none.
+func discoverRalphRun(workDir string) *RalphRun {
+	ctx, cancel := context.WithTimeout(context.Background(), ralphStatusTimeout)
+	defer cancel()
+	client := ralph.NewControlClient(ralph.ControlSocketPath(workDir))
+	if s, err := client.Status(ctx); err == nil {
+		return ralphRunFromStatus(s)
+	}
+	state, err := ralph.LatestRun(workDir)
+	if err != nil || state == nil {
+		return nil
+	}
+	return ralphRunFromJournal(state)
+}
+
+// ralphRunFromStatus converts a live run's control API status.
+func ralphRunFromStatus(s *ralph.ControlStatus) *RalphRun {
+	run := &RalphRun{RunID: s.RunID, Live: true, State: s.State, Beads: make(map[string]RalphBeadState)}
+	for id, outcome := range s.Settled {
+		run.Beads[id] = ralphBeadState(outcome)
+	}
+	for _, b := range s.InFlight {
+		run.Beads[b.ID] = RalphBeadRunning
+	}
+	return run
+}
+
+// ralphRunFromJournal converts a finished or abandoned run's journal.
+// Beads it never finished are left out: nothing is working on them.
+func ralphRunFromJournal(state *ralph.RunState) *RalphRun {
+	run := &RalphRun{RunID: state.RunID, State: ralph.ControlDone, Beads: make(map[string]RalphBeadState)}
+	if !state.Ended {
+		run.State = ralphInterrupted
+	}
+	for id, r := range state.Beads {
+		if r.Finished {
+			run.Beads[id] = ralphBeadState(r.Outcome)
+		}
+	}
+	return run
+}
+
+func ralphBeadState(o ralph.Outcome) RalphBeadState {
+	switch o {
+	case ralph.OutcomeSuccess:
+		return RalphBeadSucceeded
+	case ralph.OutcomeQuestion:
+		return RalphBeadQuestion
+	default:
+		return RalphBeadFailed
+	}
+}
+
+// ralphCounts tallies bead states across runs.
+type ralphCounts struct {
+	running, succeeded, failed, questions int
+}
+
+func (c *ralphCounts) add(r *RalphRun) {
+	for _, st := range r.Beads {
+		switch st {
+		case RalphBeadRunning:
+			c.running++
+		case RalphBeadSucceeded:
+			c.succeeded++
+		case RalphBeadFailed:
+			c.failed++
+		case RalphBeadQuestion:
+			c.questions++
+		}
+	}
+}
+
+// markers renders the counts compactly, e.g. "⟳2 ✓3 ✗1 ?1".
+func (c ralphCounts) markers() string {
+	var parts []string
+	for _, p := range []struct {
+		n      int
+		marker string
+	}{
+		{c.running, "⟳"}, {c.succeeded, "✓"}, {c.failed, "✗"}, {c.questions, "?"},
+	} {
+		if p.n > 0 {
+			parts = append(parts, fmt.Sprintf("%s%d", p.marker, p.n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ralphStatus returns the resource row's ralph marker, e.g.
+// "ralph running ⟳2 ✓3", or "" without a run.
+func ralphStatus(r *RalphRun) string {
+	if r == nil {
+		return ""
+	}
+	var c ralphCounts
+	c.add(r)
+	s := "ralph " + r.State
+	if m := c.markers(); m != "" {
+		s += " " + m
+	}
+	return s
+}
+
+// ralphBeadMarker returns the bead row's marker for st.
+func ralphBeadMarker(st RalphBeadState) string {
+	switch st {
+	case RalphBeadRunning:
+		return "⟳ ralph"
+	case RalphBeadSucceeded:
+		return "✓ done"
+	case RalphBeadFailed:
+		return "✗ failed"
+	case RalphBeadQuestion:
+		return "? question"
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"devdeploy/internal/ralph"
+)
+
+func TestDiscoverRalphRun_Live(t *testing.T) {
+	workDir := t.TempDir()
+	l, err := ralph.ListenControl(ralph.ControlSocketPath(workDir))
+	if err != nil {
+		t.Fatalf("ListenControl: %v", err)
+	}
+	status := ralph.ControlStatus{
+		WorkDir:  workDir,
+		RunID:    "run-1",
+		State:    ralph.ControlRunning,
+		InFlight: []ralph.ControlBead{{ID: "b-3"}},
+		Settled:  map[string]ralph.Outcome{"b-1": ralph.OutcomeSuccess, "b-2": ralph.OutcomeTimeout},
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(status)
+	})}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	run := discoverRalphRun(workDir)
+	if run == nil {
+		t.Fatal("discoverRalphRun = nil, want the live run")
+	}
+	if !run.Live || run.RunID != "run-1" || run.State != ralph.ControlRunning {
+		t.Errorf("run = %+v, want live run-1 running", run)
+	}
+	want := map[string]RalphBeadState{"b-1": RalphBeadSucceeded, "b-2": RalphBeadFailed, "b-3": RalphBeadRunning}
+	for id, st := range want {
+		if run.Beads[id] != st {
+			t.Errorf("bead %s = %v, want %v", id, run.Beads[id], st)
+		}
+	}
+}
+
+func TestDiscoverRalphRun_None(t *testing.T) {
+	if run := discoverRalphRun(t.TempDir()); run != nil {
+		t.Errorf("discoverRalphRun in a dir without ralph = %+v, want nil", run)
+	}
+}
+
+func TestRalphRunFromJournal(t *testing.T) {
+	state := &ralph.RunState{
+		RunID: "run-2",
+		Beads: map[string]*ralph.BeadRecord{
+			"b-1": {ID: "b-1", Finished: true, Outcome: ralph.OutcomeQuestion},
+			"b-2": {ID: "b-2"}, // never finished
+		},
+	}
+	run := ralphRunFromJournal(state)
+	if run.Live || run.State != ralphInterrupted {
+		t.Errorf("run = %+v, want an interrupted, not live run", run)
+	}
+	if len(run.Beads) != 1 || run.Beads["b-1"] != RalphBeadQuestion {
+		t.Errorf("beads = %v, want only b-1 waiting on a question", run.Beads)
+	}
+	state.Ended = true
+	if run := ralphRunFromJournal(state); run.State != ralph.ControlDone {
+		t.Errorf("ended run state = %q, want %q", run.State, ralph.ControlDone)
+	}
+}